@@ -7,7 +7,11 @@ import (
 // Config 应用配置结构
 type Config struct {
 	Server struct {
-		Port int
+		Port   int
+		NodeId int // 节点ID（0-1023），用于生成订单号，多实例部署时每个实例必须不同
+	}
+	Storage struct {
+		Mode string // 存储模式：persistent（MySQL+Redis）或memory（进程内存储，重启后数据丢失，用于演示和端到端测试），默认persistent
 	}
 	DataBase struct {
 		Driver string
@@ -22,6 +26,31 @@ type Config struct {
 		DB       int
 		PoolSize int
 	}
+	Payment struct {
+		Gateway           string // 支付网关名称，为空时使用本地模拟网关mock
+		Secret            string // 网关回调的签名密钥，未配置时拒绝启动
+		AllowInsecureMock bool   // 允许模拟网关在未配置签名密钥时启动（回调可被伪造），仅用于本地开发
+	}
+	Order struct {
+		ArchiveRetentionDays    int         // 已完成/已取消订单的保留天数，超过后迁移到归档表
+		PaymentTimeoutMinutes   int         // 待支付订单的支付时限（分钟），超时未支付自动取消
+		PaymentTimeoutOverrides map[int]int // 按商品覆盖支付时限（商品ID -> 分钟），订单包含多个商品时取最短的时限
+	}
+	Stock struct {
+		ReconcileIntervalMinutes int    // 库存对账间隔（分钟）
+		ReconcileRepair          bool   // 定时对账时是否自动修复Redis库存缓存，为false时只报告差异
+		Backend                  string // 库存存储：redis（Redis缓存扣减+定时同步到MySQL）或database（直接在MySQL中扣减，延迟队列、幂等性记录和优惠券计数同样保存在数据库中，不连接Redis），默认redis
+	}
+	Warehouse struct {
+		AllocationStrategy string // 下单时的仓库分配策略：priority（按优先级）、most_stock（库存最多）、nearest（就近），默认priority
+	}
+	DelayQueue struct {
+		ProcessingTimeoutSeconds int // 任务处理超时时间（秒），超时后由RecoveryScheduler移回ready队列重试
+		BatchSize                int // 每次从ready队列获取的最大任务数
+	}
+	Admin struct {
+		Accounts []string // 管理员账号列表，可以访问/v1/admin下的接口
+	}
 }
 
 // LoadConfig 从 config.yaml 加载配置文件
@@ -30,6 +59,16 @@ func LoadConfig() (*Config, error) {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath("./config")
 
+	// 默认配置，配置文件中未提供时使用
+	viper.SetDefault("storage.mode", "persistent")
+	viper.SetDefault("order.archiveRetentionDays", 90)
+	viper.SetDefault("order.paymentTimeoutMinutes", 15)
+	viper.SetDefault("stock.reconcileIntervalMinutes", 10)
+	viper.SetDefault("stock.backend", "redis")
+	viper.SetDefault("warehouse.allocationStrategy", "priority")
+	viper.SetDefault("delayQueue.processingTimeoutSeconds", 300)
+	viper.SetDefault("delayQueue.batchSize", 100)
+
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"server/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleWare 管理员鉴权中间件，只允许配置中列出的账号访问
+// 必须注册在AuthMiddleWare之后（依赖其注入的account）
+// 参数 accounts: 管理员账号列表，为空时拒绝所有请求
+func AdminMiddleWare(accounts []string) gin.HandlerFunc {
+	admins := make(map[string]struct{}, len(accounts))
+	for _, account := range accounts {
+		admins[account] = struct{}{}
+	}
+	return func(c *gin.Context) {
+		account, _ := c.Get("account")
+		name, _ := account.(string)
+		if _, ok := admins[name]; !ok || name == "" {
+			response.Forbidden(c, response.CodeForbidden, "admin only")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
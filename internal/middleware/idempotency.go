@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"server/pkg/idempotency"
+	"server/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// IdempotencyKeyHeader 客户端传入幂等键的请求头
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// idempotencyLockTTL 请求处理中的占位过期时间，防止处理过程崩溃导致幂等键永久被占用
+	idempotencyLockTTL = time.Minute
+	// idempotencyRecordTTL 已完成请求的响应保存时间
+	idempotencyRecordTTL = time.Hour * 24
+	// maxIdempotencyKeyLength 幂等键的最大长度
+	maxIdempotencyKeyLength = 128
+)
+
+// responseRecorder 包装gin.ResponseWriter，在写出响应的同时记录响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+// Write 写出响应并记录响应体
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 写出字符串响应并记录响应体
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleWare 幂等性中间件，用于创建订单等非幂等的写接口
+// 必须注册在AuthMiddleWare之后（幂等键按用户隔离）
+//
+// 工作流程：
+// 1. 请求未携带Idempotency-Key头时直接放行
+// 2. 以"idempotency:{userID}:{method}:{route}:{key}"为键尝试占用，并保存请求指纹（实际路径和请求体的SHA-256）：
+//   - 首次请求：占用成功，执行后续处理函数，完成后保存响应（5xx响应会释放键，允许重试）
+//   - 重复请求且首次请求已完成：直接重放保存的响应，并设置Idempotent-Replayed头
+//   - 重复请求但首次请求仍在处理中：返回409冲突
+//   - 指纹与首次请求不同（如复用幂等键支付另一个订单或修改了请求体）：返回422，不会重放其他请求的响应
+//
+// route为注册的路由模板（如/v1/order/:order_no/pay），同一个幂等键用于不同接口时互不影响
+func IdempotencyMiddleWare(store idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			response.BadRequest(c, response.CodeInvalidParams, "Idempotency-Key too long")
+			c.Abort()
+			return
+		}
+		userID, exists := c.Get("userID")
+		if !exists {
+			response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+			c.Abort()
+			return
+		}
+		storeKey := "idempotency:" + strconv.Itoa(userID.(int)) + ":" + c.Request.Method + ":" + c.FullPath() + ":" + key
+
+		body, err := c.GetRawData()
+		if err != nil {
+			response.BadRequest(c, response.CodeInvalidParams, "invalid body")
+			c.Abort()
+			return
+		}
+		// 请求体已被读取，重新放回供后续处理函数绑定
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := context.Background()
+		record, acquired, err := store.Begin(ctx, storeKey, requestFingerprint(c.Request.URL.Path, body), idempotencyLockTTL)
+		if err != nil {
+			if errors.Is(err, idempotency.ErrFingerprintMismatch) {
+				response.UnprocessableEntity(c, response.CodeIdempotencyMismatch, "Idempotency-Key was already used for a different request")
+				c.Abort()
+				return
+			}
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+			c.Abort()
+			return
+		}
+		if !acquired {
+			if record == nil {
+				// 相同幂等键的请求仍在处理中
+				response.Conflict(c, response.CodeIdempotencyConflict, "request with the same Idempotency-Key is in progress")
+				c.Abort()
+				return
+			}
+			// 重放首次请求的响应
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.Status, "application/json; charset=utf-8", record.Body)
+			c.Abort()
+			log.Info("replayed idempotent request:", storeKey)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			// 处理函数panic或返回5xx时释放幂等键，允许客户端使用相同键重试
+			if !completed {
+				if err := store.Release(ctx, storeKey); err != nil {
+					log.Errorf("Failed to release idempotency key %s: %v", storeKey, err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= 500 {
+			return
+		}
+		record = &idempotency.Record{Fingerprint: requestFingerprint(c.Request.URL.Path, body), Status: status, Body: recorder.body.Bytes()}
+		if err := store.Complete(ctx, storeKey, record, idempotencyRecordTTL); err != nil {
+			log.Errorf("Failed to save idempotent response %s: %v", storeKey, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// requestFingerprint 计算请求指纹：实际请求路径（包含订单号等路径参数）和请求体的SHA-256
+func requestFingerprint(path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -10,3 +10,11 @@ type AddToCartRequest struct {
 type UpdateCartRequest struct {
 	Quantity int `json:"quantity" binding:"required"`
 }
+
+// CheckoutRequest 购物车结算请求
+type CheckoutRequest struct {
+	CartIds    []int  `json:"cart_ids"`    // 要结算的购物车条目ID，为空时结算整个购物车
+	CouponCode string `json:"coupon_code"` // 可选，优惠券券码
+	TotalPrice string `json:"total_price"` // 可选，提供时会与服务端计算的应付金额（已扣除优惠）校验
+	Address    string `json:"address" binding:"required"`
+}
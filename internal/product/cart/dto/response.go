@@ -1,8 +1,16 @@
 package dto
 
-import "server/internal/product/cart/model"
+import (
+	"server/internal/product/cart/model"
+	orderModel "server/internal/product/order/model"
+)
 
 // CartResponse 购物车响应
 type CartResponse struct {
 	Items []*model.Cart `json:"items"`
 }
+
+// CheckoutResponse 购物车结算响应
+type CheckoutResponse struct {
+	Order *orderModel.Order `json:"order"`
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"server/internal/product/cart/dto"
 	"server/internal/product/cart/service"
+	orderService "server/internal/product/order/service"
+	promotionService "server/internal/product/promotion/service"
 	userService "server/internal/product/user/service"
 	"server/pkg/response"
 	"strconv"
@@ -60,16 +63,28 @@ func (h *CartHandler) AddToCart(c *gin.Context) {
 	log.Info("user", uid, "add to cart success")
 }
 
-// RemoveFromCart 处理从购物车移除商品请求
+// RemoveFromCart 处理从购物车移除商品请求，只能移除自己的购物车条目，否则返回404
 func (h *CartHandler) RemoveFromCart(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
 		return
 	}
-	err = h.cartService.RemoveFromCart(id)
+	err = h.cartService.RemoveFromCart(uid, id)
 	if err != nil {
+		if errors.Is(err, service.ErrCartItemNotFound) {
+			response.NotFound(c, response.CodeCartNotFound, err.Error())
+			return
+		}
 		response.BadRequest(c, response.CodeInternalError, err.Error())
 		return
 	}
@@ -78,8 +93,16 @@ func (h *CartHandler) RemoveFromCart(c *gin.Context) {
 	return
 }
 
-// UpdateCart 处理更新购物车商品数量请求
+// UpdateCart 处理更新购物车商品数量请求，只能更新自己的购物车条目，否则返回404
 func (h *CartHandler) UpdateCart(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
@@ -93,8 +116,12 @@ func (h *CartHandler) UpdateCart(c *gin.Context) {
 		response.BadRequest(c, response.CodeInvalidJSON, "invalid JSON")
 		return
 	}
-	err = h.cartService.UpdateCart(id, req.Quantity)
+	err = h.cartService.UpdateCart(uid, id, req.Quantity)
 	if err != nil {
+		if errors.Is(err, service.ErrCartItemNotFound) {
+			response.NotFound(c, response.CodeCartNotFound, err.Error())
+			return
+		}
 		response.BadRequest(c, response.CodeInternalError, err.Error())
 		return
 	}
@@ -134,3 +161,69 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	log.Info("user", uid, "get cart success")
 	return
 }
+
+// Checkout 处理购物车结算请求，将购物车转换为订单
+// 业务流程：
+// 1. 从JWT中间件获取已认证的用户ID
+// 2. 解析请求体（可选的购物车条目ID列表、券码、总价，以及地址）
+// 3. 调用Service层结算（领取并清除购物车条目、扣减库存、创建订单、加入延迟取消队列）
+// 4. 返回创建的订单
+//
+// 注意：
+// - cart_ids为空时结算整个购物车
+// - 任一步骤失败都会回滚已扣减的Redis库存并放回购物车条目，不会产生部分订单
+// - 并发结算同一购物车时只有一个请求成功，其余返回购物车为空或条目不存在
+func (h *CartHandler) Checkout(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	var req dto.CheckoutRequest
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	order, err := h.cartService.Checkout(uid, req.CartIds, req.CouponCode, req.TotalPrice, req.Address)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmptyCart):
+			response.BadRequest(c, response.CodeCartEmpty, err.Error())
+		case errors.Is(err, service.ErrCartItemNotFound):
+			response.NotFound(c, response.CodeCartNotFound, err.Error())
+		case errors.Is(err, orderService.ErrInsufficientStock):
+			response.BadRequest(c, response.CodeInsufficientStock, err.Error())
+		case errors.Is(err, orderService.ErrCommodityNotFound):
+			response.BadRequest(c, response.CodeCommodityNotFound, err.Error())
+		case errors.Is(err, orderService.ErrPriceMismatch):
+			response.BadRequest(c, response.CodeOrderPriceMismatch, err.Error())
+		case errors.Is(err, orderService.ErrInvalidTotalPrice):
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		case errors.Is(err, promotionService.ErrCouponNotFound):
+			response.BadRequest(c, response.CodeCouponNotFound, err.Error())
+		case errors.Is(err, promotionService.ErrCouponNotApplicable):
+			response.BadRequest(c, response.CodeCouponNotApplicable, err.Error())
+		case errors.Is(err, promotionService.ErrCouponExhausted):
+			response.BadRequest(c, response.CodeCouponExhausted, err.Error())
+		case errors.Is(err, promotionService.ErrCouponUserLimit):
+			response.BadRequest(c, response.CodeCouponUserLimit, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleNotStarted):
+			response.BadRequest(c, response.CodeFlashSaleNotStarted, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleSoldOut):
+			response.BadRequest(c, response.CodeFlashSaleSoldOut, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleUserLimit):
+			response.BadRequest(c, response.CodeFlashSaleUserLimit, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeOrderCreateFailed, err.Error())
+		}
+		return
+	}
+
+	response.Success(c, dto.CheckoutResponse{Order: order})
+	log.Info("user ", uid, " checkout success, orderNo:", order.OrderNo)
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"fmt"
+	"server/internal/product/cart/model"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryCartRepository struct {
+	mu     sync.RWMutex
+	carts  map[int]model.Cart
+	lastId int
+}
+
+// NewMemoryCartRepository 创建一个基于进程内存储的购物车仓储实例，用于memory存储模式
+func NewMemoryCartRepository() CartRepository {
+	return &memoryCartRepository{carts: make(map[int]model.Cart)}
+}
+
+// CreateCart 创建新购物车条目，并回写分配的条目ID
+func (cRepo *memoryCartRepository) CreateCart(cart *model.Cart) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	now := time.Now()
+	if cart.CreatedAt.IsZero() {
+		cart.CreatedAt = now
+	}
+	if cart.UpdatedAt.IsZero() {
+		cart.UpdatedAt = now
+	}
+	cRepo.lastId++
+	cart.Id = cRepo.lastId
+	cRepo.carts[cart.Id] = *cart
+	return nil
+}
+
+// DeleteCart 根据ID删除购物车条目
+func (cRepo *memoryCartRepository) DeleteCart(id int) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	if _, ok := cRepo.carts[id]; !ok {
+		return fmt.Errorf("cart with id=%d not found", id)
+	}
+	delete(cRepo.carts, id)
+	return nil
+}
+
+// DeleteCartByUserId 删除属于指定用户的购物车条目
+// 条目不存在或不属于该用户时都返回gorm.ErrRecordNotFound
+func (cRepo *memoryCartRepository) DeleteCartByUserId(id int, userId int) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	if cart, ok := cRepo.carts[id]; !ok || cart.UserId != userId {
+		return gorm.ErrRecordNotFound
+	}
+	delete(cRepo.carts, id)
+	return nil
+}
+
+// ClaimCarts 领取指定用户要结算的购物车条目：在同一把锁内取出并删除这些条目，返回实际领取到的条目
+// ids为空时领取该用户的整个购物车；不存在、不属于该用户或已被并发的结算领取的条目不会返回
+func (cRepo *memoryCartRepository) ClaimCarts(userId int, ids []int) ([]*model.Cart, error) {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	idSet := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		idSet[id] = true
+	}
+	carts := make([]*model.Cart, 0)
+	for id, cart := range cRepo.carts {
+		if cart.UserId != userId || (len(ids) > 0 && !idSet[id]) {
+			continue
+		}
+		cart := cart
+		carts = append(carts, &cart)
+		delete(cRepo.carts, id)
+	}
+	sort.Slice(carts, func(i, j int) bool { return carts[i].Id < carts[j].Id })
+	return carts, nil
+}
+
+// RestoreCarts 按原ID放回ClaimCarts领取的购物车条目，用于结算失败时撤销领取
+func (cRepo *memoryCartRepository) RestoreCarts(carts []*model.Cart) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	for _, cart := range carts {
+		cRepo.carts[cart.Id] = *cart
+	}
+	return nil
+}
+
+// UpdateCart 更新购物车信息，与GORM的Updates一致只更新非零值字段
+func (cRepo *memoryCartRepository) UpdateCart(cart *model.Cart) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	current, ok := cRepo.carts[cart.Id]
+	if !ok {
+		return nil
+	}
+	if cart.UserId != 0 {
+		current.UserId = cart.UserId
+	}
+	if cart.CommodityId != 0 {
+		current.CommodityId = cart.CommodityId
+	}
+	if cart.Quantity != 0 {
+		current.Quantity = cart.Quantity
+	}
+	current.UpdatedAt = time.Now()
+	cRepo.carts[cart.Id] = current
+	return nil
+}
+
+// FindCartById 根据ID查找购物车条目，条目不存在时返回gorm.ErrRecordNotFound
+func (cRepo *memoryCartRepository) FindCartById(id int) (*model.Cart, error) {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	cart, ok := cRepo.carts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &cart, nil
+}
+
+// FindCartByIdAndUserId 根据ID查找属于指定用户的购物车条目
+// 条目不存在或不属于该用户时都返回gorm.ErrRecordNotFound
+func (cRepo *memoryCartRepository) FindCartByIdAndUserId(id int, userId int) (*model.Cart, error) {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	cart, ok := cRepo.carts[id]
+	if !ok || cart.UserId != userId {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &cart, nil
+}
+
+// FindCartByUserId 查找指定用户的所有购物车条目
+func (cRepo *memoryCartRepository) FindCartByUserId(userId int) ([]*model.Cart, error) {
+	return cRepo.listCarts(func(cart *model.Cart) bool { return cart.UserId == userId }), nil
+}
+
+// ListCart 获取所有购物车条目
+func (cRepo *memoryCartRepository) ListCart() ([]*model.Cart, error) {
+	return cRepo.listCarts(func(*model.Cart) bool { return true }), nil
+}
+
+// listCarts 按ID升序返回满足条件的购物车条目
+func (cRepo *memoryCartRepository) listCarts(match func(cart *model.Cart) bool) []*model.Cart {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	carts := make([]*model.Cart, 0)
+	for _, cart := range cRepo.carts {
+		if match(&cart) {
+			cart := cart
+			carts = append(carts, &cart)
+		}
+	}
+	sort.Slice(carts, func(i, j int) bool { return carts[i].Id < carts[j].Id })
+	return carts
+}
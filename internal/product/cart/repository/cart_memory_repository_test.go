@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"server/internal/product/cart/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newCartFixture 创建内存购物车仓储，用户1有条目1、2，用户2有条目3
+func newCartFixture(t *testing.T) CartRepository {
+	t.Helper()
+	repo := NewMemoryCartRepository()
+	for _, cart := range []*model.Cart{
+		{UserId: 1, CommodityId: 10, Quantity: 1},
+		{UserId: 1, CommodityId: 11, Quantity: 2},
+		{UserId: 2, CommodityId: 10, Quantity: 3},
+	} {
+		require.NoError(t, repo.CreateCart(cart))
+	}
+	return repo
+}
+
+func cartIds(carts []*model.Cart) []int {
+	ids := make([]int, 0, len(carts))
+	for _, cart := range carts {
+		ids = append(ids, cart.Id)
+	}
+	return ids
+}
+
+func TestMemoryCartRepositoryClaimCarts(t *testing.T) {
+	tests := []struct {
+		name      string
+		userId    int
+		ids       []int
+		claimed   []int
+		remaining []int // 领取后用户1剩余的条目
+	}{
+		{"whole cart", 1, nil, []int{1, 2}, []int{}},
+		{"selected rows", 1, []int{2}, []int{2}, []int{1}},
+		{"rows of another user are skipped", 1, []int{1, 3}, []int{1}, []int{2}},
+		{"missing rows are skipped", 1, []int{1, 99}, []int{1}, []int{2}},
+		{"nothing to claim", 3, nil, []int{}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newCartFixture(t)
+			carts, err := repo.ClaimCarts(tt.userId, tt.ids)
+			require.NoError(t, err)
+			assert.Equal(t, tt.claimed, cartIds(carts))
+
+			left, err := repo.FindCartByUserId(1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.remaining, cartIds(left))
+		})
+	}
+}
+
+func TestMemoryCartRepositoryClaimIsExclusive(t *testing.T) {
+	repo := newCartFixture(t)
+	first, err := repo.ClaimCarts(1, []int{1, 2})
+	require.NoError(t, err)
+	assert.Len(t, first, 2)
+
+	second, err := repo.ClaimCarts(1, []int{1, 2})
+	require.NoError(t, err)
+	assert.Empty(t, second, "rows already claimed by another checkout must not be returned")
+
+	require.NoError(t, repo.RestoreCarts(first))
+	restored, err := repo.FindCartByUserId(1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, cartIds(restored))
+	assert.Equal(t, 2, restored[1].Quantity)
+}
+
+func TestMemoryCartRepositoryOwnership(t *testing.T) {
+	repo := newCartFixture(t)
+
+	_, err := repo.FindCartByIdAndUserId(3, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, repo.DeleteCartByUserId(3, 1), gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.DeleteCartByUserId(3, 2))
+	_, err = repo.FindCartById(3)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -5,18 +5,23 @@ import (
 	"server/internal/product/cart/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // cartWriter 定义购物车写操作接口
 type cartWriter interface {
 	CreateCart(cart *model.Cart) error
 	DeleteCart(id int) error
+	DeleteCartByUserId(id int, userId int) error
 	UpdateCart(cart *model.Cart) error
+	ClaimCarts(userId int, ids []int) ([]*model.Cart, error)
+	RestoreCarts(carts []*model.Cart) error
 }
 
 // cartReader 定义购物车读操作接口
 type cartReader interface {
 	FindCartById(id int) (*model.Cart, error)
+	FindCartByIdAndUserId(id int, userId int) (*model.Cart, error)
 	FindCartByUserId(userId int) ([]*model.Cart, error)
 	ListCart() ([]*model.Cart, error)
 }
@@ -51,6 +56,55 @@ func (cRepo *gormCartRepository) DeleteCart(id int) error {
 	return err.Error
 }
 
+// DeleteCartByUserId 删除属于指定用户的购物车条目
+// 条目不存在或不属于该用户时都返回gorm.ErrRecordNotFound
+func (cRepo *gormCartRepository) DeleteCartByUserId(id int, userId int) error {
+	err := cRepo.gormDB.Where("id = ? AND user_id = ?", id, userId).Delete(&model.Cart{})
+	if err.Error != nil {
+		return err.Error
+	}
+	if err.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ClaimCarts 领取指定用户要结算的购物车条目：在同一事务中锁定并删除这些条目，返回实际领取到的条目
+// ids为空时领取该用户的整个购物车；不存在、不属于该用户或已被并发的结算领取的条目不会返回
+// 并发结算同一批条目时，后到的事务等待锁释放后只能读到已被删除后的结果，因此每个条目只会被一次结算领取
+func (cRepo *gormCartRepository) ClaimCarts(userId int, ids []int) ([]*model.Cart, error) {
+	var carts []*model.Cart
+	err := cRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userId)
+		if len(ids) > 0 {
+			query = query.Where("id IN ?", ids)
+		}
+		if err := query.Order("id").Find(&carts).Error; err != nil {
+			return err
+		}
+		if len(carts) == 0 {
+			return nil
+		}
+		claimed := make([]int, 0, len(carts))
+		for _, cart := range carts {
+			claimed = append(claimed, cart.Id)
+		}
+		return tx.Where("id IN ?", claimed).Delete(&model.Cart{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return carts, nil
+}
+
+// RestoreCarts 按原ID放回ClaimCarts领取的购物车条目，用于结算失败时撤销领取
+func (cRepo *gormCartRepository) RestoreCarts(carts []*model.Cart) error {
+	if len(carts) == 0 {
+		return nil
+	}
+	return cRepo.gormDB.Create(carts).Error
+}
+
 // UpdateCart 更新数据库中的购物车信息
 func (cRepo *gormCartRepository) UpdateCart(cart *model.Cart) error {
 	err := cRepo.gormDB.Where("id=?", cart.Id).Updates(cart).Error
@@ -67,6 +121,17 @@ func (cRepo *gormCartRepository) FindCartById(id int) (*model.Cart, error) {
 	return &cart, nil
 }
 
+// FindCartByIdAndUserId 根据ID查找属于指定用户的购物车条目
+// 条目不存在或不属于该用户时都返回gorm.ErrRecordNotFound
+func (cRepo *gormCartRepository) FindCartByIdAndUserId(id int, userId int) (*model.Cart, error) {
+	var cart model.Cart
+	err := cRepo.gormDB.Where("id = ? AND user_id = ?", id, userId).First(&cart).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
 // FindCartByUserId 根据用户ID从数据库中查找该用户的所有购物车条目
 func (cRepo *gormCartRepository) FindCartByUserId(userId int) ([]*model.Cart, error) {
 	var carts []*model.Cart
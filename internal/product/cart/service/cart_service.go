@@ -1,19 +1,26 @@
 package service
 
 import (
+	"errors"
 	"server/internal/product/cart/model"
 	"server/internal/product/cart/repository"
+	orderModel "server/internal/product/order/model"
+	orderService "server/internal/product/order/service"
 	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // CartService 提供购物车相关的业务逻辑服务
 type CartService struct {
 	cartRepo repository.CartRepository
+	orderSvc *orderService.OrderService
 }
 
 // NewCartService 创建一个新的购物车服务实例
-func NewCartService(repo repository.CartRepository) *CartService {
-	return &CartService{cartRepo: repo}
+func NewCartService(repo repository.CartRepository, orderSvc *orderService.OrderService) *CartService {
+	return &CartService{cartRepo: repo, orderSvc: orderSvc}
 }
 
 // AddToCart 添加商品到购物车
@@ -41,14 +48,18 @@ func (cs *CartService) AddToCart(userId int, commodityId int, quantity int) erro
 	return cs.cartRepo.CreateCart(cart)
 }
 
-// RemoveFromCart 从购物车中移除商品
-func (cs *CartService) RemoveFromCart(cartId int) error {
-	return cs.cartRepo.DeleteCart(cartId)
+// RemoveFromCart 从用户自己的购物车中移除商品，条目不存在或不属于该用户时返回ErrCartItemNotFound
+func (cs *CartService) RemoveFromCart(userId int, cartId int) error {
+	err := cs.cartRepo.DeleteCartByUserId(cartId, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCartItemNotFound
+	}
+	return err
 }
 
 // UpdateCart 更新购物车中商品的数量
 // 业务流程：
-// 1. 根据购物车ID查询购物车项
+// 1. 根据购物车ID查询属于该用户的购物车项（不存在或不属于该用户时返回ErrCartItemNotFound）
 // 2. 更新商品数量
 // 3. 设置更新时间为当前时间
 // 4. 调用Repository层更新购物车记录
@@ -57,10 +68,13 @@ func (cs *CartService) RemoveFromCart(cartId int) error {
 // - 数量可以增加或减少
 // - 如果数量设为0，建议使用RemoveFromCart方法删除记录
 // - 不会验证库存是否充足，下单时才验证
-func (cs *CartService) UpdateCart(cartId int, quantity int) error {
-	// 查询购物车项
-	cart, err := cs.cartRepo.FindCartById(cartId)
+func (cs *CartService) UpdateCart(userId int, cartId int, quantity int) error {
+	// 查询属于该用户的购物车项
+	cart, err := cs.cartRepo.FindCartByIdAndUserId(cartId, userId)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCartItemNotFound
+		}
 		return err
 	}
 
@@ -75,3 +89,64 @@ func (cs *CartService) UpdateCart(cartId int, quantity int) error {
 func (cs *CartService) GetCart(userId int) ([]*model.Cart, error) {
 	return cs.cartRepo.FindCartByUserId(userId)
 }
+
+// Checkout 将用户的购物车（或选中的购物车条目）结算为一个订单
+// 业务流程：
+// 1. 领取要结算的购物车条目（cartIds为空时结算整个购物车，只会领取属于该用户的条目），领取即从购物车中删除
+// 2. 将购物车条目转换为订单行，调用OrderService.CreateOrder创建订单（扣减库存、写入订单、加入延迟取消队列）
+// 3. 创建订单失败时把领取的条目放回购物车
+//
+// 注意：
+// - 先领取再下单，并发结算同一个购物车时只有一个请求能领取到条目，另一个返回ErrEmptyCart或ErrCartItemNotFound，不会重复下单
+// - cartIds中包含不存在、不属于该用户或已被其他结算领取的条目时，整个结算失败，不会部分下单
+// - 同一商品在多个购物车条目中出现时，会合并为一个订单行
+// - 订单金额由服务端根据商品当前价格和优惠券计算，expectedTotal不为空时会校验是否一致
+func (cs *CartService) Checkout(userId int, cartIds []int, couponCode string, expectedTotal string, address string) (*orderModel.Order, error) {
+	cartIds = uniqueIds(cartIds)
+	carts, err := cs.cartRepo.ClaimCarts(userId, cartIds)
+	if err != nil {
+		return nil, err
+	}
+	if len(carts) == 0 {
+		return nil, ErrEmptyCart
+	}
+	if len(cartIds) > 0 && len(carts) != len(cartIds) {
+		cs.restoreCarts(userId, carts)
+		return nil, ErrCartItemNotFound
+	}
+
+	// 将购物车条目转换为订单行
+	items := make([]orderModel.OrderItem, 0, len(carts))
+	for _, cart := range carts {
+		items = append(items, orderModel.OrderItem{CommodityId: cart.CommodityId, Quantity: cart.Quantity})
+	}
+
+	// 创建订单（扣减库存、写入订单、加入延迟取消队列），失败时CreateOrder已自行回滚，这里只需放回购物车条目
+	order, err := cs.orderSvc.CreateOrder(userId, items, couponCode, expectedTotal, address)
+	if err != nil {
+		cs.restoreCarts(userId, carts)
+		return nil, err
+	}
+	return order, nil
+}
+
+// restoreCarts 结算失败时把已领取的购物车条目放回购物车，放回失败只记录日志（不影响结算失败的结果）
+func (cs *CartService) restoreCarts(userId int, carts []*model.Cart) {
+	if err := cs.cartRepo.RestoreCarts(carts); err != nil {
+		log.Errorf("Failed to restore cart items of user %d after checkout failure: %v", userId, err)
+	}
+}
+
+// uniqueIds 对ID列表去重，保持原有顺序
+func uniqueIds(ids []int) []int {
+	seen := make(map[int]struct{}, len(ids))
+	res := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		res = append(res, id)
+	}
+	return res
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"server/config"
+	"server/internal/product/cart/model"
+	"server/internal/product/cart/repository"
+	commodityModel "server/internal/product/commodity/model"
+	commodityRepository "server/internal/product/commodity/repository"
+	commodityService "server/internal/product/commodity/service"
+	orderModel "server/internal/product/order/model"
+	orderRepository "server/internal/product/order/repository"
+	orderService "server/internal/product/order/service"
+	promotionRepository "server/internal/product/promotion/repository"
+	promotionService "server/internal/product/promotion/service"
+	"server/pkg/idgen"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCheckoutFixture 以memory存储模式的仓储构建购物车服务
+// 商品1单价10.00、库存10，商品2单价5.00、库存1；用户1的购物车中有商品1两件、商品2一件、商品1一件，用户2的购物车中有商品1一件
+func newCheckoutFixture(t *testing.T) (*CartService, commodityRepository.CommodityRepository) {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Order.PaymentTimeoutMinutes = 15
+	cfg.Warehouse.AllocationStrategy = commodityService.AllocationPriority
+	cfg.DelayQueue.ProcessingTimeoutSeconds = 300
+
+	commodityStore := commodityRepository.NewMemoryStore()
+	commodityRepo := commodityRepository.NewMemoryCommodityRepository(commodityStore)
+	warehouseRepo := commodityRepository.NewMemoryWarehouseRepository(commodityStore)
+	stockRepo := commodityRepository.NewMemoryStockRepository(commodityStore)
+	stockCacheSvc := commodityService.NewStockCacheService(stockRepo, commodityRepo, warehouseRepo)
+	couponSvc := promotionService.NewCouponService(promotionRepository.NewMemoryCouponRepository(),
+		promotionRepository.NewMemoryCouponUsageRepository(), promotionRepository.NewMemoryCouponCounterRepository())
+
+	orderStore := orderRepository.NewMemoryStore()
+	oRepo := orderRepository.NewMemoryOrderRepository(orderStore)
+	eRepo := orderRepository.NewMemoryOrderEventRepository(orderStore)
+	cancelSvc := orderService.NewOrderCancelService(orderRepository.NewMemoryOrderDQRepository(cfg), oRepo, eRepo, stockRepo, couponSvc, cfg)
+	idGen, err := idgen.NewGenerator(0)
+	require.NoError(t, err)
+	orderSvc := orderService.NewOrderService(oRepo, eRepo, orderRepository.NewMemoryOrderArchiveRepository(orderStore), stockRepo,
+		commodityRepo, stockCacheSvc, commodityService.NewWarehouseService(warehouseRepo, stockCacheSvc, cfg), couponSvc,
+		promotionService.NewFlashSaleService(promotionRepository.NewMemoryFlashSaleRepository()), cancelSvc, idGen, cfg)
+
+	for _, commodity := range []struct {
+		price float64
+		stock int
+	}{{10, 10}, {5, 1}} {
+		c := &commodityModel.Commodity{Name: "commodity", Price: commodity.price, Status: true}
+		require.NoError(t, commodityRepo.CreateCommodity(c))
+		code, _, err := stockRepo.AdjustStock(context.Background(), c.ID, commodityModel.DefaultWarehouseId, commodity.stock, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+	}
+
+	cs := NewCartService(repository.NewMemoryCartRepository(), orderSvc)
+	for _, cart := range []model.Cart{
+		{UserId: 1, CommodityId: 1, Quantity: 2},
+		{UserId: 1, CommodityId: 2, Quantity: 1},
+		{UserId: 1, CommodityId: 1, Quantity: 1},
+		{UserId: 2, CommodityId: 1, Quantity: 1},
+	} {
+		require.NoError(t, cs.AddToCart(cart.UserId, cart.CommodityId, cart.Quantity))
+	}
+	return cs, commodityRepo
+}
+
+func TestCheckout(t *testing.T) {
+	tests := []struct {
+		name          string
+		userId        int
+		cartIds       []int
+		expectedTotal string
+		wantErr       error
+		wantItems     map[int]int // 订单中商品ID到数量的映射
+		wantLeft      []int       // 结算后用户购物车中剩余的条目
+	}{
+		{
+			name:      "whole cart merges rows of the same commodity",
+			userId:    1,
+			wantItems: map[int]int{1: 3, 2: 1},
+			wantLeft:  []int{},
+		},
+		{
+			name:          "selected rows",
+			userId:        1,
+			cartIds:       []int{3, 3},
+			expectedTotal: "10.00",
+			wantItems:     map[int]int{1: 1},
+			wantLeft:      []int{1, 2},
+		},
+		{
+			name:     "row of another user",
+			userId:   1,
+			cartIds:  []int{1, 4},
+			wantErr:  ErrCartItemNotFound,
+			wantLeft: []int{1, 2, 3},
+		},
+		{
+			name:     "empty cart",
+			userId:   3,
+			wantErr:  ErrEmptyCart,
+			wantLeft: []int{},
+		},
+		{
+			name:          "failed order puts the rows back",
+			userId:        1,
+			cartIds:       []int{2, 3},
+			expectedTotal: "1.00",
+			wantErr:       orderService.ErrPriceMismatch,
+			wantLeft:      []int{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, _ := newCheckoutFixture(t)
+			order, err := cs.Checkout(tt.userId, tt.cartIds, "", tt.expectedTotal, "address")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, orderModel.StatusPending, order.Status)
+				items := make(map[int]int, len(order.Items))
+				for _, item := range order.Items {
+					items[item.CommodityId] = item.Quantity
+				}
+				assert.Equal(t, tt.wantItems, items)
+			}
+
+			carts, err := cs.GetCart(tt.userId)
+			require.NoError(t, err)
+			left := make([]int, 0, len(carts))
+			for _, cart := range carts {
+				left = append(left, cart.Id)
+			}
+			assert.Equal(t, tt.wantLeft, left)
+		})
+	}
+}
+
+func TestCheckoutInsufficientStock(t *testing.T) {
+	cs, commodityRepo := newCheckoutFixture(t)
+	require.NoError(t, cs.UpdateCart(1, 2, 2))
+
+	_, err := cs.Checkout(1, nil, "", "", "address")
+	assert.ErrorIs(t, err, orderService.ErrInsufficientStock)
+
+	for commodityId, stock := range map[int]int{1: 10, 2: 1} {
+		commodity, err := commodityRepo.FindCommodityById(commodityId)
+		require.NoError(t, err)
+		assert.Equal(t, stock, commodity.Stock, "no stock is deducted when one commodity is short")
+	}
+	carts, err := cs.GetCart(1)
+	require.NoError(t, err)
+	assert.Len(t, carts, 3, "the cart is left intact")
+
+	_, err = cs.Checkout(1, []int{1, 3}, "", "30.00", "address")
+	require.NoError(t, err, "checking out the rows in stock still works")
+}
@@ -0,0 +1,11 @@
+package service
+
+import "errors"
+
+var (
+	// ErrEmptyCart 购物车为空，没有可结算的商品
+	ErrEmptyCart = errors.New("cart is empty")
+
+	// ErrCartItemNotFound 购物车条目不存在或不属于当前用户
+	ErrCartItemNotFound = errors.New("cart item not found")
+)
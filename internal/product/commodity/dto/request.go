@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 // CreateCommodityRequest 创建商品请求
 type CreateCommodityRequest struct {
 	Name  string  `json:"name" binding:"required"`
@@ -7,9 +9,47 @@ type CreateCommodityRequest struct {
 	Stock int     `json:"stock" binding:"required"`
 }
 
-// UpdateCommodityRequest 更新商品请求
+// UpdateCommodityRequest 更新商品请求（库存通过AdjustStockRequest调整）
 type UpdateCommodityRequest struct {
 	Name  string  `json:"name" binding:"required"`
 	Price float64 `json:"price" binding:"required"`
-	Stock int     `json:"stock" binding:"required"`
+}
+
+// AdjustStockRequest 调整商品库存请求
+type AdjustStockRequest struct {
+	Delta       int    `json:"delta" binding:"required"`          // 带符号的调整量：正数为入库，负数为出库，不能为0
+	Reason      string `json:"reason" binding:"required,max=255"` // 调整原因，记录到库存流水中
+	WarehouseId int    `json:"warehouse_id" binding:"min=0"`      // 调整的仓库，为0时调整默认仓库
+}
+
+// ReconcileStockRequest 管理员触发库存对账请求（Query参数）
+type ReconcileStockRequest struct {
+	Repair bool `form:"repair"` // 是否修复发现的差异，默认只报告
+}
+
+// ListStockMovementRequest 查询商品库存流水请求（Query参数）
+type ListStockMovementRequest struct {
+	WarehouseId int       `form:"warehouse_id" binding:"min=0"`                // 仓库过滤，为0时返回所有仓库
+	Reason      string    `form:"reason"`                                      // 变动原因过滤，为空时返回全部原因
+	StartTime   time.Time `form:"start_time"`                                  // 发生时间下限（RFC3339，包含）
+	EndTime     time.Time `form:"end_time"`                                    // 发生时间上限（RFC3339，不包含）
+	Page        int       `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize    int       `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
+
+// CreateWarehouseRequest 创建仓库请求
+type CreateWarehouseRequest struct {
+	Code     string `json:"code" binding:"required,max=64"`
+	Name     string `json:"name" binding:"required,max=255"`
+	Region   string `json:"region" binding:"max=255"` // 仓库所在地区，按就近策略分配时与收货地址匹配
+	Priority int    `json:"priority"`                 // 优先级，数值越小越优先
+	Enabled  bool   `json:"enabled"`
+}
+
+// UpdateWarehouseRequest 更新仓库请求（仓库编码不允许修改）
+type UpdateWarehouseRequest struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	Region   string `json:"region" binding:"max=255"`
+	Priority int    `json:"priority"`
+	Enabled  bool   `json:"enabled"`
 }
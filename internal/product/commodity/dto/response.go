@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"server/internal/product/commodity/model"
+	"time"
+)
+
 // CommodityResponse 商品响应
 type CommodityResponse struct {
 	ID    int     `json:"id"`
@@ -7,3 +12,59 @@ type CommodityResponse struct {
 	Price float64 `json:"price"`
 	Stock int     `json:"stock"`
 }
+
+// StockDiscrepancyResponse 单个商品在某个仓库的库存差异
+type StockDiscrepancyResponse struct {
+	CommodityId   int    `json:"commodity_id"`
+	WarehouseId   int    `json:"warehouse_id"`
+	DBStock       int    `json:"db_stock"`
+	CacheStock    int    `json:"cache_stock"`
+	PendingDelta  int    `json:"pending_delta"`
+	Missing       bool   `json:"missing"`
+	Repaired      bool   `json:"repaired"`
+	RepairedStock int    `json:"repaired_stock,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// StockReconcileResponse 库存对账汇总
+type StockReconcileResponse struct {
+	Repair        bool                       `json:"repair"`
+	Checked       int                        `json:"checked"`
+	Skipped       []int                      `json:"skipped"`
+	Failed        []int                      `json:"failed"`
+	Repaired      int                        `json:"repaired"`
+	Discrepancies []StockDiscrepancyResponse `json:"discrepancies"`
+	StartedAt     time.Time                  `json:"started_at"`
+	FinishedAt    time.Time                  `json:"finished_at"`
+}
+
+// StockMovementListResponse 库存流水列表响应
+type StockMovementListResponse struct {
+	Movements []*model.StockMovement `json:"movements"`
+}
+
+// AdjustStockResponse 调整商品库存响应
+type AdjustStockResponse struct {
+	CommodityId int `json:"commodity_id"`
+	WarehouseId int `json:"warehouse_id"`
+	Delta       int `json:"delta"`
+	Stock       int `json:"stock"` // 调整后该仓库的可售库存
+}
+
+// WarehouseListResponse 仓库列表响应
+type WarehouseListResponse struct {
+	Warehouses []*model.Warehouse `json:"warehouses"`
+}
+
+// WarehouseStockResponse 商品在某个仓库的库存
+type WarehouseStockResponse struct {
+	Warehouse *model.Warehouse `json:"warehouse"`
+	Stock     int              `json:"stock"`     // MySQL中的库存
+	Available int              `json:"available"` // 当前可售库存（含尚未同步到MySQL的扣减）
+}
+
+// CommodityWarehouseStockResponse 商品在所有仓库的库存响应
+type CommodityWarehouseStockResponse struct {
+	CommodityId int                      `json:"commodity_id"`
+	Stocks      []WarehouseStockResponse `json:"stocks"`
+}
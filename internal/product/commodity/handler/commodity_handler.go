@@ -105,7 +105,7 @@ func (h *CommodityHandler) ListCommodity(c *gin.Context) {
 // 注意：
 // - 更新时间由Service层自动设置为当前时间
 // - 创建时间会被保留，不会被覆盖
-// - 更新库存不会自动同步到Redis，需手动刷新缓存
+// - 库存不能通过此接口修改，需通过/commodity/:id/stock/adjust接口调整
 func (h *CommodityHandler) UpdateCommodity(c *gin.Context) {
 	// 从URL路径参数中获取商品ID（如：/commodity/123）
 	idStr := c.Param("id")
@@ -128,7 +128,6 @@ func (h *CommodityHandler) UpdateCommodity(c *gin.Context) {
 		ID:    id,
 		Name:  req.Name,
 		Price: req.Price,
-		Stock: req.Stock,
 	}
 
 	// 调用Service层更新商品
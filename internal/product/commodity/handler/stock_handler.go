@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"server/internal/product/commodity/dto"
+	"server/internal/product/commodity/model"
+	"server/internal/product/commodity/repository"
+	"server/internal/product/commodity/service"
+	"server/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// StockHandler 处理库存管理相关的HTTP请求
+type StockHandler struct {
+	stockCacheSvc *service.StockCacheService
+	reconcileSvc  *service.StockReconcileService
+	ledgerSvc     *service.StockLedgerService
+}
+
+// NewStockHandler 创建一个新的库存管理处理器实例
+func NewStockHandler(stockCacheSvc *service.StockCacheService, reconcileSvc *service.StockReconcileService, ledgerSvc *service.StockLedgerService) *StockHandler {
+	return &StockHandler{stockCacheSvc: stockCacheSvc, reconcileSvc: reconcileSvc, ledgerSvc: ledgerSvc}
+}
+
+// AdjustStock 处理管理员调整商品库存请求（入库、出库、盘点修正等，仅管理员）
+// 请求体示例：{"delta": 100, "reason": "restock", "warehouse_id": 2}，delta为负数时出库，未指定仓库时调整默认仓库
+// 业务流程：
+// 1. 从URL路径中提取商品ID，解析调整量和原因
+// 2. 调用Service层原子性地调整Redis库存并同步到MySQL
+// 3. 返回调整后的可售库存
+//
+// 注意：
+// - 出库数量超过该仓库当前可售库存时返回CodeInsufficientStock
+// - 调整记录到库存流水中，操作人为当前管理员账号，如admin:alice
+func (h *StockHandler) AdjustStock(c *gin.Context) {
+	commodityId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.AdjustStockRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	if req.WarehouseId == 0 {
+		req.WarehouseId = model.DefaultWarehouseId
+	}
+
+	stock, err := h.stockCacheSvc.AdjustStock(c.Request.Context(), commodityId, req.WarehouseId, req.Delta, req.Reason, "admin:"+c.GetString("account"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStockDelta):
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		case errors.Is(err, service.ErrWarehouseNotFound):
+			response.NotFound(c, response.CodeWarehouseNotFound, "warehouse not found")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.NotFound(c, response.CodeCommodityNotFound, "commodity not found")
+		case errors.Is(err, service.ErrInsufficientStock):
+			response.BadRequest(c, response.CodeInsufficientStock, err.Error())
+		default:
+			log.Error("Failed to adjust stock:", err)
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	response.Success(c, dto.AdjustStockResponse{CommodityId: commodityId, WarehouseId: req.WarehouseId, Delta: req.Delta, Stock: stock})
+}
+
+// ReconcileStock 处理管理员按需触发库存对账请求（仅管理员）
+// Query参数示例：/admin/stock/reconcile?repair=true
+// repair为true时修复发现的差异，否则只返回差异报告
+func (h *StockHandler) ReconcileStock(c *gin.Context) {
+	var req dto.ReconcileStockRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+
+	report, err := h.reconcileSvc.ReconcileStock(c.Request.Context(), req.Repair)
+	if err != nil {
+		log.Error("Failed to reconcile stock:", err)
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	discrepancies := make([]dto.StockDiscrepancyResponse, 0, len(report.Discrepancies))
+	for _, d := range report.Discrepancies {
+		discrepancies = append(discrepancies, dto.StockDiscrepancyResponse{
+			CommodityId:   d.CommodityId,
+			WarehouseId:   d.WarehouseId,
+			DBStock:       d.DBStock,
+			CacheStock:    d.CacheStock,
+			PendingDelta:  d.PendingDelta,
+			Missing:       d.Missing,
+			Repaired:      d.Repaired,
+			RepairedStock: d.RepairedStock,
+			Error:         d.Error,
+		})
+	}
+	log.Infof("Stock reconcile triggered by %s, repair: %t", c.GetString("account"), req.Repair)
+	response.Success(c, dto.StockReconcileResponse{
+		Repair:        report.Repair,
+		Checked:       report.Checked,
+		Skipped:       report.Skipped,
+		Failed:        report.Failed,
+		Repaired:      report.Repaired,
+		Discrepancies: discrepancies,
+		StartedAt:     report.StartedAt,
+		FinishedAt:    report.FinishedAt,
+	})
+}
+
+// ListStockMovements 处理管理员查询商品库存流水请求（仅管理员）
+// Query参数示例：/admin/commodity/12/stock-movements?warehouse_id=1&reason=order_reserve&start_time=2024-01-01T00:00:00Z&page=1&page_size=20
+func (h *StockHandler) ListStockMovements(c *gin.Context) {
+	commodityId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.ListStockMovementRequest
+	if err = c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	query := repository.StockMovementQuery{
+		CommodityId: commodityId,
+		WarehouseId: req.WarehouseId,
+		Reason:      model.StockMovementReason(req.Reason),
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Offset:      (req.Page - 1) * req.PageSize,
+		Limit:       req.PageSize,
+	}
+	movements, total, err := h.ledgerSvc.ListMovements(query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMovementReason) {
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.StockMovementListResponse{Movements: movements}, response.NewPagination(req.Page, req.PageSize, total))
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"server/internal/product/commodity/dto"
+	"server/internal/product/commodity/model"
+	"server/internal/product/commodity/service"
+	"server/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// WarehouseHandler 处理仓库管理相关的HTTP请求（仅管理员）
+type WarehouseHandler struct {
+	wSvc *service.WarehouseService
+}
+
+// NewWarehouseHandler 创建一个新的仓库处理器实例
+func NewWarehouseHandler(wSvc *service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{wSvc: wSvc}
+}
+
+// CreateWarehouse 处理创建仓库请求
+// 请求体示例：{"code": "SH01", "name": "上海仓", "region": "上海", "priority": 10, "enabled": true}
+// 新仓库的库存为0，通过库存调整接口指定warehouse_id入库
+func (h *WarehouseHandler) CreateWarehouse(c *gin.Context) {
+	var req dto.CreateWarehouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	warehouse := &model.Warehouse{
+		Code:     req.Code,
+		Name:     req.Name,
+		Region:   req.Region,
+		Priority: req.Priority,
+		Enabled:  req.Enabled,
+	}
+	if err := h.wSvc.CreateWarehouse(warehouse); err != nil {
+		if errors.Is(err, service.ErrInvalidWarehouse) {
+			response.BadRequest(c, response.CodeInvalidWarehouse, err.Error())
+			return
+		}
+		log.Error("Failed to create warehouse:", err)
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	log.Infof("Warehouse %s created by %s", warehouse.Code, c.GetString("account"))
+	response.Success(c, warehouse)
+}
+
+// UpdateWarehouse 处理更新仓库请求，停用的仓库不再参与下单分配，已分配到该仓库的订单不受影响
+func (h *WarehouseHandler) UpdateWarehouse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.UpdateWarehouseRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	warehouse := &model.Warehouse{
+		Id:       id,
+		Name:     req.Name,
+		Region:   req.Region,
+		Priority: req.Priority,
+		Enabled:  req.Enabled,
+	}
+	if err = h.wSvc.UpdateWarehouse(warehouse); err != nil {
+		if errors.Is(err, service.ErrWarehouseNotFound) {
+			response.NotFound(c, response.CodeWarehouseNotFound, "warehouse not found")
+			return
+		}
+		log.Error("Failed to update warehouse:", err)
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	log.Infof("Warehouse %d updated by %s", id, c.GetString("account"))
+	response.SuccessWithMessage(c, "update success", nil)
+}
+
+// ListWarehouses 处理获取仓库列表请求，按优先级排序，包括已停用的仓库
+func (h *WarehouseHandler) ListWarehouses(c *gin.Context) {
+	warehouses, err := h.wSvc.ListWarehouses()
+	if err != nil {
+		log.Error("Failed to list warehouses:", err)
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+	response.Success(c, dto.WarehouseListResponse{Warehouses: warehouses})
+}
+
+// ListCommodityStocks 处理查询商品在各仓库库存的请求
+func (h *WarehouseHandler) ListCommodityStocks(c *gin.Context) {
+	commodityId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	stocks, err := h.wSvc.ListCommodityStocks(c.Request.Context(), commodityId)
+	if err != nil {
+		log.Error("Failed to list warehouse stocks:", err)
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	result := make([]dto.WarehouseStockResponse, 0, len(stocks))
+	for _, stock := range stocks {
+		result = append(result, dto.WarehouseStockResponse{Warehouse: stock.Warehouse, Stock: stock.Stock, Available: stock.Available})
+	}
+	response.Success(c, dto.CommodityWarehouseStockResponse{CommodityId: commodityId, Stocks: result})
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// FlashSaleCounter 秒杀限购计数，仅在库存存储为database时使用（redis存储时计数保存在Redis中）
+// UserId为0的记录是活动的总已售件数，其余为各用户的已购件数
+type FlashSaleCounter struct {
+	FlashSaleId int `gorm:"primary_key;autoIncrement:false"`
+	UserId      int `gorm:"primary_key;autoIncrement:false"`
+	Quantity    int
+	UpdatedAt   time.Time
+}
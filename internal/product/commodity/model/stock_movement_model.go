@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// StockMovementReason 库存变动原因
+type StockMovementReason string
+
+const (
+	MovementOrderReserve StockMovementReason = "order_reserve" // 下单扣减库存
+	MovementOrderRelease StockMovementReason = "order_release" // 下单失败回滚，归还扣减的库存
+	MovementOrderTimeout StockMovementReason = "order_timeout" // 订单超时未支付，自动取消并归还库存
+	MovementOrderCancel  StockMovementReason = "order_cancel"  // 订单被主动取消，归还库存
+	MovementRefund       StockMovementReason = "refund"        // 退款归还库存
+	MovementManualAdjust StockMovementReason = "manual_adjust" // 手动调整商品库存（入库、出库等，具体原因见Note）
+	MovementSync         StockMovementReason = "sync"          // 将Redis中的库存增量同步到MySQL
+)
+
+// IsValid 判断库存变动原因是否为已定义的值
+func (r StockMovementReason) IsValid() bool {
+	switch r {
+	case MovementOrderReserve, MovementOrderRelease, MovementOrderTimeout, MovementOrderCancel,
+		MovementRefund, MovementManualAdjust, MovementSync:
+		return true
+	}
+	return false
+}
+
+// StockMovement 库存流水模型，每次库存变动追加一条记录，只增不改
+// Quantity为带符号的变动量：负数表示减少，正数表示增加
+// 订单、退款和手动调整记录的是可售库存（Redis）的变化；sync记录的是同步时MySQL库存的变化，可售库存不变
+type StockMovement struct {
+	Id          int     `gorm:"primary_key"`
+	MovementKey *string `gorm:"uniqueIndex;size:32"` // 流水唯一键，由写入方生成，从Redis发件箱重复写入时据此去重；迁移前的存量流水为NULL
+	CommodityId int
+	WarehouseId int                 // 发生变动的仓库
+	Quantity    int                 // 变动量，负数为减少
+	Reason      StockMovementReason // 变动原因
+	OrderNo     string              // 关联的订单号，与订单无关的变动为空
+	Operator    string              // 操作人，如user:12、admin:alice、system
+	Note        string              // 变动说明，如手动调整时填写的原因
+	CreatedAt   time.Time
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// DefaultWarehouseId 默认仓库ID，迁移时由原有的单一库存生成，未指定仓库的旧数据都归属于该仓库
+const DefaultWarehouseId = 1
+
+// Warehouse 仓库模型
+type Warehouse struct {
+	Id        int    `gorm:"primary_key"`
+	Code      string // 仓库编码，唯一
+	Name      string
+	Region    string // 仓库所在地区，按就近策略分配时与收货地址匹配
+	Priority  int    // 优先级，数值越小越优先
+	Enabled   bool   // 停用的仓库不参与下单分配
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WarehouseStock 商品在某个仓库的库存，commodities.stock为所有仓库库存之和
+type WarehouseStock struct {
+	Id          int `gorm:"primary_key"`
+	WarehouseId int `gorm:"uniqueIndex:idx_warehouse_commodity"`
+	CommodityId int `gorm:"uniqueIndex:idx_warehouse_commodity"`
+	Stock       int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
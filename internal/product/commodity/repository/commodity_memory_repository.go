@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	"server/internal/product/commodity/model"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+type memoryCommodityRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryCommodityRepository 创建一个基于进程内存储的商品仓储实例，用于memory存储模式
+func NewMemoryCommodityRepository(store *MemoryStore) CommodityRepository {
+	return &memoryCommodityRepository{store: store}
+}
+
+// CreateCommodity 创建新商品记录，并回写分配的商品ID
+func (cRepo *memoryCommodityRepository) CreateCommodity(commodity *model.Commodity) error {
+	cRepo.store.mu.Lock()
+	defer cRepo.store.mu.Unlock()
+	cRepo.store.lastCommodityId++
+	commodity.ID = cRepo.store.lastCommodityId
+	cRepo.store.commodities[commodity.ID] = *commodity
+	return nil
+}
+
+// DeleteCommodity 根据ID删除商品记录
+func (cRepo *memoryCommodityRepository) DeleteCommodity(id int) error {
+	cRepo.store.mu.Lock()
+	defer cRepo.store.mu.Unlock()
+	if _, ok := cRepo.store.commodities[id]; !ok {
+		return fmt.Errorf("commodity with id=%d not found", id)
+	}
+	delete(cRepo.store.commodities, id)
+	return nil
+}
+
+// UpdateCommodity 更新商品信息，与GORM的Updates一致只更新非零值字段
+func (cRepo *memoryCommodityRepository) UpdateCommodity(commodity *model.Commodity) error {
+	cRepo.store.mu.Lock()
+	defer cRepo.store.mu.Unlock()
+	current, ok := cRepo.store.commodities[commodity.ID]
+	if !ok {
+		return nil
+	}
+	if commodity.Name != "" {
+		current.Name = commodity.Name
+	}
+	if commodity.Price != 0 {
+		current.Price = commodity.Price
+	}
+	if commodity.Stock != 0 {
+		current.Stock = commodity.Stock
+	}
+	if commodity.Status {
+		current.Status = commodity.Status
+	}
+	if !commodity.CreatedAt.IsZero() {
+		current.CreatedAt = commodity.CreatedAt
+	}
+	if !commodity.UpdateAt.IsZero() {
+		current.UpdateAt = commodity.UpdateAt
+	}
+	cRepo.store.commodities[commodity.ID] = current
+	return nil
+}
+
+// FindCommodityById 根据ID查找商品，商品不存在时返回gorm.ErrRecordNotFound
+func (cRepo *memoryCommodityRepository) FindCommodityById(id int) (*model.Commodity, error) {
+	cRepo.store.mu.RLock()
+	defer cRepo.store.mu.RUnlock()
+	commodity, ok := cRepo.store.commodities[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &commodity, nil
+}
+
+// FindCommodityByName 根据名称查找商品
+func (cRepo *memoryCommodityRepository) FindCommodityByName(name string) ([]*model.Commodity, error) {
+	commodities := cRepo.listCommodities(func(commodity *model.Commodity) bool { return commodity.Name == name })
+	return commodities, nil
+}
+
+// ListCommodity 获取所有商品列表
+func (cRepo *memoryCommodityRepository) ListCommodity() ([]*model.Commodity, error) {
+	return cRepo.listCommodities(func(*model.Commodity) bool { return true }), nil
+}
+
+// listCommodities 按ID升序返回满足条件的商品
+func (cRepo *memoryCommodityRepository) listCommodities(match func(commodity *model.Commodity) bool) []*model.Commodity {
+	cRepo.store.mu.RLock()
+	defer cRepo.store.mu.RUnlock()
+	commodities := make([]*model.Commodity, 0)
+	for _, commodity := range cRepo.store.commodities {
+		if match(&commodity) {
+			commodity := commodity
+			commodities = append(commodities, &commodity)
+		}
+	}
+	sort.Slice(commodities, func(i, j int) bool { return commodities[i].ID < commodities[j].ID })
+	return commodities
+}
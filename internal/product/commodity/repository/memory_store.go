@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"server/internal/product/commodity/model"
+	"sync"
+	"time"
+)
+
+// warehouseStockKey 仓库库存在内存存储中的key
+type warehouseStockKey struct {
+	commodityId int
+	warehouseId int
+}
+
+// flashSaleCounterKey 秒杀计数在内存存储中的key，userId为0表示活动的总已售件数
+type flashSaleCounterKey struct {
+	flashSaleId int
+	userId      int
+}
+
+// MemoryStore 商品模块的进程内存储，memory存储模式下由商品、仓库、库存和库存流水的内存仓储共享
+// 所有读写使用同一把锁，库存扣减与商品总库存的更新在锁内一并完成，保证commodities.stock始终等于各仓库库存之和
+// 数据只保存在进程内，重启后丢失
+type MemoryStore struct {
+	mu                sync.RWMutex
+	commodities       map[int]model.Commodity
+	warehouses        map[int]model.Warehouse
+	warehouseStocks   map[warehouseStockKey]model.WarehouseStock
+	flashSaleCounters map[flashSaleCounterKey]int
+	movements         []model.StockMovement
+
+	lastCommodityId      int
+	lastWarehouseId      int
+	lastWarehouseStockId int
+	lastMovementId       int
+}
+
+// NewMemoryStore 创建商品模块的进程内存储，并创建ID为DefaultWarehouseId的默认仓库（对应数据库迁移时创建的默认仓库）
+func NewMemoryStore() *MemoryStore {
+	now := time.Now()
+	return &MemoryStore{
+		commodities: make(map[int]model.Commodity),
+		warehouses: map[int]model.Warehouse{
+			model.DefaultWarehouseId: {
+				Id:        model.DefaultWarehouseId,
+				Code:      "default",
+				Name:      "默认仓库",
+				Enabled:   true,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+		},
+		warehouseStocks:   make(map[warehouseStockKey]model.WarehouseStock),
+		flashSaleCounters: make(map[flashSaleCounterKey]int),
+		lastWarehouseId:   model.DefaultWarehouseId,
+	}
+}
+
+// addWarehouseStock 按带符号的数量修改仓库库存（没有记录时创建）和商品总库存，调用方需持有写锁
+func (s *MemoryStore) addWarehouseStock(commodityId int, warehouseId int, delta int) {
+	now := time.Now()
+	key := warehouseStockKey{commodityId: commodityId, warehouseId: warehouseId}
+	stock, ok := s.warehouseStocks[key]
+	if !ok {
+		s.lastWarehouseStockId++
+		stock = model.WarehouseStock{
+			Id:          s.lastWarehouseStockId,
+			WarehouseId: warehouseId,
+			CommodityId: commodityId,
+			CreatedAt:   now,
+		}
+	}
+	stock.Stock += delta
+	stock.UpdatedAt = now
+	s.warehouseStocks[key] = stock
+
+	if commodity, ok := s.commodities[commodityId]; ok {
+		commodity.Stock += delta
+		s.commodities[commodityId] = commodity
+	}
+}
+
+// appendMovements 追加库存流水并回写分配的流水ID，调用方需持有写锁
+func (s *MemoryStore) appendMovements(movements []*model.StockMovement) {
+	now := time.Now()
+	for _, movement := range movements {
+		if movement.CreatedAt.IsZero() {
+			movement.CreatedAt = now
+		}
+		s.lastMovementId++
+		movement.Id = s.lastMovementId
+		s.movements = append(s.movements, *movement)
+	}
+}
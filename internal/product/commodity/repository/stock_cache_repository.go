@@ -2,39 +2,130 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"server/internal/product/commodity/model"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// 可配置的库存存储（config.Stock.Backend）
+const (
+	StockBackendRedis    = "redis"    // Redis缓存扣减，增量定时同步到MySQL
+	StockBackendDatabase = "database" // 直接在数据库中扣减，不依赖Redis
+)
+
+// deltaKeyPrefix 库存增量key的前缀
+const deltaKeyPrefix = "delta_key_"
+
+// movementOutboxKey 库存流水发件箱的Redis key（List）
+// 扣减、归还和调整库存的Lua脚本在修改库存的同时RPUSH序列化后的流水，由同步调度器通过FlushMovements写入数据库
+const movementOutboxKey = "stock_movement_outbox"
+
 // getStockCacheKey 生成库存缓存的Redis key
-// 格式：stock_key_{商品ID}
-// 例如：stock_key_123 表示商品ID为123的库存
-// 用途：存储商品的实时库存数量
-func getStockCacheKey(commodityId int) string {
-	return "stock_key_" + strconv.Itoa(commodityId)
+// 格式：stock_key_{商品ID}_{仓库ID}
+// 例如：stock_key_123_2 表示商品ID为123的商品在仓库2的库存
+// 用途：存储商品在某个仓库的实时库存数量
+func getStockCacheKey(commodityId int, warehouseId int) string {
+	return "stock_key_" + strconv.Itoa(commodityId) + "_" + strconv.Itoa(warehouseId)
 }
 
 // getDeltaCacheKey 生成库存增量的Redis key
-// 格式：delta_key_{商品ID}
-// 例如：delta_key_123 表示商品ID为123的库存变化量
+// 格式：delta_key_{商品ID}_{仓库ID}
+// 例如：delta_key_123_2 表示商品ID为123的商品在仓库2的库存变化量
 // 用途：存储Redis与MySQL之间的库存差值，用于异步同步
-func getDeltaCacheKey(commodityId int) string {
-	return "delta_key_" + strconv.Itoa(commodityId)
+func getDeltaCacheKey(commodityId int, warehouseId int) string {
+	return deltaKeyPrefix + strconv.Itoa(commodityId) + "_" + strconv.Itoa(warehouseId)
+}
+
+// ParseDeltaKey 从库存增量key（delta_key_{商品ID}_{仓库ID}）中解析商品ID和仓库ID
+func ParseDeltaKey(key string) (int, int, error) {
+	parts := strings.Split(strings.TrimPrefix(key, deltaKeyPrefix), "_")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid delta key %q", key)
+	}
+	commodityId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delta key %q: %w", key, err)
+	}
+	warehouseId, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid delta key %q: %w", key, err)
+	}
+	return commodityId, warehouseId, nil
+}
+
+// getStockLockKey 生成商品库存锁的Redis key
+// 格式：stock_lock_{商品ID}（同一商品的所有仓库共用一把锁）
+// 用途：库存同步与对账互斥，避免对账读到同步进行到一半的中间状态
+func getStockLockKey(commodityId int) string {
+	return "stock_lock_" + strconv.Itoa(commodityId)
 }
 
+// getFlashSaleSoldKey 生成秒杀活动已售件数的Redis key
+// 格式：flash_sale_sold_{秒杀活动ID}
+func getFlashSaleSoldKey(flashSaleId int) string {
+	return "flash_sale_sold_" + strconv.Itoa(flashSaleId)
+}
+
+// getFlashSaleUserKey 生成用户在秒杀活动中已购件数的Redis key
+// 格式：flash_sale_user_{秒杀活动ID}_{用户ID}
+func getFlashSaleUserKey(flashSaleId int, userId int) string {
+	return "flash_sale_user_" + strconv.Itoa(flashSaleId) + "_" + strconv.Itoa(userId)
+}
+
+// StockSnapshot 某一时刻Redis中商品在某个仓库的库存缓存快照
+type StockSnapshot struct {
+	Exists bool // stock_key是否存在
+	Stock  int  // Redis中的实时库存
+	Delta  int  // 尚未同步到MySQL的库存增量
+}
+
+// StockItem 批量扣减/归还库存时的单个商品条目
+type StockItem struct {
+	CommodityId int            // 商品ID
+	WarehouseId int            // 仓库ID
+	Quantity    int            // 数量
+	Limit       *PurchaseLimit // 秒杀限购条件，为nil时只检查库存
+}
+
+// PurchaseLimit 秒杀商品的限购条件，扣减库存时在同一Lua脚本中检查并累加限购计数器，归还库存时同时归还计数
+// 计数器不存在时按0计算，过期时间为ExpireAt
+type PurchaseLimit struct {
+	FlashSaleId  int       // 秒杀活动ID
+	UserId       int       // 下单用户ID
+	PerUserLimit int       // 每个用户可购买的件数，0表示不限（归还时不使用）
+	Quota        int       // 活动总配额，0表示不限（归还时不使用）
+	ExpireAt     time.Time // 计数器过期时间（归还时不使用）
+}
+
+// StockCacheRepository 库存仓储接口
+// 带MovementInfo参数的操作在修改库存的同一原子操作中记录库存流水，库存变动成功时流水一定不会丢失
 type StockCacheRepository interface {
-	InitStockCache(ctx context.Context, commodityId int, stock int) error          // 初始化商品库存缓存
-	DecreaseStock(ctx context.Context, commodityId int, quantity int) (int, error) // 扣减库存（原子操作）
-	IncreaseStock(ctx context.Context, commodityId int, quantity int) error        // 增加库存（用于归还）
-	SyncStock(ctx context.Context, commodityId int) error                          // 同步库存增量到数据库
-	GetAllDeltaKey(ctx context.Context) ([]string, error)                          // 获取所有有变化的库存key
-	GetDeltaValue(ctx context.Context, key string) (int, error)                    // 获取库存增量值
+	InitStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) error                                  // 初始化商品库存缓存（已存在时不覆盖）
+	DecreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) (int, error)                         // 扣减库存（原子操作，不记录流水）
+	DecreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) (int, int, error)                    // 批量扣减多个商品库存并检查秒杀限购（全部成功或全部失败）
+	IncreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) error                                // 增加库存（用于归还，不记录流水）
+	IncreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) error                                // 批量归还多个商品库存（原子操作）
+	AdjustStock(ctx context.Context, commodityId int, warehouseId int, delta int, movement *MovementInfo) (int, int, error) // 按带符号的调整量原子性地修改库存，返回码和调整后的库存
+	SyncStock(ctx context.Context, commodityId int, warehouseId int, movement *MovementInfo) (int, error)                   // 同步库存增量到数据库，返回同步的增量
+	SyncStockBatch(ctx context.Context, keys []StockItem, movement *MovementInfo) ([]StockItem, error)                      // 批量同步多个商品仓库的库存增量到数据库（一个事务），返回同步的条目
+	FlushMovements(ctx context.Context, limit int) (int, error)                                                             // 将发件箱中最多limit条库存流水写入数据库，返回写入的条数
+	GetAllDeltaKey(ctx context.Context) ([]string, error)                                                                   // 获取所有有变化的库存key
+	GetStockSnapshot(ctx context.Context, commodityId int, warehouseId int) (*StockSnapshot, error)                         // 原子性读取库存缓存和未同步的增量
+	RepairStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) (int, error)                         // 按MySQL库存修复库存缓存，返回修复后的缓存库存
+	LockStock(ctx context.Context, commodityId int, ttl time.Duration) (string, bool, error)                                // 获取商品库存锁，返回锁令牌
+	UnlockStock(ctx context.Context, commodityId int, token string) error                                                   // 释放商品库存锁（只释放自己持有的锁）
+	LockStockBatch(ctx context.Context, commodityIds []int, ttl time.Duration) (string, []int, error)                       // 批量获取商品库存锁，返回锁令牌和获取成功的商品ID
+	UnlockStockBatch(ctx context.Context, commodityIds []int, token string) error                                           // 批量释放商品库存锁
 }
 
 type redisCommodityRepository struct {
@@ -53,17 +144,35 @@ func NewRedisCommodityRepository(cRedisRepo *redis.Client, cRepo *gorm.DB) Stock
 // delta_key_商品ID：存储Redis与MySQL之间的库存差值，初始值为0
 // 创建delta_key目的是为了不影响主动修改库存的业务场景
 // 例如：管理员手动调整库存时，不应影响订单扣减的库存同步逻辑
-func (rRepo *redisCommodityRepository) InitStockCache(ctx context.Context, commodityId int, stock int) error {
-	if err := rRepo.cRedisRepo.Set(ctx, getStockCacheKey(commodityId), stock, time.Hour*24).Err(); err != nil {
+//
+// 注意：
+// - 仅在stock_key不存在时写入（与SETNX语义一致），并发初始化时只有第一次生效，不会覆盖已被扣减的库存
+// - 写入值为MySQL库存减去尚未同步的增量，避免delta_key残留时重复计入已扣减的数量
+// - stock_key不设置过期时间，由启动预热和商品变更保持与MySQL一致
+func (rRepo *redisCommodityRepository) InitStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) error {
+	luaScript := `
+	if redis.call("EXISTS", KEYS[1]) == 1 then
+		return 0
+	end
+	local delta = tonumber(redis.call("GET", KEYS[2])) or 0
+	redis.call("SET", KEYS[1], tonumber(ARGV[1]) - delta)
+	return 1
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, []string{getStockCacheKey(commodityId, warehouseId), getDeltaCacheKey(commodityId, warehouseId)}, stock).Result()
+	if err != nil {
 		log.Error("Failed to initialize stock cache:", err)
 		return err
 	}
-	log.Debug("Initialized stock cache for commodity ID", commodityId, "with stock", stock)
+	if result.(int64) == 0 {
+		log.Debug("Stock cache already initialized for commodity ID ", commodityId)
+		return nil
+	}
+	log.Debug("Initialized stock cache for commodity ID ", commodityId, " with stock ", stock)
 	return nil
 }
 
 // DecreaseStock 使用Lua脚本原子性地扣减库存，防止超卖
-func (rRepo *redisCommodityRepository) DecreaseStock(ctx context.Context, commodityId int, quantity int) (int, error) {
+func (rRepo *redisCommodityRepository) DecreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) (int, error) {
 	if rRepo == nil || rRepo.cRedisRepo == nil {
 		panic("redis repository is nil")
 	}
@@ -71,8 +180,8 @@ func (rRepo *redisCommodityRepository) DecreaseStock(ctx context.Context, commod
 		log.Warning("Invalid quantity:", quantity)
 		return -1, fmt.Errorf("invalid quantity %d", quantity)
 	}
-	stockKey := getStockCacheKey(commodityId)
-	deltaKey := getDeltaCacheKey(commodityId)
+	stockKey := getStockCacheKey(commodityId, warehouseId)
+	deltaKey := getDeltaCacheKey(commodityId, warehouseId)
 
 	luaScript := `
 	local stock_key = KEYS[1]
@@ -127,14 +236,188 @@ func (rRepo *redisCommodityRepository) DecreaseStock(ctx context.Context, commod
 	return 0, nil
 }
 
+// DecreaseStockBatch 使用Lua脚本原子性地扣减多个商品的库存（全部成功或全部失败）
+// 脚本先检查所有商品的缓存、限购和库存，全部满足后才统一扣减，任一商品不满足则不做任何修改
+// 带限购条件（秒杀）的商品在同一脚本中检查用户已购件数和活动已售件数，扣减成功后一起累加
+// 扣减成功时在同一脚本中把库存流水写入发件箱
+//
+// 返回值：
+// - int: 返回码，0-3与DecreaseStock一致（0成功、1 Redis执行失败、2缓存未初始化、3库存不足），4用户限购已达上限，5秒杀配额已售完
+// - int: 导致失败的商品ID（返回码为2-5时有效），调用方可据此初始化缓存后重试
+//
+// 注意：items中的商品ID不能重复，重复的商品行应由调用方先合并数量
+func (rRepo *redisCommodityRepository) DecreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) (int, int, error) {
+	if len(items) == 0 {
+		return -1, 0, fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return -1, item.CommodityId, fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	keys, args, err := stockBatchScriptArgs(items, movement, -1)
+	if err != nil {
+		return 1, 0, err
+	}
+
+	luaScript := `
+	local n = tonumber(ARGV[1])
+	local items = {}
+	local k = 1
+	for i = 1, n do
+		local base = (i - 1) * 5 + 1
+		local item = {
+			quantity = tonumber(ARGV[base + 1]),
+			limited = ARGV[base + 2] == "1",
+			user_limit = tonumber(ARGV[base + 3]),
+			quota = tonumber(ARGV[base + 4]),
+			expire_at = tonumber(ARGV[base + 5]),
+			stock_key = KEYS[k],
+			delta_key = KEYS[k + 1],
+		}
+		k = k + 2
+		if item.limited then
+			item.sold_key = KEYS[k]
+			item.user_key = KEYS[k + 1]
+			k = k + 2
+		end
+		items[i] = item
+	end
+	-- 第一轮：检查所有商品的缓存
+	for i = 1, n do
+		items[i].stock = tonumber(redis.call("GET", items[i].stock_key))
+		if not items[i].stock then
+			return {-2, i}
+		end
+	end
+	-- 第二轮：检查秒杀商品的用户限购和活动配额
+	for i = 1, n do
+		local item = items[i]
+		if item.limited then
+			local user_bought = tonumber(redis.call("GET", item.user_key)) or 0
+			if item.user_limit > 0 and user_bought + item.quantity > item.user_limit then
+				return {-4, i}
+			end
+			local sold = tonumber(redis.call("GET", item.sold_key)) or 0
+			if item.quota > 0 and sold + item.quantity > item.quota then
+				return {-5, i}
+			end
+		end
+	end
+	-- 第三轮：检查所有商品的库存
+	for i = 1, n do
+		if items[i].stock < items[i].quantity then
+			return {-3, i}
+		end
+	end
+	-- 全部满足后统一扣减
+	for i = 1, n do
+		local item = items[i]
+		redis.call("DECRBY", item.stock_key, item.quantity)
+		redis.call("INCRBY", item.delta_key, item.quantity)
+		redis.call("EXPIRE", item.delta_key, 86400)
+		if item.limited then
+			redis.call("INCRBY", item.sold_key, item.quantity)
+			redis.call("EXPIREAT", item.sold_key, item.expire_at)
+			redis.call("INCRBY", item.user_key, item.quantity)
+			redis.call("EXPIREAT", item.user_key, item.expire_at)
+		end
+	end
+	for i = 1, n do
+		local movement = ARGV[n * 5 + 1 + i]
+		if movement ~= "" then
+			redis.call("RPUSH", KEYS[#KEYS], movement)
+		end
+	end
+	return {0, 0}
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, keys, args...).Result()
+	if err != nil {
+		log.Error("Failed to decrease stock batch:", err)
+		return 1, 0, err
+	}
+
+	res := result.([]interface{})
+	code, idx := res[0].(int64), res[1].(int64)
+	switch code {
+	case -2:
+		commodityId := items[idx-1].CommodityId
+		log.Warning("Stock cache not initialized for commodity ID", commodityId)
+		return 2, commodityId, fmt.Errorf("stock cache not initialized")
+	case -3:
+		commodityId := items[idx-1].CommodityId
+		log.Warning("Insufficient stock for commodity ID", commodityId)
+		return 3, commodityId, fmt.Errorf("insufficient stock")
+	case -4:
+		commodityId := items[idx-1].CommodityId
+		log.Info("Flash sale per-user limit reached for commodity ID ", commodityId, ", user ", items[idx-1].Limit.UserId)
+		return 4, commodityId, fmt.Errorf("flash sale per-user limit reached")
+	case -5:
+		commodityId := items[idx-1].CommodityId
+		log.Info("Flash sale quota sold out for commodity ID", commodityId)
+		return 5, commodityId, fmt.Errorf("flash sale sold out")
+	}
+
+	log.Debug("Decreased stock batch for ", len(items), " commodities")
+	return 0, 0, nil
+}
+
+// stockBatchScriptArgs 生成批量扣减/归还库存脚本的KEYS和ARGV
+// KEYS：每个商品依次为stock_key、delta_key，带限购条件的商品再追加已售件数和用户已购件数的key，最后为流水发件箱的key
+// ARGV：第一个参数为商品数，之后每个商品5个参数：数量、是否限购（1/0）、用户限购件数、活动配额、计数器过期时间（Unix秒），
+// 最后每个商品一条序列化后的库存流水（movement为nil时为空字符串），变动量为数量乘以sign
+func stockBatchScriptArgs(items []StockItem, movement *MovementInfo, sign int) ([]string, []interface{}, error) {
+	movements, err := encodeMovements(movement, items, sign)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys := make([]string, 0, len(items)*4+1)
+	args := make([]interface{}, 0, len(items)*6+1)
+	args = append(args, len(items))
+	for _, item := range items {
+		keys = append(keys, getStockCacheKey(item.CommodityId, item.WarehouseId), getDeltaCacheKey(item.CommodityId, item.WarehouseId))
+		if item.Limit == nil {
+			args = append(args, item.Quantity, 0, 0, 0, 0)
+			continue
+		}
+		keys = append(keys, getFlashSaleSoldKey(item.Limit.FlashSaleId), getFlashSaleUserKey(item.Limit.FlashSaleId, item.Limit.UserId))
+		args = append(args, item.Quantity, 1, item.Limit.PerUserLimit, item.Limit.Quota, item.Limit.ExpireAt.Unix())
+	}
+	keys = append(keys, movementOutboxKey)
+	args = append(args, movements...)
+	return keys, args, nil
+}
+
+// encodeMovements 为每个条目生成一条序列化后的库存流水，作为Lua脚本写入发件箱的参数；movement为nil时每个条目为空字符串
+func encodeMovements(movement *MovementInfo, items []StockItem, sign int) ([]interface{}, error) {
+	encoded := make([]interface{}, len(items))
+	movements, err := newMovements(movement, items, sign)
+	if err != nil {
+		return nil, err
+	}
+	for i := range encoded {
+		encoded[i] = ""
+		if movements == nil {
+			continue
+		}
+		data, err := json.Marshal(movements[i])
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = string(data)
+	}
+	return encoded, nil
+}
+
 // IncreaseStock 使用Lua脚本原子性地增加库存（用于订单取消）
-func (rRepo *redisCommodityRepository) IncreaseStock(ctx context.Context, commodityId int, quantity int) error {
+func (rRepo *redisCommodityRepository) IncreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) error {
 	if quantity <= 0 {
 		log.Warning("Invalid quantity:", quantity)
 		return fmt.Errorf("invalid quantity %d", quantity)
 	}
-	stockKey := getStockCacheKey(commodityId)
-	deltaKey := getDeltaCacheKey(commodityId)
+	stockKey := getStockCacheKey(commodityId, warehouseId)
+	deltaKey := getDeltaCacheKey(commodityId, warehouseId)
 
 	luaScript := `
 	local stock_key = KEYS[1]
@@ -162,9 +445,141 @@ func (rRepo *redisCommodityRepository) IncreaseStock(ctx context.Context, commod
 	return nil
 }
 
-// SyncStock 将Redis中的库存增量同步到MySQL数据库
-func (rRepo *redisCommodityRepository) SyncStock(ctx context.Context, commodityId int) error {
-	deltaKey := getDeltaCacheKey(commodityId)
+// IncreaseStockBatch 使用Lua脚本原子性地归还多个商品的库存（用于多商品订单取消）
+// 带限购条件（秒杀）的商品同时归还活动已售件数和用户已购件数，计数器不会减到0以下
+// 任一商品缓存未初始化时不做任何修改，调用方可稍后重试；归还成功时在同一脚本中把库存流水写入发件箱
+func (rRepo *redisCommodityRepository) IncreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	keys, args, err := stockBatchScriptArgs(items, movement, 1)
+	if err != nil {
+		return err
+	}
+
+	luaScript := `
+	local n = tonumber(ARGV[1])
+	local items = {}
+	local k = 1
+	for i = 1, n do
+		local base = (i - 1) * 5 + 1
+		local item = {
+			quantity = tonumber(ARGV[base + 1]),
+			limited = ARGV[base + 2] == "1",
+			stock_key = KEYS[k],
+			delta_key = KEYS[k + 1],
+		}
+		k = k + 2
+		if item.limited then
+			item.sold_key = KEYS[k]
+			item.user_key = KEYS[k + 1]
+			k = k + 2
+		end
+		items[i] = item
+	end
+	for i = 1, n do
+		if redis.call("EXISTS", items[i].stock_key) == 0 then
+			return i
+		end
+	end
+	for i = 1, n do
+		local item = items[i]
+		redis.call("INCRBY", item.stock_key, item.quantity)
+		redis.call("DECRBY", item.delta_key, item.quantity)
+		if item.limited then
+			for _, key in ipairs({item.sold_key, item.user_key}) do
+				local value = tonumber(redis.call("GET", key))
+				if value then
+					redis.call("DECRBY", key, math.min(value, item.quantity))
+				end
+			end
+		end
+	end
+	for i = 1, n do
+		local movement = ARGV[n * 5 + 1 + i]
+		if movement ~= "" then
+			redis.call("RPUSH", KEYS[#KEYS], movement)
+		end
+	end
+	return 0
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, keys, args...).Result()
+	if err != nil {
+		log.Error("Failed to increase stock batch:", err)
+		return err
+	}
+	if idx := result.(int64); idx > 0 {
+		commodityId := items[idx-1].CommodityId
+		log.Warning("Stock cache not initialized for commodity ID", commodityId)
+		return fmt.Errorf("stock cache not initialized for commodity %d", commodityId)
+	}
+	log.Debug("Increased stock batch for ", len(items), " commodities")
+	return nil
+}
+
+// AdjustStock 使用Lua脚本原子性地按带符号的调整量修改库存（用于入库、出库等手动调整）
+// stock_key增加delta的同时delta_key减少delta，保持 stock_key + delta_key = MySQL库存，由SyncStock写回MySQL
+// 出库时在脚本内检查调整后的库存不小于0，与订单扣减串行执行，不会因调整导致超卖
+// 调整成功时在同一脚本中把库存流水写入发件箱
+//
+// 返回值：
+// - int: 返回码（0成功、1 Redis执行失败、2缓存未初始化、3可售库存不足以出库）
+// - int: 成功时为调整后的库存，返回码为3时为当前库存
+func (rRepo *redisCommodityRepository) AdjustStock(ctx context.Context, commodityId int, warehouseId int, delta int, movement *MovementInfo) (int, int, error) {
+	if delta == 0 {
+		return -1, 0, fmt.Errorf("invalid delta %d", delta)
+	}
+	movements, err := encodeMovements(movement, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: delta}}, 1)
+	if err != nil {
+		return 1, 0, err
+	}
+	luaScript := `
+	local current_stock = tonumber(redis.call("GET", KEYS[1]))
+	if not current_stock then
+		return {-2, 0}
+	end
+	local delta = tonumber(ARGV[1])
+	if current_stock + delta < 0 then
+		return {-3, current_stock}
+	end
+	redis.call("INCRBY", KEYS[1], delta)
+	redis.call("DECRBY", KEYS[2], delta)
+	redis.call("EXPIRE", KEYS[2], 86400)
+	if ARGV[2] ~= "" then
+		redis.call("RPUSH", KEYS[3], ARGV[2])
+	end
+	return {0, current_stock + delta}
+`
+	keys := []string{getStockCacheKey(commodityId, warehouseId), getDeltaCacheKey(commodityId, warehouseId), movementOutboxKey}
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, keys, delta, movements[0]).Result()
+	if err != nil {
+		log.Error("Failed to adjust stock:", err)
+		return 1, 0, err
+	}
+	res := result.([]interface{})
+	code, stock := res[0].(int64), int(res[1].(int64))
+	switch code {
+	case -2:
+		log.Warning("Stock cache not initialized for commodity ID", commodityId)
+		return 2, 0, fmt.Errorf("stock cache not initialized")
+	case -3:
+		log.Warning("Insufficient stock to adjust for commodity ID", commodityId)
+		return 3, stock, fmt.Errorf("insufficient stock")
+	}
+	log.Debug("Adjusted stock for commodity ID ", commodityId, " by ", delta)
+	return 0, stock, nil
+}
+
+// SyncStock 将Redis中商品在某个仓库的库存增量同步到MySQL数据库（仓库库存和商品总库存），并在同一事务中写入库存流水
+// 返回本次同步的增量（MySQL库存减少的数量），没有需要同步的增量或同步失败时返回0
+func (rRepo *redisCommodityRepository) SyncStock(ctx context.Context, commodityId int, warehouseId int, movement *MovementInfo) (int, error) {
+	deltaKey := getDeltaCacheKey(commodityId, warehouseId)
 	// 获取并重置库存增量
 	luaScript := `
 	local delta_key = KEYS[1]
@@ -187,45 +602,265 @@ func (rRepo *redisCommodityRepository) SyncStock(ctx context.Context, commodityI
 	if err != nil {
 		// Redis执行失败
 		log.Error("Failed to execute sync stock Lua script:", err)
-		return err
+		return 0, err
 	}
 
 	if delta.(int64) == -1 {
 		// delta_key不存在：商品从未有订单扣减库存
 		// 处理方式：无需同步，直接返回
 		log.Debug("No delta to sync for commodity ID", commodityId)
-		return nil
+		return 0, nil
 	}
 
 	if delta.(int64) == 0 {
 		// delta为0：上次同步后没有新的库存变化
 		// 处理方式：无需同步，避免无效的数据库UPDATE操作
 		log.Debug("Delta is zero, no need to sync for commodity ID", commodityId)
-		return nil
+		return 0, nil
 	}
 
-	// 同步到数据库
-	result := rRepo.cRepo.Model(&model.Commodity{}).Where("id = ?", commodityId).Update("stock", gorm.Expr("stock - ?", delta))
+	// 在同一事务中同步到仓库库存和商品总库存
+	d := int(delta.(int64))
+	items := []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: d}}
+	missing, err := rRepo.applyStockDeltas(items, movement)
+	if err == nil && len(missing) > 0 {
+		log.Warning("Commodity not found in database for ID", commodityId)
+		err = fmt.Errorf("commodity with id=%d not found", commodityId)
+	}
+	if err != nil {
+		// 同步失败，把增量加回delta_key，下次同步时重试
+		rRepo.restoreDeltas(ctx, items)
+		log.Error("Failed to sync stock to database:", err)
+		return 0, err
+	}
 
-	if result.RowsAffected == 0 {
-		rRepo.cRedisRepo.IncrBy(ctx, deltaKey, delta.(int64))
+	return d, nil
+}
 
-		log.Warning("Commodity not found in database for ID", commodityId)
-		return fmt.Errorf("commodity with id=%d not found", commodityId)
+// SyncStockBatch 将多个商品仓库的库存增量批量同步到MySQL数据库，返回实际同步的条目（Quantity为同步的增量）
+// 业务流程：
+// 1. 一次Lua调用原子性地读取并重置所有delta_key，跳过增量为0或不存在的key
+// 2. 在一个事务中用一条多行UPDATE更新商品总库存、一条多行INSERT ... ON DUPLICATE KEY UPDATE更新仓库库存，并写入同步的库存流水
+// 3. 事务失败时通过一次pipeline把所有增量加回delta_key，下次同步时重试
+//
+// 注意：数据库中已不存在的商品不参与同步，其增量同样加回delta_key
+func (rRepo *redisCommodityRepository) SyncStockBatch(ctx context.Context, keys []StockItem, movement *MovementInfo) ([]StockItem, error) {
+	if len(keys) == 0 {
+		return nil, nil
 	}
-	if result.Error != nil {
-		rRepo.cRedisRepo.IncrBy(ctx, deltaKey, delta.(int64))
-		log.Error("Failed to sync stock to database:", result.Error)
-		return result.Error
+	deltaKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		deltaKeys = append(deltaKeys, getDeltaCacheKey(key.CommodityId, key.WarehouseId))
+	}
+	luaScript := `
+	local deltas = {}
+	for i = 1, #KEYS do
+		local delta = tonumber(redis.call("GET", KEYS[i])) or 0
+		if delta ~= 0 then
+			redis.call("SET", KEYS[i], 0)
+			redis.call("EXPIRE", KEYS[i], 86400)
+		end
+		deltas[i] = delta
+	end
+	return deltas
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, deltaKeys).Result()
+	if err != nil {
+		log.Error("Failed to execute sync stock batch Lua script:", err)
+		return nil, err
 	}
 
-	return nil
+	items := make([]StockItem, 0, len(keys))
+	for i, value := range result.([]interface{}) {
+		if delta := int(value.(int64)); delta != 0 {
+			items = append(items, StockItem{CommodityId: keys[i].CommodityId, WarehouseId: keys[i].WarehouseId, Quantity: delta})
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	missing, err := rRepo.applyStockDeltas(items, movement)
+	if err != nil {
+		rRepo.restoreDeltas(ctx, items)
+		log.Error("Failed to sync stock batch to database:", err)
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return items, nil
+	}
+	rRepo.restoreDeltas(ctx, missing)
+	log.Warning("Commodities not found in database, deltas restored: ", missing)
+	missingSet := make(map[int]bool, len(missing))
+	for _, item := range missing {
+		missingSet[item.CommodityId] = true
+	}
+	synced := make([]StockItem, 0, len(items)-len(missing))
+	for _, item := range items {
+		if !missingSet[item.CommodityId] {
+			synced = append(synced, item)
+		}
+	}
+	return synced, nil
+}
+
+// applyStockDeltas 在一个事务中将库存增量写入数据库：商品总库存减去各仓库增量之和，仓库库存减去对应增量
+// 仓库库存记录不存在时（如首次向该仓库入库）插入一条；每个写入的条目在同一事务中记录一条MySQL库存减少增量的流水
+// 返回数据库中已不存在的商品的条目，这些条目不会写入
+func (rRepo *redisCommodityRepository) applyStockDeltas(items []StockItem, movement *MovementInfo) ([]StockItem, error) {
+	missing := make([]StockItem, 0)
+	err := rRepo.cRepo.Transaction(func(tx *gorm.DB) error {
+		ids := make([]int, 0, len(items))
+		totals := make(map[int]int, len(items))
+		for _, item := range items {
+			if _, ok := totals[item.CommodityId]; !ok {
+				ids = append(ids, item.CommodityId)
+			}
+			totals[item.CommodityId] += item.Quantity
+		}
+		var existingIds []int
+		if err := tx.Model(&model.Commodity{}).Where("id IN ?", ids).Pluck("id", &existingIds).Error; err != nil {
+			return err
+		}
+		existing := make(map[int]bool, len(existingIds))
+		for _, id := range existingIds {
+			existing[id] = true
+		}
+
+		// 商品总库存：UPDATE commodities SET stock = stock - CASE id WHEN ? THEN ? ... ELSE 0 END WHERE id IN (...)
+		var caseSQL strings.Builder
+		caseArgs := make([]interface{}, 0, len(existingIds)*2)
+		caseSQL.WriteString("stock - CASE id")
+		for _, id := range ids {
+			if existing[id] {
+				caseSQL.WriteString(" WHEN ? THEN ?")
+				caseArgs = append(caseArgs, id, totals[id])
+			}
+		}
+		// ELSE 0 让CASE的结果类型为整数，PostgreSQL不会把只有占位符的分支推断为文本
+		caseSQL.WriteString(" ELSE 0 END")
+		if len(existingIds) > 0 {
+			if err := tx.Model(&model.Commodity{}).Where("id IN ?", existingIds).Update("stock", gorm.Expr(caseSQL.String(), caseArgs...)).Error; err != nil {
+				return err
+			}
+		}
+
+		// 仓库库存：插入值为负的增量，记录已存在时累加到原库存
+		now := time.Now()
+		rows := make([]*model.WarehouseStock, 0, len(items))
+		applied := make([]StockItem, 0, len(items))
+		for _, item := range items {
+			if !existing[item.CommodityId] {
+				missing = append(missing, item)
+				continue
+			}
+			applied = append(applied, item)
+			rows = append(rows, &model.WarehouseStock{
+				WarehouseId: item.WarehouseId,
+				CommodityId: item.CommodityId,
+				Stock:       -item.Quantity,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			})
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "commodity_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"stock":      upsertIncrement(tx, "warehouse_stocks", "stock"),
+				"updated_at": now,
+			}),
+		}).Create(&rows).Error
+		if err != nil {
+			return err
+		}
+		movements, err := newMovements(movement, applied, -1)
+		if err != nil {
+			return err
+		}
+		return createMovements(tx, movements)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// upsertIncrement 返回冲突更新时把插入值累加到原值的表达式（table.column + 插入值）
+// MySQL通过VALUES(column)引用插入值，PostgreSQL等支持ON CONFLICT的数据库通过EXCLUDED.column引用
+func upsertIncrement(db *gorm.DB, table string, column string) clause.Expr {
+	if db.Dialector.Name() == "mysql" {
+		return gorm.Expr(fmt.Sprintf("%s.%s + VALUES(%s)", table, column, column))
+	}
+	return gorm.Expr(fmt.Sprintf("%s.%s + EXCLUDED.%s", table, column, column))
+}
+
+// restoreDeltas 通过一次pipeline把同步失败的增量加回delta_key，加回失败只记录日志（会由对账发现差异）
+func (rRepo *redisCommodityRepository) restoreDeltas(ctx context.Context, items []StockItem) {
+	pipe := rRepo.cRedisRepo.Pipeline()
+	for _, item := range items {
+		pipe.IncrBy(ctx, getDeltaCacheKey(item.CommodityId, item.WarehouseId), int64(item.Quantity))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("Failed to restore stock deltas %v: %v", items, err)
+	}
+}
+
+// FlushMovements 将发件箱中的库存流水写入数据库，返回写入的条数
+// 业务流程：
+// 1. LRANGE读取发件箱队首最多limit条流水
+// 2. 按movement_key去重插入数据库，写入后未能删除发件箱条目时，下次重复写入不会产生重复流水
+// 3. 仅当队首仍是本次读取的第一条时LTRIM删除已写入的条目，多个实例同时写入时不会删除其他实例尚未写入的流水
+//
+// 注意：无法解析的条目记录日志后随本批一起删除，避免阻塞后续流水
+func (rRepo *redisCommodityRepository) FlushMovements(ctx context.Context, limit int) (int, error) {
+	entries, err := rRepo.cRedisRepo.LRange(ctx, movementOutboxKey, 0, int64(limit)-1).Result()
+	if err != nil {
+		log.Error("Failed to read stock movement outbox:", err)
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	movements := make([]*model.StockMovement, 0, len(entries))
+	for _, entry := range entries {
+		var movement model.StockMovement
+		if err := json.Unmarshal([]byte(entry), &movement); err != nil || movement.MovementKey == nil {
+			log.Errorf("Discarding invalid stock movement %q in outbox: %v", entry, err)
+			continue
+		}
+		movements = append(movements, &movement)
+	}
+	if len(movements) > 0 {
+		err = rRepo.cRepo.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "movement_key"}},
+			DoNothing: true,
+		}).Create(movements).Error
+		if err != nil {
+			log.Error("Failed to write stock movements from outbox:", err)
+			return 0, err
+		}
+	}
+
+	luaScript := `
+	if redis.call("LINDEX", KEYS[1], 0) == ARGV[1] then
+		redis.call("LTRIM", KEYS[1], ARGV[2], -1)
+	end
+	return 0
+`
+	if err := rRepo.cRedisRepo.Eval(ctx, luaScript, []string{movementOutboxKey}, entries[0], len(entries)).Err(); err != nil {
+		log.Error("Failed to trim stock movement outbox:", err)
+		return 0, err
+	}
+	return len(movements), nil
 }
 
 // GetAllDeltaKey 扫描并获取所有库存增量的key
 func (rRepo *redisCommodityRepository) GetAllDeltaKey(ctx context.Context) ([]string, error) {
 	res := make([]string, 0)
-	iter := rRepo.cRedisRepo.Scan(ctx, 0, "delta_key_*", 100).Iterator() //分100条每页
+	iter := rRepo.cRedisRepo.Scan(ctx, 0, deltaKeyPrefix+"*", 100).Iterator() //分100条每页
 	for iter.Next(ctx) {
 		res = append(res, iter.Val())
 	}
@@ -236,17 +871,140 @@ func (rRepo *redisCommodityRepository) GetAllDeltaKey(ctx context.Context) ([]st
 	return res, nil
 }
 
-// GetDeltaValue 获取指定key的库存增量值
-func (rRepo *redisCommodityRepository) GetDeltaValue(ctx context.Context, key string) (int, error) {
-	res, err := rRepo.cRedisRepo.Get(ctx, key).Result()
+// GetStockSnapshot 使用Lua脚本原子性地读取stock_key和delta_key
+// 订单扣减/归还时stock_key与delta_key在同一脚本中修改，因此快照中的Stock+Delta在没有同步进行时应等于MySQL库存
+func (rRepo *redisCommodityRepository) GetStockSnapshot(ctx context.Context, commodityId int, warehouseId int) (*StockSnapshot, error) {
+	luaScript := `
+	local stock = redis.call("GET", KEYS[1])
+	local delta = tonumber(redis.call("GET", KEYS[2])) or 0
+	if not stock then
+		return {0, 0, delta}
+	end
+	return {1, tonumber(stock), delta}
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, []string{getStockCacheKey(commodityId, warehouseId), getDeltaCacheKey(commodityId, warehouseId)}).Result()
 	if err != nil {
-		log.Error("Failed to get delta value:", err)
-		return 0, err
+		log.Error("Failed to get stock snapshot:", err)
+		return nil, err
 	}
-	delta, err := strconv.Atoi(res)
+	res := result.([]interface{})
+	return &StockSnapshot{
+		Exists: res[0].(int64) == 1,
+		Stock:  int(res[1].(int64)),
+		Delta:  int(res[2].(int64)),
+	}, nil
+}
+
+// RepairStockCache 按MySQL库存修复Redis库存缓存
+// 修复后的缓存库存为MySQL库存减去脚本执行时尚未同步的增量，保留delta_key，待同步调度器继续写回MySQL
+// 与InitStockCache不同，stock_key已存在时也会被覆盖
+func (rRepo *redisCommodityRepository) RepairStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) (int, error) {
+	luaScript := `
+	local delta = tonumber(redis.call("GET", KEYS[2])) or 0
+	local stock = tonumber(ARGV[1]) - delta
+	redis.call("SET", KEYS[1], stock)
+	return stock
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, []string{getStockCacheKey(commodityId, warehouseId), getDeltaCacheKey(commodityId, warehouseId)}, stock).Result()
 	if err != nil {
-		log.Error("Failed to convert delta value to int:", err)
+		log.Error("Failed to repair stock cache:", err)
 		return 0, err
 	}
-	return delta, nil
+	log.Debug("Repaired stock cache for commodity ID ", commodityId, " to ", result)
+	return int(result.(int64)), nil
+}
+
+// LockStock 获取商品库存锁（SET NX PX），锁的值为随机令牌，超时后自动释放
+// 返回值：
+// - string: 锁令牌，释放锁时使用
+// - bool: 是否获取成功，锁已被其他进程持有时返回false
+func (rRepo *redisCommodityRepository) LockStock(ctx context.Context, commodityId int, ttl time.Duration) (string, bool, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	token := hex.EncodeToString(buf)
+	ok, err := rRepo.cRedisRepo.SetNX(ctx, getStockLockKey(commodityId), token, ttl).Result()
+	if err != nil {
+		log.Error("Failed to lock stock:", err)
+		return "", false, err
+	}
+	return token, ok, nil
+}
+
+// UnlockStock 释放商品库存锁，只有令牌一致时才删除，防止误删锁超时后被其他进程重新获取的锁
+func (rRepo *redisCommodityRepository) UnlockStock(ctx context.Context, commodityId int, token string) error {
+	luaScript := `
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`
+	if err := rRepo.cRedisRepo.Eval(ctx, luaScript, []string{getStockLockKey(commodityId)}, token).Err(); err != nil {
+		log.Error("Failed to unlock stock:", err)
+		return err
+	}
+	return nil
+}
+
+// LockStockBatch 使用一次Lua调用批量获取多个商品的库存锁，所有锁使用同一个令牌
+// 返回值：
+// - string: 锁令牌，释放锁时使用
+// - []int: 获取成功的商品ID，已被其他进程持有的锁跳过
+func (rRepo *redisCommodityRepository) LockStockBatch(ctx context.Context, commodityIds []int, ttl time.Duration) (string, []int, error) {
+	if len(commodityIds) == 0 {
+		return "", nil, nil
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+	keys := make([]string, 0, len(commodityIds))
+	for _, commodityId := range commodityIds {
+		keys = append(keys, getStockLockKey(commodityId))
+	}
+	luaScript := `
+	local locked = {}
+	for i = 1, #KEYS do
+		if redis.call("SET", KEYS[i], ARGV[1], "NX", "PX", ARGV[2]) then
+			table.insert(locked, i)
+		end
+	end
+	return locked
+`
+	result, err := rRepo.cRedisRepo.Eval(ctx, luaScript, keys, token, ttl.Milliseconds()).Result()
+	if err != nil {
+		log.Error("Failed to lock stock batch:", err)
+		return "", nil, err
+	}
+	locked := make([]int, 0, len(commodityIds))
+	for _, idx := range result.([]interface{}) {
+		locked = append(locked, commodityIds[idx.(int64)-1])
+	}
+	return token, locked, nil
+}
+
+// UnlockStockBatch 使用一次Lua调用批量释放商品库存锁，只删除令牌一致的锁
+func (rRepo *redisCommodityRepository) UnlockStockBatch(ctx context.Context, commodityIds []int, token string) error {
+	if len(commodityIds) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(commodityIds))
+	for _, commodityId := range commodityIds {
+		keys = append(keys, getStockLockKey(commodityId))
+	}
+	luaScript := `
+	for i = 1, #KEYS do
+		if redis.call("GET", KEYS[i]) == ARGV[1] then
+			redis.call("DEL", KEYS[i])
+		end
+	end
+	return 0
+`
+	if err := rRepo.cRedisRepo.Eval(ctx, luaScript, keys, token).Err(); err != nil {
+		log.Error("Failed to unlock stock batch:", err)
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,381 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"server/internal/product/commodity/model"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stockResultError 事务内扣减失败时携带返回码和失败的商品ID，用于回滚事务并转换为与Redis实现一致的返回码
+type stockResultError struct {
+	code        int
+	commodityId int
+}
+
+func (e *stockResultError) Error() string {
+	return fmt.Sprintf("stock operation failed with code %d for commodity %d", e.code, e.commodityId)
+}
+
+type dbStockRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewDBStockRepository 创建一个直接读写数据库的库存仓储实例，用于热点不明显的小规模部署
+// database库存存储下延迟队列、幂等性记录和优惠券计数同样使用数据库实现，整个服务不依赖Redis
+// 库存直接在warehouse_stocks上通过条件UPDATE扣减，同时更新commodities.stock，库存流水在同一事务中写入，没有增量需要同步：
+// - InitStockCache、RepairStockCache、SyncStock等缓存相关操作不做任何修改
+// - 不会返回缓存未初始化（code=2），其余返回码与Redis实现一致，订单服务无需区分
+// - 库存锁总是获取成功，没有增量同步时对账与同步之间不需要互斥
+func NewDBStockRepository(gDB *gorm.DB) StockCacheRepository {
+	return &dbStockRepository{gormDB: gDB}
+}
+
+// InitStockCache 数据库即为库存的唯一来源，无需初始化
+func (dRepo *dbStockRepository) InitStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) error {
+	return nil
+}
+
+// DecreaseStock 使用条件UPDATE（stock >= quantity）扣减商品在仓库的库存，同时扣减商品总库存
+// 返回码：0扣减成功、1数据库执行失败、3库存不足（仓库没有库存记录时同样视为库存不足）
+func (dRepo *dbStockRepository) DecreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) (int, error) {
+	code, _, err := dRepo.DecreaseStockBatch(ctx, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: quantity}}, nil)
+	return code, err
+}
+
+// DecreaseStockBatch 在一个事务中扣减多个商品的库存并写入库存流水（全部成功或全部失败）
+// 带限购条件（秒杀）的商品先累加flash_sale_counters中的用户和活动计数，超过上限时回滚整个事务
+// 条目按(commodity_id, warehouse_id)排序后再加锁更新，并发事务以相同顺序锁定行，避免互相等待导致死锁
+// 返回码与Redis实现一致：0成功、1数据库执行失败、3库存不足、4用户限购已达上限、5秒杀配额已售完
+func (dRepo *dbStockRepository) DecreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) (int, int, error) {
+	if len(items) == 0 {
+		return -1, 0, fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return -1, item.CommodityId, fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	movements, err := newMovements(movement, items, -1)
+	if err != nil {
+		return 1, 0, err
+	}
+	items = sortStockItems(items)
+
+	err = dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if item.Limit == nil {
+				continue
+			}
+			if code, err := reserveFlashSaleCounters(tx, item); err != nil {
+				return err
+			} else if code != 0 {
+				return &stockResultError{code: code, commodityId: item.CommodityId}
+			}
+		}
+		for _, item := range items {
+			result := tx.Model(&model.WarehouseStock{}).
+				Where("commodity_id = ? AND warehouse_id = ? AND stock >= ?", item.CommodityId, item.WarehouseId, item.Quantity).
+				Update("stock", gorm.Expr("stock - ?", item.Quantity))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return &stockResultError{code: 3, commodityId: item.CommodityId}
+			}
+			if err := updateCommodityStock(tx, item.CommodityId, -item.Quantity); err != nil {
+				return err
+			}
+		}
+		return createMovements(tx, movements)
+	})
+
+	var resultErr *stockResultError
+	if errors.As(err, &resultErr) {
+		switch resultErr.code {
+		case 3:
+			log.Warning("Insufficient stock for commodity ID", resultErr.commodityId)
+			return 3, resultErr.commodityId, fmt.Errorf("insufficient stock")
+		case 4:
+			log.Info("Flash sale per-user limit reached for commodity ID ", resultErr.commodityId)
+			return 4, resultErr.commodityId, fmt.Errorf("flash sale per-user limit reached")
+		default:
+			log.Info("Flash sale quota sold out for commodity ID", resultErr.commodityId)
+			return 5, resultErr.commodityId, fmt.Errorf("flash sale sold out")
+		}
+	}
+	if err != nil {
+		log.Error("Failed to decrease stock batch:", err)
+		return 1, 0, err
+	}
+	log.Debug("Decreased stock batch for ", len(items), " commodities")
+	return 0, 0, nil
+}
+
+// sortStockItems 返回按(commodity_id, warehouse_id)排序的条目副本，不修改调用方的切片
+func sortStockItems(items []StockItem) []StockItem {
+	sorted := make([]StockItem, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CommodityId != sorted[j].CommodityId {
+			return sorted[i].CommodityId < sorted[j].CommodityId
+		}
+		return sorted[i].WarehouseId < sorted[j].WarehouseId
+	})
+	return sorted
+}
+
+// reserveFlashSaleCounters 累加用户和活动的秒杀计数后检查上限，返回0表示未超限，4用户限购已达上限，5配额已售完
+// 先累加再检查，累加时的行锁保证并发事务串行检查；超限时由调用方回滚事务撤销累加
+func reserveFlashSaleCounters(tx *gorm.DB, item StockItem) (int, error) {
+	limit := item.Limit
+	userQuantity, err := incrFlashSaleCounter(tx, limit.FlashSaleId, limit.UserId, item.Quantity)
+	if err != nil {
+		return 0, err
+	}
+	if limit.PerUserLimit > 0 && userQuantity > limit.PerUserLimit {
+		return 4, nil
+	}
+	sold, err := incrFlashSaleCounter(tx, limit.FlashSaleId, 0, item.Quantity)
+	if err != nil {
+		return 0, err
+	}
+	if limit.Quota > 0 && sold > limit.Quota {
+		return 5, nil
+	}
+	return 0, nil
+}
+
+// incrFlashSaleCounter 累加秒杀计数（记录不存在时插入），返回累加后的值
+func incrFlashSaleCounter(tx *gorm.DB, flashSaleId int, userId int, quantity int) (int, error) {
+	now := time.Now()
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "flash_sale_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   upsertIncrement(tx, "flash_sale_counters", "quantity"),
+			"updated_at": now,
+		}),
+	}).Create(&model.FlashSaleCounter{FlashSaleId: flashSaleId, UserId: userId, Quantity: quantity, UpdatedAt: now}).Error
+	if err != nil {
+		return 0, err
+	}
+	var counter model.FlashSaleCounter
+	if err = tx.Where("flash_sale_id = ? AND user_id = ?", flashSaleId, userId).First(&counter).Error; err != nil {
+		return 0, err
+	}
+	return counter.Quantity, nil
+}
+
+// IncreaseStock 归还商品在仓库的库存，同时增加商品总库存
+func (dRepo *dbStockRepository) IncreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) error {
+	if quantity <= 0 {
+		log.Warning("Invalid quantity:", quantity)
+		return fmt.Errorf("invalid quantity %d", quantity)
+	}
+	return dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return increaseStock(tx, StockItem{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: quantity})
+	})
+}
+
+// IncreaseStockBatch 在一个事务中归还多个商品的库存并写入库存流水，带限购条件的商品同时归还秒杀计数（不减到0以下）
+// 与DecreaseStockBatch按相同顺序锁定行
+func (dRepo *dbStockRepository) IncreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	movements, err := newMovements(movement, items, 1)
+	if err != nil {
+		return err
+	}
+	items = sortStockItems(items)
+	err = dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			if err := increaseStock(tx, item); err != nil {
+				return err
+			}
+			if item.Limit == nil {
+				continue
+			}
+			err := tx.Model(&model.FlashSaleCounter{}).
+				Where("flash_sale_id = ? AND user_id IN ?", item.Limit.FlashSaleId, []int{item.Limit.UserId, 0}).
+				Update("quantity", gorm.Expr("GREATEST(quantity - ?, 0)", item.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return createMovements(tx, movements)
+	})
+	if err != nil {
+		log.Error("Failed to increase stock batch:", err)
+		return err
+	}
+	log.Debug("Increased stock batch for ", len(items), " commodities")
+	return nil
+}
+
+// increaseStock 在事务中增加仓库库存（记录不存在时插入）和商品总库存
+func increaseStock(tx *gorm.DB, item StockItem) error {
+	now := time.Now()
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "warehouse_id"}, {Name: "commodity_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"stock":      upsertIncrement(tx, "warehouse_stocks", "stock"),
+			"updated_at": now,
+		}),
+	}).Create(&model.WarehouseStock{
+		WarehouseId: item.WarehouseId,
+		CommodityId: item.CommodityId,
+		Stock:       item.Quantity,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return updateCommodityStock(tx, item.CommodityId, item.Quantity)
+}
+
+// updateCommodityStock 在事务中按带符号的数量修改商品总库存，商品不存在时返回错误
+func updateCommodityStock(tx *gorm.DB, commodityId int, delta int) error {
+	result := tx.Model(&model.Commodity{}).Where("id = ?", commodityId).Update("stock", gorm.Expr("stock + ?", delta))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("commodity with id=%d not found", commodityId)
+	}
+	return nil
+}
+
+// AdjustStock 在一个事务中按带符号的调整量修改仓库库存和商品总库存并写入库存流水
+// 出库时使用条件UPDATE检查调整后的库存不小于0；返回码与Redis实现一致（0成功、1数据库执行失败、3可售库存不足以出库）
+func (dRepo *dbStockRepository) AdjustStock(ctx context.Context, commodityId int, warehouseId int, delta int, movement *MovementInfo) (int, int, error) {
+	if delta == 0 {
+		return -1, 0, fmt.Errorf("invalid delta %d", delta)
+	}
+	movements, err := newMovements(movement, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: delta}}, 1)
+	if err != nil {
+		return 1, 0, err
+	}
+	stock := 0
+	err = dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if delta > 0 {
+			if err := increaseStock(tx, StockItem{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: delta}); err != nil {
+				return err
+			}
+		} else {
+			result := tx.Model(&model.WarehouseStock{}).
+				Where("commodity_id = ? AND warehouse_id = ? AND stock >= ?", commodityId, warehouseId, -delta).
+				Update("stock", gorm.Expr("stock + ?", delta))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return &stockResultError{code: 3, commodityId: commodityId}
+			}
+			if err := updateCommodityStock(tx, commodityId, delta); err != nil {
+				return err
+			}
+		}
+		if err := createMovements(tx, movements); err != nil {
+			return err
+		}
+		var err error
+		stock, err = findWarehouseStock(tx, commodityId, warehouseId)
+		return err
+	})
+
+	var resultErr *stockResultError
+	if errors.As(err, &resultErr) {
+		current, err := findWarehouseStock(dRepo.gormDB.WithContext(ctx), commodityId, warehouseId)
+		if err != nil {
+			return 1, 0, err
+		}
+		return 3, current, fmt.Errorf("insufficient stock")
+	}
+	if err != nil {
+		log.Error("Failed to adjust stock:", err)
+		return 1, 0, err
+	}
+	return 0, stock, nil
+}
+
+// findWarehouseStock 查询商品在仓库的库存，没有记录时为0
+func findWarehouseStock(db *gorm.DB, commodityId int, warehouseId int) (int, error) {
+	var stock model.WarehouseStock
+	err := db.Where("commodity_id = ? AND warehouse_id = ?", commodityId, warehouseId).First(&stock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return stock.Stock, nil
+}
+
+// SyncStock 库存直接写入数据库，没有需要同步的增量
+func (dRepo *dbStockRepository) SyncStock(ctx context.Context, commodityId int, warehouseId int, movement *MovementInfo) (int, error) {
+	return 0, nil
+}
+
+// SyncStockBatch 库存直接写入数据库，没有需要同步的增量
+func (dRepo *dbStockRepository) SyncStockBatch(ctx context.Context, keys []StockItem, movement *MovementInfo) ([]StockItem, error) {
+	return nil, nil
+}
+
+// FlushMovements 库存流水已在变动库存的事务中写入，没有发件箱
+func (dRepo *dbStockRepository) FlushMovements(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+// GetAllDeltaKey 库存直接写入数据库，没有增量key
+func (dRepo *dbStockRepository) GetAllDeltaKey(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+// GetStockSnapshot 读取商品在仓库的数据库库存，快照总是存在且没有未同步的增量
+func (dRepo *dbStockRepository) GetStockSnapshot(ctx context.Context, commodityId int, warehouseId int) (*StockSnapshot, error) {
+	stock, err := findWarehouseStock(dRepo.gormDB.WithContext(ctx), commodityId, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	return &StockSnapshot{Exists: true, Stock: stock}, nil
+}
+
+// RepairStockCache 数据库即为库存的唯一来源，无需修复
+func (dRepo *dbStockRepository) RepairStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) (int, error) {
+	return stock, nil
+}
+
+// LockStock 没有增量同步，同步与对账之间不需要互斥，总是获取成功
+func (dRepo *dbStockRepository) LockStock(ctx context.Context, commodityId int, ttl time.Duration) (string, bool, error) {
+	return "", true, nil
+}
+
+// UnlockStock 见LockStock
+func (dRepo *dbStockRepository) UnlockStock(ctx context.Context, commodityId int, token string) error {
+	return nil
+}
+
+// LockStockBatch 见LockStock
+func (dRepo *dbStockRepository) LockStockBatch(ctx context.Context, commodityIds []int, ttl time.Duration) (string, []int, error) {
+	return "", commodityIds, nil
+}
+
+// UnlockStockBatch 见LockStock
+func (dRepo *dbStockRepository) UnlockStockBatch(ctx context.Context, commodityIds []int, token string) error {
+	return nil
+}
@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type memoryStockRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryStockRepository 创建一个基于进程内存储的库存仓储实例，用于memory存储模式
+// 与database库存存储一样直接扣减仓库库存和商品总库存，没有需要同步的增量，返回码与Redis实现一致
+// 库存流水在修改库存的同一把锁内追加，没有发件箱需要写入
+func NewMemoryStockRepository(store *MemoryStore) StockCacheRepository {
+	return &memoryStockRepository{store: store}
+}
+
+// InitStockCache 库存直接保存在内存存储中，无需初始化
+func (mRepo *memoryStockRepository) InitStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) error {
+	return nil
+}
+
+// DecreaseStock 扣减商品在仓库的库存，返回码：0扣减成功、3库存不足
+func (mRepo *memoryStockRepository) DecreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) (int, error) {
+	code, _, err := mRepo.DecreaseStockBatch(ctx, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: quantity}}, nil)
+	return code, err
+}
+
+// DecreaseStockBatch 在锁内检查所有商品的限购条件和库存后一并扣减并追加库存流水（全部成功或全部失败）
+// 返回码与Redis实现一致：0成功、3库存不足、4用户限购已达上限、5秒杀配额已售完
+func (mRepo *memoryStockRepository) DecreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) (int, int, error) {
+	if len(items) == 0 {
+		return -1, 0, fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return -1, item.CommodityId, fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	movements, err := newMovements(movement, items, -1)
+	if err != nil {
+		return 1, 0, err
+	}
+
+	store := mRepo.store
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// 同一批次中同一秒杀活动的多个订单行累计检查
+	counters := make(map[flashSaleCounterKey]int)
+	for _, item := range items {
+		if item.Limit == nil {
+			continue
+		}
+		limit := item.Limit
+		userKey := flashSaleCounterKey{flashSaleId: limit.FlashSaleId, userId: limit.UserId}
+		soldKey := flashSaleCounterKey{flashSaleId: limit.FlashSaleId}
+		counters[userKey] += item.Quantity
+		counters[soldKey] += item.Quantity
+		if limit.PerUserLimit > 0 && store.flashSaleCounters[userKey]+counters[userKey] > limit.PerUserLimit {
+			log.Info("Flash sale per-user limit reached for commodity ID ", item.CommodityId)
+			return 4, item.CommodityId, fmt.Errorf("flash sale per-user limit reached")
+		}
+		if limit.Quota > 0 && store.flashSaleCounters[soldKey]+counters[soldKey] > limit.Quota {
+			log.Info("Flash sale quota sold out for commodity ID", item.CommodityId)
+			return 5, item.CommodityId, fmt.Errorf("flash sale sold out")
+		}
+	}
+
+	required := make(map[warehouseStockKey]int)
+	for _, item := range items {
+		key := warehouseStockKey{commodityId: item.CommodityId, warehouseId: item.WarehouseId}
+		required[key] += item.Quantity
+		if store.warehouseStocks[key].Stock < required[key] {
+			log.Warning("Insufficient stock for commodity ID", item.CommodityId)
+			return 3, item.CommodityId, fmt.Errorf("insufficient stock")
+		}
+	}
+
+	for _, item := range items {
+		store.addWarehouseStock(item.CommodityId, item.WarehouseId, -item.Quantity)
+	}
+	for key, quantity := range counters {
+		store.flashSaleCounters[key] += quantity
+	}
+	store.appendMovements(movements)
+	log.Debug("Decreased stock batch for ", len(items), " commodities")
+	return 0, 0, nil
+}
+
+// IncreaseStock 归还商品在仓库的库存，同时增加商品总库存
+func (mRepo *memoryStockRepository) IncreaseStock(ctx context.Context, commodityId int, warehouseId int, quantity int) error {
+	return mRepo.IncreaseStockBatch(ctx, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: quantity}}, nil)
+}
+
+// IncreaseStockBatch 归还多个商品的库存并追加库存流水，带限购条件的商品同时归还秒杀计数（不减到0以下）
+func (mRepo *memoryStockRepository) IncreaseStockBatch(ctx context.Context, items []StockItem, movement *MovementInfo) error {
+	if len(items) == 0 {
+		return fmt.Errorf("empty stock items")
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			log.Warning("Invalid quantity:", item.Quantity)
+			return fmt.Errorf("invalid quantity %d", item.Quantity)
+		}
+	}
+	movements, err := newMovements(movement, items, 1)
+	if err != nil {
+		return err
+	}
+
+	store := mRepo.store
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, item := range items {
+		store.addWarehouseStock(item.CommodityId, item.WarehouseId, item.Quantity)
+		if item.Limit == nil {
+			continue
+		}
+		for _, userId := range []int{item.Limit.UserId, 0} {
+			key := flashSaleCounterKey{flashSaleId: item.Limit.FlashSaleId, userId: userId}
+			store.flashSaleCounters[key] = max(store.flashSaleCounters[key]-item.Quantity, 0)
+		}
+	}
+	store.appendMovements(movements)
+	log.Debug("Increased stock batch for ", len(items), " commodities")
+	return nil
+}
+
+// AdjustStock 按带符号的调整量修改仓库库存和商品总库存并追加库存流水
+// 返回码与Redis实现一致：0成功、3可售库存不足以出库（同时返回当前库存）
+func (mRepo *memoryStockRepository) AdjustStock(ctx context.Context, commodityId int, warehouseId int, delta int, movement *MovementInfo) (int, int, error) {
+	if delta == 0 {
+		return -1, 0, fmt.Errorf("invalid delta %d", delta)
+	}
+	movements, err := newMovements(movement, []StockItem{{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: delta}}, 1)
+	if err != nil {
+		return 1, 0, err
+	}
+	store := mRepo.store
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	key := warehouseStockKey{commodityId: commodityId, warehouseId: warehouseId}
+	stock := store.warehouseStocks[key].Stock
+	if stock+delta < 0 {
+		return 3, stock, fmt.Errorf("insufficient stock")
+	}
+	store.addWarehouseStock(commodityId, warehouseId, delta)
+	store.appendMovements(movements)
+	return 0, stock + delta, nil
+}
+
+// SyncStock 没有需要同步的增量
+func (mRepo *memoryStockRepository) SyncStock(ctx context.Context, commodityId int, warehouseId int, movement *MovementInfo) (int, error) {
+	return 0, nil
+}
+
+// SyncStockBatch 没有需要同步的增量
+func (mRepo *memoryStockRepository) SyncStockBatch(ctx context.Context, keys []StockItem, movement *MovementInfo) ([]StockItem, error) {
+	return nil, nil
+}
+
+// FlushMovements 库存流水已在变动库存时写入，没有发件箱
+func (mRepo *memoryStockRepository) FlushMovements(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+// GetAllDeltaKey 没有增量key
+func (mRepo *memoryStockRepository) GetAllDeltaKey(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+// GetStockSnapshot 读取商品在仓库的库存，快照总是存在且没有未同步的增量
+func (mRepo *memoryStockRepository) GetStockSnapshot(ctx context.Context, commodityId int, warehouseId int) (*StockSnapshot, error) {
+	mRepo.store.mu.RLock()
+	defer mRepo.store.mu.RUnlock()
+	stock := mRepo.store.warehouseStocks[warehouseStockKey{commodityId: commodityId, warehouseId: warehouseId}].Stock
+	return &StockSnapshot{Exists: true, Stock: stock}, nil
+}
+
+// RepairStockCache 库存只有一份，无需修复
+func (mRepo *memoryStockRepository) RepairStockCache(ctx context.Context, commodityId int, warehouseId int, stock int) (int, error) {
+	return stock, nil
+}
+
+// LockStock 没有增量同步，同步与对账之间不需要互斥，总是获取成功
+func (mRepo *memoryStockRepository) LockStock(ctx context.Context, commodityId int, ttl time.Duration) (string, bool, error) {
+	return "", true, nil
+}
+
+// UnlockStock 见LockStock
+func (mRepo *memoryStockRepository) UnlockStock(ctx context.Context, commodityId int, token string) error {
+	return nil
+}
+
+// LockStockBatch 见LockStock
+func (mRepo *memoryStockRepository) LockStockBatch(ctx context.Context, commodityIds []int, ttl time.Duration) (string, []int, error) {
+	return "", commodityIds, nil
+}
+
+// UnlockStockBatch 见LockStock
+func (mRepo *memoryStockRepository) UnlockStockBatch(ctx context.Context, commodityIds []int, token string) error {
+	return nil
+}
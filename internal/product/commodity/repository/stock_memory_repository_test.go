@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"server/internal/product/commodity/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStockFixture 创建内存库存仓储，商品1在默认仓库有10件库存，商品2有5件
+func newStockFixture(t *testing.T) (*MemoryStore, StockCacheRepository) {
+	t.Helper()
+	store := NewMemoryStore()
+	commodities := NewMemoryCommodityRepository(store)
+	repo := NewMemoryStockRepository(store)
+	for _, stock := range []int{10, 5} {
+		commodity := &model.Commodity{}
+		require.NoError(t, commodities.CreateCommodity(commodity))
+		code, _, err := repo.AdjustStock(context.Background(), commodity.ID, model.DefaultWarehouseId, stock, nil)
+		require.NoError(t, err)
+		require.Equal(t, 0, code)
+	}
+	return store, repo
+}
+
+func stockOf(t *testing.T, repo StockCacheRepository, commodityId int) int {
+	t.Helper()
+	snapshot, err := repo.GetStockSnapshot(context.Background(), commodityId, model.DefaultWarehouseId)
+	require.NoError(t, err)
+	return snapshot.Stock
+}
+
+func TestMemoryStockRepositoryDecreaseStockBatch(t *testing.T) {
+	limit := func(perUser, quota int) *PurchaseLimit {
+		return &PurchaseLimit{FlashSaleId: 1, UserId: 7, PerUserLimit: perUser, Quota: quota}
+	}
+	tests := []struct {
+		name          string
+		items         []StockItem
+		wantCode      int
+		wantCommodity int
+		wantStocks    []int // 扣减后商品1、2的库存
+	}{
+		{
+			name:       "success",
+			items:      []StockItem{{CommodityId: 1, Quantity: 3}, {CommodityId: 2, Quantity: 5}},
+			wantStocks: []int{7, 0},
+		},
+		{
+			name:          "insufficient stock leaves every item untouched",
+			items:         []StockItem{{CommodityId: 1, Quantity: 3}, {CommodityId: 2, Quantity: 6}},
+			wantCode:      3,
+			wantCommodity: 2,
+			wantStocks:    []int{10, 5},
+		},
+		{
+			name:          "rows of the same commodity are summed",
+			items:         []StockItem{{CommodityId: 2, Quantity: 3}, {CommodityId: 2, Quantity: 3}},
+			wantCode:      3,
+			wantCommodity: 2,
+			wantStocks:    []int{10, 5},
+		},
+		{
+			name:          "per-user limit",
+			items:         []StockItem{{CommodityId: 1, Quantity: 2, Limit: limit(1, 0)}},
+			wantCode:      4,
+			wantCommodity: 1,
+			wantStocks:    []int{10, 5},
+		},
+		{
+			name:          "flash sale quota",
+			items:         []StockItem{{CommodityId: 1, Quantity: 2, Limit: limit(0, 3)}, {CommodityId: 2, Quantity: 2, Limit: limit(0, 3)}},
+			wantCode:      5,
+			wantCommodity: 2,
+			wantStocks:    []int{10, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.items {
+				tt.items[i].WarehouseId = model.DefaultWarehouseId
+			}
+			store, repo := newStockFixture(t)
+			movement := &MovementInfo{Reason: model.MovementOrderReserve, OrderNo: "O1"}
+			code, commodityId, err := repo.DecreaseStockBatch(context.Background(), tt.items, movement)
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantCommodity, commodityId)
+			if tt.wantCode == 0 {
+				require.NoError(t, err)
+				assert.Len(t, store.movements, len(tt.items), "one movement per item")
+			} else {
+				assert.Error(t, err)
+				assert.Empty(t, store.movements, "no movement is written when nothing is deducted")
+			}
+			assert.Equal(t, tt.wantStocks, []int{stockOf(t, repo, 1), stockOf(t, repo, 2)})
+			commodity, err := NewMemoryCommodityRepository(store).FindCommodityById(1)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStocks[0], commodity.Stock, "commodity stock follows the warehouse stock")
+		})
+	}
+}
+
+func TestMemoryStockRepositoryIncreaseStockBatch(t *testing.T) {
+	store, repo := newStockFixture(t)
+	ctx := context.Background()
+	items := []StockItem{{CommodityId: 1, WarehouseId: model.DefaultWarehouseId, Quantity: 2,
+		Limit: &PurchaseLimit{FlashSaleId: 1, UserId: 7, PerUserLimit: 2, Quota: 2}}}
+	code, _, err := repo.DecreaseStockBatch(ctx, items, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+
+	code, _, _ = repo.DecreaseStockBatch(ctx, items, nil)
+	assert.Equal(t, 4, code, "the per-user counter is used up")
+
+	require.NoError(t, repo.IncreaseStockBatch(ctx, items, &MovementInfo{Reason: model.MovementOrderCancel, OrderNo: "O1"}))
+	assert.Equal(t, 10, stockOf(t, repo, 1))
+	require.Len(t, store.movements, 1)
+	assert.Equal(t, 2, store.movements[0].Quantity)
+	assert.Equal(t, model.MovementOrderCancel, store.movements[0].Reason)
+
+	code, _, err = repo.DecreaseStockBatch(ctx, items, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code, "releasing the stock also returns the flash sale counters")
+}
+
+func TestMemoryStockRepositoryAdjustStock(t *testing.T) {
+	tests := []struct {
+		name      string
+		delta     int
+		wantCode  int
+		wantStock int
+	}{
+		{"inbound", 5, 0, 15},
+		{"outbound", -10, 0, 0},
+		{"outbound beyond stock", -11, 3, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, repo := newStockFixture(t)
+			code, stock, err := repo.AdjustStock(context.Background(), 1, model.DefaultWarehouseId, tt.delta,
+				&MovementInfo{Reason: model.MovementManualAdjust, Operator: "admin:test"})
+			assert.Equal(t, tt.wantCode, code)
+			assert.Equal(t, tt.wantStock, stock)
+			assert.Equal(t, tt.wantStock, stockOf(t, repo, 1))
+			if tt.wantCode == 0 {
+				require.NoError(t, err)
+				require.Len(t, store.movements, 1)
+				assert.Equal(t, tt.delta, store.movements[0].Quantity)
+			} else {
+				assert.Error(t, err)
+				assert.Empty(t, store.movements)
+			}
+		})
+	}
+}
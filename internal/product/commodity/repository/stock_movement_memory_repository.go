@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"server/internal/product/commodity/model"
+	"server/pkg/memstore"
+	"sort"
+)
+
+type memoryStockMovementRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryStockMovementRepository 创建一个基于进程内存储的库存流水仓储实例，用于memory存储模式
+func NewMemoryStockMovementRepository(store *MemoryStore) StockMovementRepository {
+	return &memoryStockMovementRepository{store: store}
+}
+
+// CreateMovements 追加多条库存流水，并回写分配的流水ID
+func (mRepo *memoryStockMovementRepository) CreateMovements(movements []*model.StockMovement) error {
+	mRepo.store.mu.Lock()
+	defer mRepo.store.mu.Unlock()
+	mRepo.store.appendMovements(movements)
+	return nil
+}
+
+// FindMovements 分页查询商品的库存流水，按发生时间倒序（最新的在前），同时返回符合条件的总数
+func (mRepo *memoryStockMovementRepository) FindMovements(query StockMovementQuery) ([]*model.StockMovement, int64, error) {
+	mRepo.store.mu.RLock()
+	defer mRepo.store.mu.RUnlock()
+	movements := make([]*model.StockMovement, 0)
+	for _, movement := range mRepo.store.movements {
+		if movement.CommodityId != query.CommodityId ||
+			(query.WarehouseId != 0 && movement.WarehouseId != query.WarehouseId) ||
+			(query.Reason != "" && movement.Reason != query.Reason) ||
+			(!query.StartTime.IsZero() && movement.CreatedAt.Before(query.StartTime)) ||
+			(!query.EndTime.IsZero() && !movement.CreatedAt.Before(query.EndTime)) {
+			continue
+		}
+		movement := movement
+		movements = append(movements, &movement)
+	}
+	sort.Slice(movements, func(i, j int) bool {
+		if !movements[i].CreatedAt.Equal(movements[j].CreatedAt) {
+			return movements[i].CreatedAt.After(movements[j].CreatedAt)
+		}
+		return movements[i].Id > movements[j].Id
+	})
+	return memstore.Paginate(movements, query.Offset, query.Limit), int64(len(movements)), nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"server/internal/product/commodity/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockMovementQuery 库存流水的查询条件
+type StockMovementQuery struct {
+	CommodityId int                       // 商品ID
+	WarehouseId int                       // 仓库ID，0表示不过滤
+	Reason      model.StockMovementReason // 变动原因，为空时不过滤
+	StartTime   time.Time                 // 发生时间下限（包含），零值表示不限
+	EndTime     time.Time                 // 发生时间上限（不包含），零值表示不限
+	Offset      int                       // 分页偏移量
+	Limit       int                       // 每页条数
+}
+
+// MovementInfo 库存变动对应的流水信息，库存仓储在变动库存的同一原子操作中写入流水
+// 传入nil时不记录流水
+type MovementInfo struct {
+	Reason   model.StockMovementReason // 变动原因
+	OrderNo  string                    // 关联的订单号，与订单无关的变动为空
+	Operator string                    // 操作人，如user:12、admin:alice、system
+	Note     string                    // 变动说明
+}
+
+// newMovements 按流水信息为每个条目生成一条带唯一键的库存流水，变动量为条目数量乘以sign（-1表示减少）
+// info为nil时返回nil
+func newMovements(info *MovementInfo, items []StockItem, sign int) ([]*model.StockMovement, error) {
+	if info == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	movements := make([]*model.StockMovement, 0, len(items))
+	for _, item := range items {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		key := hex.EncodeToString(buf)
+		movements = append(movements, &model.StockMovement{
+			MovementKey: &key,
+			CommodityId: item.CommodityId,
+			WarehouseId: item.WarehouseId,
+			Quantity:    item.Quantity * sign,
+			Reason:      info.Reason,
+			OrderNo:     info.OrderNo,
+			Operator:    info.Operator,
+			Note:        info.Note,
+			CreatedAt:   now,
+		})
+	}
+	return movements, nil
+}
+
+// createMovements 在事务中追加库存流水
+func createMovements(tx *gorm.DB, movements []*model.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	return tx.Create(movements).Error
+}
+
+// StockMovementRepository 库存流水的数据访问接口，流水只允许追加，不允许修改和删除
+type StockMovementRepository interface {
+	CreateMovements(movements []*model.StockMovement) error
+	FindMovements(query StockMovementQuery) ([]*model.StockMovement, int64, error)
+}
+
+type gormStockMovementRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewStockMovementRepository 创建一个新的库存流水仓储实例
+func NewStockMovementRepository(gDB *gorm.DB) StockMovementRepository {
+	return &gormStockMovementRepository{gormDB: gDB}
+}
+
+// CreateMovements 在同一条INSERT中追加多条库存流水
+func (mRepo *gormStockMovementRepository) CreateMovements(movements []*model.StockMovement) error {
+	return createMovements(mRepo.gormDB, movements)
+}
+
+// FindMovements 分页查询商品的库存流水，按发生时间倒序（最新的在前），同时返回符合条件的总数
+func (mRepo *gormStockMovementRepository) FindMovements(query StockMovementQuery) ([]*model.StockMovement, int64, error) {
+	db := mRepo.gormDB.Model(&model.StockMovement{}).Where("commodity_id = ?", query.CommodityId)
+	if query.WarehouseId != 0 {
+		db = db.Where("warehouse_id = ?", query.WarehouseId)
+	}
+	if query.Reason != "" {
+		db = db.Where("reason = ?", query.Reason)
+	}
+	if !query.StartTime.IsZero() {
+		db = db.Where("created_at >= ?", query.StartTime)
+	}
+	if !query.EndTime.IsZero() {
+		db = db.Where("created_at < ?", query.EndTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var movements []*model.StockMovement
+	err := db.Order("created_at DESC, id DESC").Offset(query.Offset).Limit(query.Limit).Find(&movements).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return movements, total, nil
+}
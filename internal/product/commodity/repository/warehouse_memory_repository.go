@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"server/internal/product/commodity/model"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryWarehouseRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryWarehouseRepository 创建一个基于进程内存储的仓库仓储实例，用于memory存储模式
+func NewMemoryWarehouseRepository(store *MemoryStore) WarehouseRepository {
+	return &memoryWarehouseRepository{store: store}
+}
+
+// CreateWarehouse 创建仓库，并回写分配的仓库ID
+func (wRepo *memoryWarehouseRepository) CreateWarehouse(warehouse *model.Warehouse) error {
+	wRepo.store.mu.Lock()
+	defer wRepo.store.mu.Unlock()
+	now := time.Now()
+	if warehouse.CreatedAt.IsZero() {
+		warehouse.CreatedAt = now
+	}
+	if warehouse.UpdatedAt.IsZero() {
+		warehouse.UpdatedAt = now
+	}
+	wRepo.store.lastWarehouseId++
+	warehouse.Id = wRepo.store.lastWarehouseId
+	wRepo.store.warehouses[warehouse.Id] = *warehouse
+	return nil
+}
+
+// UpdateWarehouse 更新仓库的名称、地区、优先级和启用状态
+func (wRepo *memoryWarehouseRepository) UpdateWarehouse(warehouse *model.Warehouse) error {
+	wRepo.store.mu.Lock()
+	defer wRepo.store.mu.Unlock()
+	current, ok := wRepo.store.warehouses[warehouse.Id]
+	if !ok {
+		return nil
+	}
+	current.Name = warehouse.Name
+	current.Region = warehouse.Region
+	current.Priority = warehouse.Priority
+	current.Enabled = warehouse.Enabled
+	current.UpdatedAt = time.Now()
+	wRepo.store.warehouses[warehouse.Id] = current
+	return nil
+}
+
+// FindWarehouseById 根据ID查找仓库，仓库不存在时返回gorm.ErrRecordNotFound
+func (wRepo *memoryWarehouseRepository) FindWarehouseById(id int) (*model.Warehouse, error) {
+	wRepo.store.mu.RLock()
+	defer wRepo.store.mu.RUnlock()
+	warehouse, ok := wRepo.store.warehouses[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &warehouse, nil
+}
+
+// FindWarehouseByCode 根据编码查找仓库，仓库不存在时返回gorm.ErrRecordNotFound
+func (wRepo *memoryWarehouseRepository) FindWarehouseByCode(code string) (*model.Warehouse, error) {
+	wRepo.store.mu.RLock()
+	defer wRepo.store.mu.RUnlock()
+	for _, warehouse := range wRepo.store.warehouses {
+		if warehouse.Code == code {
+			return &warehouse, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// ListWarehouses 按优先级获取所有仓库（包括已停用的）
+func (wRepo *memoryWarehouseRepository) ListWarehouses() ([]*model.Warehouse, error) {
+	wRepo.store.mu.RLock()
+	defer wRepo.store.mu.RUnlock()
+	warehouses := make([]*model.Warehouse, 0, len(wRepo.store.warehouses))
+	for _, warehouse := range wRepo.store.warehouses {
+		warehouse := warehouse
+		warehouses = append(warehouses, &warehouse)
+	}
+	sort.Slice(warehouses, func(i, j int) bool {
+		if warehouses[i].Priority != warehouses[j].Priority {
+			return warehouses[i].Priority < warehouses[j].Priority
+		}
+		return warehouses[i].Id < warehouses[j].Id
+	})
+	return warehouses, nil
+}
+
+// FindWarehouseStock 查找商品在某个仓库的库存记录，商品从未在该仓库入库时返回gorm.ErrRecordNotFound
+func (wRepo *memoryWarehouseRepository) FindWarehouseStock(commodityId int, warehouseId int) (*model.WarehouseStock, error) {
+	wRepo.store.mu.RLock()
+	defer wRepo.store.mu.RUnlock()
+	stock, ok := wRepo.store.warehouseStocks[warehouseStockKey{commodityId: commodityId, warehouseId: warehouseId}]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &stock, nil
+}
+
+// FindWarehouseStocksByCommodityIds 查找多个商品在所有仓库的库存记录
+func (wRepo *memoryWarehouseRepository) FindWarehouseStocksByCommodityIds(commodityIds []int) ([]*model.WarehouseStock, error) {
+	ids := make(map[int]bool, len(commodityIds))
+	for _, id := range commodityIds {
+		ids[id] = true
+	}
+	return wRepo.listWarehouseStocks(func(stock *model.WarehouseStock) bool { return ids[stock.CommodityId] }), nil
+}
+
+// ListWarehouseStocks 获取所有仓库库存记录
+func (wRepo *memoryWarehouseRepository) ListWarehouseStocks() ([]*model.WarehouseStock, error) {
+	return wRepo.listWarehouseStocks(func(*model.WarehouseStock) bool { return true }), nil
+}
+
+// listWarehouseStocks 按ID升序返回满足条件的仓库库存记录
+func (wRepo *memoryWarehouseRepository) listWarehouseStocks(match func(stock *model.WarehouseStock) bool) []*model.WarehouseStock {
+	wRepo.store.mu.RLock()
+	defer wRepo.store.mu.RUnlock()
+	stocks := make([]*model.WarehouseStock, 0)
+	for _, stock := range wRepo.store.warehouseStocks {
+		if match(&stock) {
+			stock := stock
+			stocks = append(stocks, &stock)
+		}
+	}
+	sort.Slice(stocks, func(i, j int) bool { return stocks[i].Id < stocks[j].Id })
+	return stocks
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"server/internal/product/commodity/model"
+
+	"gorm.io/gorm"
+)
+
+// WarehouseRepository 仓库和仓库库存的数据访问接口
+// 仓库库存只通过SyncStock写入，这里只提供读操作
+type WarehouseRepository interface {
+	CreateWarehouse(warehouse *model.Warehouse) error
+	UpdateWarehouse(warehouse *model.Warehouse) error
+	FindWarehouseById(id int) (*model.Warehouse, error)
+	FindWarehouseByCode(code string) (*model.Warehouse, error)
+	ListWarehouses() ([]*model.Warehouse, error)
+	FindWarehouseStock(commodityId int, warehouseId int) (*model.WarehouseStock, error)
+	FindWarehouseStocksByCommodityIds(commodityIds []int) ([]*model.WarehouseStock, error)
+	ListWarehouseStocks() ([]*model.WarehouseStock, error)
+}
+
+type gormWarehouseRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewWarehouseRepository 创建一个新的仓库仓储实例
+func NewWarehouseRepository(gDB *gorm.DB) WarehouseRepository {
+	return &gormWarehouseRepository{gormDB: gDB}
+}
+
+// CreateWarehouse 创建仓库
+func (wRepo *gormWarehouseRepository) CreateWarehouse(warehouse *model.Warehouse) error {
+	return wRepo.gormDB.Create(warehouse).Error
+}
+
+// UpdateWarehouse 更新仓库的名称、地区、优先级和启用状态（显式指定字段，允许将启用状态更新为false）
+func (wRepo *gormWarehouseRepository) UpdateWarehouse(warehouse *model.Warehouse) error {
+	return wRepo.gormDB.Model(&model.Warehouse{}).
+		Where("id = ?", warehouse.Id).
+		Select("name", "region", "priority", "enabled", "updated_at").
+		Updates(warehouse).Error
+}
+
+// FindWarehouseById 根据ID查找仓库
+func (wRepo *gormWarehouseRepository) FindWarehouseById(id int) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	if err := wRepo.gormDB.First(&warehouse, id).Error; err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// FindWarehouseByCode 根据编码查找仓库
+func (wRepo *gormWarehouseRepository) FindWarehouseByCode(code string) (*model.Warehouse, error) {
+	var warehouse model.Warehouse
+	if err := wRepo.gormDB.Where("code = ?", code).First(&warehouse).Error; err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+// ListWarehouses 按优先级获取所有仓库（包括已停用的）
+func (wRepo *gormWarehouseRepository) ListWarehouses() ([]*model.Warehouse, error) {
+	warehouses := make([]*model.Warehouse, 0)
+	if err := wRepo.gormDB.Order("priority ASC, id ASC").Find(&warehouses).Error; err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+// FindWarehouseStock 查找商品在某个仓库的库存记录，商品从未在该仓库入库时返回gorm.ErrRecordNotFound
+func (wRepo *gormWarehouseRepository) FindWarehouseStock(commodityId int, warehouseId int) (*model.WarehouseStock, error) {
+	var stock model.WarehouseStock
+	err := wRepo.gormDB.Where("commodity_id = ? AND warehouse_id = ?", commodityId, warehouseId).First(&stock).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+// FindWarehouseStocksByCommodityIds 查找多个商品在所有仓库的库存记录
+func (wRepo *gormWarehouseRepository) FindWarehouseStocksByCommodityIds(commodityIds []int) ([]*model.WarehouseStock, error) {
+	stocks := make([]*model.WarehouseStock, 0)
+	if len(commodityIds) == 0 {
+		return stocks, nil
+	}
+	if err := wRepo.gormDB.Where("commodity_id IN ?", commodityIds).Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
+
+// ListWarehouseStocks 获取所有仓库库存记录，用于启动时预热缓存
+func (wRepo *gormWarehouseRepository) ListWarehouseStocks() ([]*model.WarehouseStock, error) {
+	stocks := make([]*model.WarehouseStock, 0)
+	if err := wRepo.gormDB.Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
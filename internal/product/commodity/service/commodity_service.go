@@ -1,19 +1,23 @@
 package service
 
 import (
+	"context"
 	"server/internal/product/commodity/model"
 	"server/internal/product/commodity/repository"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // CommodityService 提供商品相关的业务逻辑服务
 type CommodityService struct {
-	cRepo repository.CommodityRepository
+	cRepo         repository.CommodityRepository
+	stockCacheSvc *StockCacheService
 }
 
 // NewCommodityService 创建一个新的商品服务实例
-func NewCommodityService(repository repository.CommodityRepository) *CommodityService {
-	return &CommodityService{cRepo: repository}
+func NewCommodityService(repository repository.CommodityRepository, stockCacheSvc *StockCacheService) *CommodityService {
+	return &CommodityService{cRepo: repository, stockCacheSvc: stockCacheSvc}
 }
 
 // CreateCommodity 创建新商品，设置创建时间、更新时间、状态和库存初始值
@@ -23,12 +27,13 @@ func NewCommodityService(repository repository.CommodityRepository) *CommoditySe
 // 3. 设置商品状态为true（上架状态）
 // 4. 设置库存初始值为0（需后续手动设置库存）
 // 5. 调用Repository层创建商品记录
+// 6. 将库存写入Redis缓存，保证新商品的首批订单无需回源MySQL
 //
 // 注意：
 // - 商品创建后状态默认为true（上架）
 // - 库存初始值强制为0，忽略传入的Stock值
-// - 创建后需要手动更新库存或使用库存管理功能
-// - 如需使用Redis库存缓存，需调用StockCacheService.InitStockCache初始化
+// - 创建后通过库存调整接口（StockCacheService.AdjustStock）入库
+// - Redis缓存写入失败只记录日志，下单时会按需从MySQL加载
 func (c *CommodityService) CreateCommodity(commodity *model.Commodity) error {
 	// 设置创建时间和更新时间为当前时间
 	commodity.CreatedAt = time.Now()
@@ -38,7 +43,11 @@ func (c *CommodityService) CreateCommodity(commodity *model.Commodity) error {
 	// 强制设置库存为0，防止创建时直接设置库存导致Redis和MySQL不一致
 	commodity.Stock = 0
 
-	return c.cRepo.CreateCommodity(commodity)
+	if err := c.cRepo.CreateCommodity(commodity); err != nil {
+		return err
+	}
+	c.initStockCache(commodity.ID)
+	return nil
 }
 
 // RemoveCommodity 根据ID删除商品
@@ -50,21 +59,18 @@ func (c *CommodityService) RemoveCommodity(id int) error {
 // 业务流程：
 // 1. 根据ID查询原有商品信息
 // 2. 保留原商品的创建时间（CreatedAt）
-// 3. 保留原商品的状态（Status）
+// 3. 保留原商品的状态（Status）和库存（Stock）
 // 4. 设置更新时间为当前时间
 // 5. 调用Repository层更新商品记录
 //
 // 保留字段说明：
 // - CreatedAt: 保留原有创建时间，不允许修改
 // - Status: 保留原有状态，需通过专门的上下架接口修改
+// - Stock: 保留原有库存，只能通过StockCacheService.AdjustStock调整（直接覆盖会丢失尚未同步的订单扣减，导致超卖）
 //
 // 可更新字段：
 // - Name: 商品名称
 // - Price: 商品价格
-// - Stock: 商品库存（注意：更新MySQL库存不会自动同步到Redis）
-//
-// 注意：
-// - 如果使用了Redis库存缓存，更新Stock后需手动刷新Redis缓存
 func (c *CommodityService) UpdateCommodity(commodity *model.Commodity) error {
 	// 查询原有商品信息，用于保留不可修改的字段
 	com, err := c.cRepo.FindCommodityById(commodity.ID)
@@ -76,12 +82,21 @@ func (c *CommodityService) UpdateCommodity(commodity *model.Commodity) error {
 	commodity.CreatedAt = com.CreatedAt
 	// 保留原有的状态，状态变更需通过专门的接口
 	commodity.Status = com.Status
+	// 保留原有的库存，库存变更需通过库存调整接口
+	commodity.Stock = com.Stock
 	// 设置更新时间为当前时间
 	commodity.UpdateAt = time.Now()
 
 	return c.cRepo.UpdateCommodity(commodity)
 }
 
+// initStockCache 将新商品在各启用仓库的库存写入Redis缓存，失败只记录日志，不影响已完成的数据库写入
+func (c *CommodityService) initStockCache(commodityId int) {
+	if err := c.stockCacheSvc.InitCommodityStockCache(context.TODO(), commodityId); err != nil {
+		log.Errorf("Failed to refresh stock cache of commodity %d: %v", commodityId, err)
+	}
+}
+
 // FindCommodityById 根据ID查找商品
 func (c *CommodityService) FindCommodityById(id int) (*model.Commodity, error) {
 	return c.cRepo.FindCommodityById(id)
@@ -0,0 +1,20 @@
+package service
+
+import "errors"
+
+var (
+	// ErrInvalidMovementReason 库存变动原因不合法
+	ErrInvalidMovementReason = errors.New("invalid stock movement reason")
+
+	// ErrInvalidStockDelta 库存调整量为0
+	ErrInvalidStockDelta = errors.New("stock delta must not be zero")
+
+	// ErrInsufficientStock 出库数量超过当前可售库存
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// ErrWarehouseNotFound 仓库不存在
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+
+	// ErrInvalidWarehouse 仓库参数无效（编码为空或重复等）
+	ErrInvalidWarehouse = errors.New("invalid warehouse")
+)
@@ -2,52 +2,284 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"server/internal/product/commodity/model"
 	"server/internal/product/commodity/repository"
 	"strconv"
-	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 )
 
 // StockCacheService 提供库存缓存相关的业务逻辑服务
+// 库存按商品和仓库分别缓存，Redis key见repository.getStockCacheKey
 type StockCacheService struct {
 	cRedisSvc repository.StockCacheRepository
+	cRepo     repository.CommodityRepository
+	wRepo     repository.WarehouseRepository
+	loadGroup singleflight.Group // 合并同一商品同一仓库的并发缓存加载
 }
 
 // NewStockCacheService 创建一个新的库存缓存服务实例
-func NewStockCacheService(cRedisSvc repository.StockCacheRepository) *StockCacheService {
-	return &StockCacheService{cRedisSvc: cRedisSvc}
+func NewStockCacheService(cRedisSvc repository.StockCacheRepository, cRepo repository.CommodityRepository, wRepo repository.WarehouseRepository) *StockCacheService {
+	return &StockCacheService{cRedisSvc: cRedisSvc, cRepo: cRepo, wRepo: wRepo}
 }
 
+// WarmUpStockCache 启动时将所有上架商品在启用仓库中的库存从MySQL加载到Redis
+// 已存在的缓存不会被覆盖（其中可能包含尚未同步到MySQL的扣减），单条加载失败只记录日志，
+// 下单时仍可通过LoadStockCache按需加载
+func (s *StockCacheService) WarmUpStockCache(ctx context.Context) error {
+	commodities, err := s.cRepo.ListCommodity()
+	if err != nil {
+		return err
+	}
+	warehouses, err := s.wRepo.ListWarehouses()
+	if err != nil {
+		return err
+	}
+	stocks, err := s.wRepo.ListWarehouseStocks()
+	if err != nil {
+		return err
+	}
+
+	onShelf := make(map[int]bool, len(commodities))
+	for _, commodity := range commodities {
+		onShelf[commodity.ID] = commodity.Status
+	}
+	enabled := make(map[int]bool, len(warehouses))
+	for _, warehouse := range warehouses {
+		enabled[warehouse.Id] = warehouse.Enabled
+	}
+
+	loaded := 0
+	for _, stock := range stocks {
+		if !onShelf[stock.CommodityId] || !enabled[stock.WarehouseId] {
+			continue
+		}
+		if err = s.cRedisSvc.InitStockCache(ctx, stock.CommodityId, stock.WarehouseId, stock.Stock); err != nil {
+			log.Warningf("Failed to warm up stock cache of commodity %d in warehouse %d: %v", stock.CommodityId, stock.WarehouseId, err)
+			continue
+		}
+		loaded++
+	}
+	log.Infof("Stock cache warmed up for %d commodity warehouse stocks", loaded)
+	return nil
+}
+
+// LoadStockCache 缓存未命中时从MySQL加载商品在某个仓库的库存到Redis
+// 同一进程内对同一商品同一仓库的并发加载通过singleflight合并为一次，跨实例的并发由InitStockCache的SETNX语义保证只初始化一次
+// 商品从未在该仓库入库时按0初始化；商品不存在时返回gorm.ErrRecordNotFound
+func (s *StockCacheService) LoadStockCache(ctx context.Context, commodityId int, warehouseId int) error {
+	key := strconv.Itoa(commodityId) + "_" + strconv.Itoa(warehouseId)
+	_, err, _ := s.loadGroup.Do(key, func() (interface{}, error) {
+		if _, err := s.cRepo.FindCommodityById(commodityId); err != nil {
+			return nil, err
+		}
+		stock := 0
+		warehouseStock, err := s.wRepo.FindWarehouseStock(commodityId, warehouseId)
+		if err == nil {
+			stock = warehouseStock.Stock
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, s.cRedisSvc.InitStockCache(ctx, commodityId, warehouseId, stock)
+	})
+	return err
+}
+
+// InitCommodityStockCache 为新商品在所有启用的仓库中写入库存为0的缓存，保证首批订单无需回源MySQL
+// 使用InitStockCache的SETNX语义，已存在的缓存（如创建后立即入库）不会被覆盖
+func (s *StockCacheService) InitCommodityStockCache(ctx context.Context, commodityId int) error {
+	warehouses, err := s.wRepo.ListWarehouses()
+	if err != nil {
+		return err
+	}
+	for _, warehouse := range warehouses {
+		if !warehouse.Enabled {
+			continue
+		}
+		if err = s.cRedisSvc.InitStockCache(ctx, commodityId, warehouse.Id, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncBatchSize 每批同步的商品仓库数，每批使用一次Lua调用和一个数据库事务
+const syncBatchSize = 500
+
 // SyncAllStock 同步所有有变化的库存到数据库
+// 业务流程：
+// 1. 扫描所有delta_key，解析出商品和仓库
+// 2. 按syncBatchSize分批，每批一次Lua调用获取所有涉及商品的库存锁，锁被占用（正在对账或调整）的商品本轮跳过
+// 3. 一次Lua调用读取并重置已加锁商品的增量，在一个事务中写入MySQL和同步的库存流水，失败时增量加回Redis
+// 4. 释放本批的库存锁
 func (s *StockCacheService) SyncAllStock(ctx context.Context) error {
 	keys, err := s.cRedisSvc.GetAllDeltaKey(ctx)
 	if err != nil {
 		return err
 	}
-	validKeys := make([]int, 0)
+	items := make([]repository.StockItem, 0, len(keys))
 	for _, key := range keys {
-		value, err := s.cRedisSvc.GetDeltaValue(ctx, key)
+		commodityId, warehouseId, err := repository.ParseDeltaKey(key)
 		if err != nil {
-			log.Warning("fail to get delta value " + err.Error())
+			log.Warning("invalid key " + err.Error())
 			continue
 		}
-		if value != 0 {
-			key = strings.TrimPrefix(key, "delta_key_")
-			i, err := strconv.Atoi(key)
-			if err != nil {
-				log.Warning("invalid key " + err.Error())
-				continue
-			}
-			validKeys = append(validKeys, i)
+		items = append(items, repository.StockItem{CommodityId: commodityId, WarehouseId: warehouseId})
+	}
+	for start := 0; start < len(items); start += syncBatchSize {
+		end := start + syncBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		if err = s.syncStockBatch(ctx, items[start:end]); err != nil {
+			log.Warning("fail to sync " + err.Error())
 		}
 	}
-	for _, key := range validKeys {
-		err = s.cRedisSvc.SyncStock(ctx, key)
+	return nil
+}
+
+// syncMovement 库存同步的流水信息，记录的是MySQL库存的变化，可售库存已在扣减/归还时记录过
+var syncMovement = &repository.MovementInfo{Reason: model.MovementSync, Operator: OperatorSystem}
+
+// movementFlushBatchSize 每批从发件箱写入数据库的库存流水条数
+const movementFlushBatchSize = 500
+
+// FlushMovements 将库存仓储发件箱中的库存流水全部写入数据库（Redis库存存储下由扣减、归还和调整脚本写入发件箱）
+// 每批最多movementFlushBatchSize条，写入失败时返回错误，未写入的流水保留在发件箱中，下一轮重试
+func (s *StockCacheService) FlushMovements(ctx context.Context) error {
+	total := 0
+	for {
+		n, err := s.cRedisSvc.FlushMovements(ctx, movementFlushBatchSize)
+		total += n
 		if err != nil {
-			log.Warning("fail to sync " + err.Error())
-			continue
+			return fmt.Errorf("flushed %d stock movements before failure: %w", total, err)
+		}
+		if n < movementFlushBatchSize {
+			break
 		}
 	}
+	if total > 0 {
+		log.Debugf("Flushed %d stock movements from outbox", total)
+	}
 	return nil
 }
+
+// syncStockBatch 持有所涉及商品的库存锁，批量同步一批商品仓库的库存增量并记录同步的库存流水
+func (s *StockCacheService) syncStockBatch(ctx context.Context, items []repository.StockItem) error {
+	commodityIds := make([]int, 0, len(items))
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if !seen[item.CommodityId] {
+			seen[item.CommodityId] = true
+			commodityIds = append(commodityIds, item.CommodityId)
+		}
+	}
+	token, lockedIds, err := s.cRedisSvc.LockStockBatch(ctx, commodityIds, stockLockTTL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.cRedisSvc.UnlockStockBatch(ctx, lockedIds, token); err != nil {
+			log.Warningf("Failed to unlock stock of commodities %v: %v", lockedIds, err)
+		}
+	}()
+
+	locked := make(map[int]bool, len(lockedIds))
+	for _, id := range lockedIds {
+		locked[id] = true
+	}
+	keys := make([]repository.StockItem, 0, len(items))
+	for _, item := range items {
+		if locked[item.CommodityId] {
+			keys = append(keys, item)
+		} else {
+			// 与库存对账互斥，锁被占用时跳过，下一轮再同步
+			log.Debug("Stock of commodity ", item.CommodityId, " is locked, skip sync")
+		}
+	}
+
+	synced, err := s.cRedisSvc.SyncStockBatch(ctx, keys, syncMovement)
+	if err != nil {
+		return err
+	}
+	if len(synced) > 0 {
+		log.Debugf("Synced stock deltas of %d commodity warehouses", len(synced))
+	}
+	return nil
+}
+
+// syncCommodityStock 持有商品库存锁将商品在某个仓库的库存增量同步到MySQL，并记录同步的库存流水
+// 锁被占用（正在对账或同步）时不同步并返回false
+func (s *StockCacheService) syncCommodityStock(ctx context.Context, commodityId int, warehouseId int) (bool, error) {
+	return withStockLock(ctx, s.cRedisSvc, commodityId, func() error {
+		_, err := s.cRedisSvc.SyncStock(ctx, commodityId, warehouseId, syncMovement)
+		return err
+	})
+}
+
+// AdjustStock 按带符号的调整量调整商品在某个仓库的库存（正数入库，负数出库），返回调整后的可售库存
+// 业务流程：
+// 1. 校验调整量不为0、商品和仓库存在
+// 2. Lua脚本原子性地修改Redis库存，并反向修改delta_key（出库时调整后的库存不能小于0），同时记录手动调整的库存流水（reason作为说明）
+// 3. 缓存未初始化时从MySQL加载后重试
+// 4. 立即将增量同步到MySQL；同步失败或锁被占用时增量保留在delta_key中，由同步调度器稍后写回
+//
+// 注意：
+// - 调整与订单扣减在Redis中串行执行，调整期间进行中的订单不会超卖
+// - 出库数量超过当前可售库存时返回ErrInsufficientStock；商品不存在时返回gorm.ErrRecordNotFound；仓库不存在时返回ErrWarehouseNotFound
+func (s *StockCacheService) AdjustStock(ctx context.Context, commodityId int, warehouseId int, delta int, reason string, operator string) (int, error) {
+	if delta == 0 {
+		return 0, ErrInvalidStockDelta
+	}
+	if _, err := s.cRepo.FindCommodityById(commodityId); err != nil {
+		return 0, err
+	}
+	if _, err := s.wRepo.FindWarehouseById(warehouseId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%w: %d", ErrWarehouseNotFound, warehouseId)
+		}
+		return 0, err
+	}
+
+	// 缓存最多初始化一次，因此最多尝试两次
+	for attempt := 0; attempt < 2; attempt++ {
+		movement := &repository.MovementInfo{Reason: model.MovementManualAdjust, Operator: operator, Note: reason}
+		code, stock, err := s.cRedisSvc.AdjustStock(ctx, commodityId, warehouseId, delta, movement)
+		switch code {
+		case 0: // 调整成功
+			if locked, err := s.syncCommodityStock(ctx, commodityId, warehouseId); err != nil {
+				log.Warningf("Failed to sync adjusted stock of commodity %d in warehouse %d, will be synced by scheduler: %v", commodityId, warehouseId, err)
+			} else if !locked {
+				log.Infof("Stock of commodity %d is locked, adjustment will be synced by scheduler", commodityId)
+			}
+			log.Infof("Stock of commodity %d in warehouse %d adjusted by %d (%s) by %s, now %d", commodityId, warehouseId, delta, reason, operator, stock)
+			return stock, nil
+		case 2: // Redis缓存未初始化，从MySQL加载后重试
+			if err = s.LoadStockCache(ctx, commodityId, warehouseId); err != nil {
+				return 0, err
+			}
+		case 3: // 出库数量超过可售库存
+			return 0, fmt.Errorf("%w: commodity %d has %d in warehouse %d", ErrInsufficientStock, commodityId, stock, warehouseId)
+		default:
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("failed to initialize stock cache")
+}
+
+// AvailableStock 返回商品在某个仓库的当前可售库存
+// 缓存存在时为Redis中的实时库存，否则为MySQL中的仓库库存减去尚未同步的增量
+func (s *StockCacheService) AvailableStock(ctx context.Context, commodityId int, warehouseId int, dbStock int) (int, error) {
+	snapshot, err := s.cRedisSvc.GetStockSnapshot(ctx, commodityId, warehouseId)
+	if err != nil {
+		return 0, err
+	}
+	if snapshot.Exists {
+		return snapshot.Stock, nil
+	}
+	return dbStock - snapshot.Delta, nil
+}
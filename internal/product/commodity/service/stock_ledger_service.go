@@ -0,0 +1,29 @@
+package service
+
+import (
+	"fmt"
+	"server/internal/product/commodity/model"
+	"server/internal/product/commodity/repository"
+)
+
+// OperatorSystem 系统操作人，用于超时取消、库存同步等非人工操作
+const OperatorSystem = "system"
+
+// StockLedgerService 提供库存流水的查询服务
+// 流水由库存仓储在变动库存的同一原子操作中写入，见repository.MovementInfo
+type StockLedgerService struct {
+	mRepo repository.StockMovementRepository
+}
+
+// NewStockLedgerService 创建一个新的库存流水服务实例
+func NewStockLedgerService(mRepo repository.StockMovementRepository) *StockLedgerService {
+	return &StockLedgerService{mRepo: mRepo}
+}
+
+// ListMovements 分页查询商品的库存流水
+func (s *StockLedgerService) ListMovements(query repository.StockMovementQuery) ([]*model.StockMovement, int64, error) {
+	if query.Reason != "" && !query.Reason.IsValid() {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidMovementReason, query.Reason)
+	}
+	return s.mRepo.FindMovements(query)
+}
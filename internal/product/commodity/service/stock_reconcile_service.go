@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"server/internal/product/commodity/model"
+	"server/internal/product/commodity/repository"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// stockLockTTL 商品库存锁的超时时间，持有者崩溃时锁自动释放
+const stockLockTTL = time.Second * 10
+
+// StockDiscrepancy 单个商品在某个仓库的Redis库存缓存与MySQL库存的差异
+type StockDiscrepancy struct {
+	CommodityId   int    // 商品ID
+	WarehouseId   int    // 仓库ID
+	DBStock       int    // MySQL中该仓库的库存
+	CacheStock    int    // Redis中的实时库存（缓存缺失时为0）
+	PendingDelta  int    // 尚未同步到MySQL的库存增量
+	Missing       bool   // 上架商品在启用仓库的Redis缓存缺失
+	Repaired      bool   // 是否已修复
+	RepairedStock int    // 修复后的Redis库存
+	Error         string // 修复失败的原因
+}
+
+// StockReconcileReport 一次库存对账的汇总结果
+type StockReconcileReport struct {
+	Repair        bool               // 是否修复差异
+	Checked       int                // 已检查的商品数
+	Skipped       []int              // 因库存锁被占用而跳过的商品ID，下次对账时再检查
+	Failed        []int              // 检查过程中出错的商品ID
+	Repaired      int                // 已修复的商品数
+	Discrepancies []StockDiscrepancy // 发现的差异
+	StartedAt     time.Time          // 开始时间
+	FinishedAt    time.Time          // 结束时间
+}
+
+// StockReconcileService 提供Redis库存缓存与MySQL库存的对账服务
+// 对账依据：没有同步进行时，每个仓库的 Redis库存 + 未同步的增量 应等于MySQL中该仓库的库存
+// 同步调度器在重置增量和更新MySQL之间会短暂打破这一关系，因此对账与同步使用同一把商品库存锁互斥
+type StockReconcileService struct {
+	cRedisRepo repository.StockCacheRepository
+	cRepo      repository.CommodityRepository
+	wRepo      repository.WarehouseRepository
+}
+
+// NewStockReconcileService 创建一个新的库存对账服务实例
+func NewStockReconcileService(cRedisRepo repository.StockCacheRepository, cRepo repository.CommodityRepository, wRepo repository.WarehouseRepository) *StockReconcileService {
+	return &StockReconcileService{cRedisRepo: cRedisRepo, cRepo: cRepo, wRepo: wRepo}
+}
+
+// ReconcileStock 对比所有商品在各仓库的Redis库存缓存与MySQL库存，返回差异汇总
+// 业务流程：
+// 1. 查询所有商品和仓库
+// 2. 逐个获取商品库存锁，锁被占用（正在同步）的商品跳过
+// 3. 在锁内逐个仓库重新读取MySQL库存，并原子性读取Redis库存和未同步的增量
+// 4. Redis库存不等于 MySQL库存 - 增量，或上架商品在启用仓库的缓存缺失时记为差异
+// 5. repair为true时，将Redis库存修复为 MySQL库存 - 增量（保留增量，由同步调度器继续写回MySQL）
+//
+// 注意：
+// - 商品在仓库没有库存记录时MySQL库存按0计算
+// - 下架商品或停用仓库没有缓存且没有未同步增量时视为正常
+// - 单个商品出错只记录到报告中，不影响其他商品
+func (s *StockReconcileService) ReconcileStock(ctx context.Context, repair bool) (*StockReconcileReport, error) {
+	commodities, err := s.cRepo.ListCommodity()
+	if err != nil {
+		return nil, err
+	}
+	warehouses, err := s.wRepo.ListWarehouses()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StockReconcileReport{
+		Repair:        repair,
+		Skipped:       make([]int, 0),
+		Failed:        make([]int, 0),
+		Discrepancies: make([]StockDiscrepancy, 0),
+		StartedAt:     time.Now(),
+	}
+	for _, commodity := range commodities {
+		locked, err := withStockLock(ctx, s.cRedisRepo, commodity.ID, func() error {
+			return s.reconcileCommodity(ctx, commodity.ID, warehouses, repair, report)
+		})
+		if err != nil {
+			log.Warningf("Failed to reconcile stock of commodity %d: %v", commodity.ID, err)
+			report.Failed = append(report.Failed, commodity.ID)
+			continue
+		}
+		if !locked {
+			report.Skipped = append(report.Skipped, commodity.ID)
+			continue
+		}
+		report.Checked++
+	}
+	report.FinishedAt = time.Now()
+
+	log.Infof("Stock reconcile finished: checked=%d discrepancies=%d repaired=%d skipped=%d failed=%d",
+		report.Checked, len(report.Discrepancies), report.Repaired, len(report.Skipped), len(report.Failed))
+	return report, nil
+}
+
+// reconcileCommodity 在持有商品库存锁时对账单个商品在所有仓库的库存，发现差异时追加到报告中
+func (s *StockReconcileService) reconcileCommodity(ctx context.Context, commodityId int, warehouses []*model.Warehouse, repair bool, report *StockReconcileReport) error {
+	// 在锁内重新读取，避免使用列表查询后被同步调度器更新过的旧值
+	commodity, err := s.cRepo.FindCommodityById(commodityId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 商品在对账期间被删除，无需对账
+			return nil
+		}
+		return err
+	}
+	stocks, err := s.wRepo.FindWarehouseStocksByCommodityIds([]int{commodityId})
+	if err != nil {
+		return err
+	}
+	dbStocks := make(map[int]int, len(stocks))
+	for _, stock := range stocks {
+		dbStocks[stock.WarehouseId] = stock.Stock
+	}
+
+	for _, warehouse := range warehouses {
+		dbStock := dbStocks[warehouse.Id]
+		snapshot, err := s.cRedisRepo.GetStockSnapshot(ctx, commodityId, warehouse.Id)
+		if err != nil {
+			return err
+		}
+
+		if !snapshot.Exists {
+			if (!commodity.Status || !warehouse.Enabled) && snapshot.Delta == 0 {
+				continue
+			}
+		} else if snapshot.Stock+snapshot.Delta == dbStock {
+			continue
+		}
+
+		discrepancy := StockDiscrepancy{
+			CommodityId:  commodityId,
+			WarehouseId:  warehouse.Id,
+			DBStock:      dbStock,
+			CacheStock:   snapshot.Stock,
+			PendingDelta: snapshot.Delta,
+			Missing:      !snapshot.Exists,
+		}
+		if repair {
+			repaired, err := s.cRedisRepo.RepairStockCache(ctx, commodityId, warehouse.Id, dbStock)
+			if err != nil {
+				discrepancy.Error = err.Error()
+			} else {
+				discrepancy.Repaired = true
+				discrepancy.RepairedStock = repaired
+				report.Repaired++
+			}
+		}
+		log.Warningf("Stock discrepancy of commodity %d in warehouse %d: db=%d cache=%d delta=%d missing=%t repaired=%t",
+			commodityId, warehouse.Id, discrepancy.DBStock, discrepancy.CacheStock, discrepancy.PendingDelta, discrepancy.Missing, discrepancy.Repaired)
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+	}
+	return nil
+}
+
+// withStockLock 持有商品库存锁执行fn，锁已被其他进程持有时不执行fn并返回false
+func withStockLock(ctx context.Context, cRedisRepo repository.StockCacheRepository, commodityId int, fn func() error) (bool, error) {
+	token, ok, err := cRedisRepo.LockStock(ctx, commodityId, stockLockTTL)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer func() {
+		if err := cRedisRepo.UnlockStock(ctx, commodityId, token); err != nil {
+			log.Warningf("Failed to unlock stock of commodity %d: %v", commodityId, err)
+		}
+	}()
+	return true, fn()
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"server/config"
+	"server/internal/product/commodity/model"
+	"server/internal/product/commodity/repository"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 下单时的仓库分配策略
+const (
+	AllocationPriority  = "priority"   // 按仓库优先级
+	AllocationMostStock = "most_stock" // 订单商品可售库存之和最多的仓库优先
+	AllocationNearest   = "nearest"    // 仓库地区与收货地址匹配的优先，其余按优先级
+)
+
+// CommodityWarehouseStock 商品在某个仓库的库存
+type CommodityWarehouseStock struct {
+	Warehouse *model.Warehouse
+	Stock     int // MySQL中的库存
+	Available int // 当前可售库存（含尚未同步到MySQL的扣减）
+}
+
+// WarehouseService 提供仓库管理和下单时仓库分配的业务逻辑服务
+type WarehouseService struct {
+	wRepo         repository.WarehouseRepository
+	stockCacheSvc *StockCacheService
+	strategy      string
+}
+
+// NewWarehouseService 创建一个新的仓库服务实例，未知的分配策略回退为按优先级分配
+func NewWarehouseService(wRepo repository.WarehouseRepository, stockCacheSvc *StockCacheService, cfg *config.Config) *WarehouseService {
+	strategy := cfg.Warehouse.AllocationStrategy
+	switch strategy {
+	case AllocationPriority, AllocationMostStock, AllocationNearest:
+	default:
+		log.Warningf("Unknown warehouse allocation strategy %q, fallback to %s", strategy, AllocationPriority)
+		strategy = AllocationPriority
+	}
+	return &WarehouseService{wRepo: wRepo, stockCacheSvc: stockCacheSvc, strategy: strategy}
+}
+
+// CreateWarehouse 创建仓库，编码为空或已存在时返回ErrInvalidWarehouse
+// 新仓库的库存缓存在下单或库存调整时按需加载
+func (s *WarehouseService) CreateWarehouse(warehouse *model.Warehouse) error {
+	warehouse.Code = strings.TrimSpace(warehouse.Code)
+	if warehouse.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidWarehouse)
+	}
+	if _, err := s.wRepo.FindWarehouseByCode(warehouse.Code); err == nil {
+		return fmt.Errorf("%w: code %s already exists", ErrInvalidWarehouse, warehouse.Code)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	warehouse.CreatedAt = time.Now()
+	warehouse.UpdatedAt = time.Now()
+	return s.wRepo.CreateWarehouse(warehouse)
+}
+
+// UpdateWarehouse 更新仓库的名称、地区、优先级和启用状态，仓库编码不允许修改
+func (s *WarehouseService) UpdateWarehouse(warehouse *model.Warehouse) error {
+	if _, err := s.wRepo.FindWarehouseById(warehouse.Id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWarehouseNotFound
+		}
+		return err
+	}
+	warehouse.UpdatedAt = time.Now()
+	return s.wRepo.UpdateWarehouse(warehouse)
+}
+
+// ListWarehouses 获取所有仓库
+func (s *WarehouseService) ListWarehouses() ([]*model.Warehouse, error) {
+	return s.wRepo.ListWarehouses()
+}
+
+// ListCommodityStocks 获取商品在所有仓库的库存
+func (s *WarehouseService) ListCommodityStocks(ctx context.Context, commodityId int) ([]*CommodityWarehouseStock, error) {
+	warehouses, err := s.wRepo.ListWarehouses()
+	if err != nil {
+		return nil, err
+	}
+	dbStocks, err := s.findDBStocks([]int{commodityId})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*CommodityWarehouseStock, 0, len(warehouses))
+	for _, warehouse := range warehouses {
+		stock := dbStocks[stockKey(commodityId, warehouse.Id)]
+		available, err := s.stockCacheSvc.AvailableStock(ctx, commodityId, warehouse.Id, stock)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &CommodityWarehouseStock{Warehouse: warehouse, Stock: stock, Available: available})
+	}
+	return result, nil
+}
+
+// AllocationCandidates 按配置的分配策略返回下单时依次尝试的启用仓库ID
+// 调用方按顺序尝试在仓库中扣减整单库存，第一个库存充足的仓库即为订单的发货仓库
+func (s *WarehouseService) AllocationCandidates(ctx context.Context, address string, items []repository.StockItem) ([]int, error) {
+	warehouses, err := s.wRepo.ListWarehouses()
+	if err != nil {
+		return nil, err
+	}
+	enabled := make([]*model.Warehouse, 0, len(warehouses))
+	for _, warehouse := range warehouses {
+		if warehouse.Enabled {
+			enabled = append(enabled, warehouse)
+		}
+	}
+	// ListWarehouses已按优先级排序，以下排序均为稳定排序，同等条件下保持优先级顺序
+	switch s.strategy {
+	case AllocationMostStock:
+		if err = s.sortByStock(ctx, enabled, items); err != nil {
+			return nil, err
+		}
+	case AllocationNearest:
+		sort.SliceStable(enabled, func(i, j int) bool {
+			return matchRegion(enabled[i], address) && !matchRegion(enabled[j], address)
+		})
+	}
+
+	candidates := make([]int, 0, len(enabled))
+	for _, warehouse := range enabled {
+		candidates = append(candidates, warehouse.Id)
+	}
+	return candidates, nil
+}
+
+// sortByStock 按订单商品在仓库的可售库存之和从多到少排序
+func (s *WarehouseService) sortByStock(ctx context.Context, warehouses []*model.Warehouse, items []repository.StockItem) error {
+	commodityIds := make([]int, 0, len(items))
+	for _, item := range items {
+		commodityIds = append(commodityIds, item.CommodityId)
+	}
+	dbStocks, err := s.findDBStocks(commodityIds)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[int]int, len(warehouses))
+	for _, warehouse := range warehouses {
+		for _, item := range items {
+			available, err := s.stockCacheSvc.AvailableStock(ctx, item.CommodityId, warehouse.Id, dbStocks[stockKey(item.CommodityId, warehouse.Id)])
+			if err != nil {
+				return err
+			}
+			totals[warehouse.Id] += available
+		}
+	}
+	sort.SliceStable(warehouses, func(i, j int) bool {
+		return totals[warehouses[i].Id] > totals[warehouses[j].Id]
+	})
+	return nil
+}
+
+// findDBStocks 查询商品在各仓库的MySQL库存，key为stockKey(商品ID, 仓库ID)
+func (s *WarehouseService) findDBStocks(commodityIds []int) (map[string]int, error) {
+	stocks, err := s.wRepo.FindWarehouseStocksByCommodityIds(commodityIds)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(stocks))
+	for _, stock := range stocks {
+		result[stockKey(stock.CommodityId, stock.WarehouseId)] = stock.Stock
+	}
+	return result, nil
+}
+
+// stockKey 商品在某个仓库的库存的map key
+func stockKey(commodityId int, warehouseId int) string {
+	return fmt.Sprintf("%d_%d", commodityId, warehouseId)
+}
+
+// matchRegion 判断收货地址是否位于仓库所在地区
+func matchRegion(warehouse *model.Warehouse, address string) bool {
+	return warehouse.Region != "" && strings.Contains(address, warehouse.Region)
+}
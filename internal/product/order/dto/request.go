@@ -1,11 +1,19 @@
 package dto
 
+import "time"
+
+// OrderItemRequest 订单行请求
+type OrderItemRequest struct {
+	CommodityId int `json:"commodity_id" binding:"required"`
+	Quantity    int `json:"quantity" binding:"required,min=1"`
+}
+
 // CreateOrderRequest 创建订单请求
 type CreateOrderRequest struct {
-	CommodityId int    `json:"commodity_id" binding:"required"`
-	Quantity    int    `json:"quantity" binding:"required"`
-	TotalPrice  string `json:"total_price" binding:"required"`
-	Address     string `json:"address" binding:"required"`
+	Items      []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	CouponCode string             `json:"coupon_code"` // 可选，优惠券券码
+	TotalPrice string             `json:"total_price"` // 可选，客户端展示的应付金额（如"12.34"，已扣除优惠），提供时会与服务端计算结果校验
+	Address    string             `json:"address" binding:"required"`
 }
 
 // UpdateOrderRequest 更新订单请求
@@ -13,3 +21,26 @@ type UpdateOrderRequest struct {
 	Status  string `json:"status"`
 	Address string `json:"address"`
 }
+
+// AdminUpdateOrderStatusRequest 管理员更新订单状态请求
+type AdminUpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=shipped completed"`
+}
+
+// ListOrderRequest 查询订单列表请求（Query参数）
+type ListOrderRequest struct {
+	Status    string    `form:"status"`                                      // 订单状态过滤，为空时返回全部状态
+	StartTime time.Time `form:"start_time"`                                  // 创建时间下限（RFC3339，包含）
+	EndTime   time.Time `form:"end_time"`                                    // 创建时间上限（RFC3339，不包含）
+	Sort      string    `form:"sort" binding:"omitempty,oneof=asc desc"`     // 按创建时间排序：asc升序、desc降序（默认）
+	Page      int       `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize  int       `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
+
+// ListArchivedOrderRequest 查询归档订单列表请求（Query参数）
+type ListArchivedOrderRequest struct {
+	UserId   int    `form:"user_id"`                                     // 用户ID过滤，为空时返回所有用户
+	Status   string `form:"status"`                                      // 订单状态过滤，为空时返回全部状态
+	Page     int    `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
@@ -1,8 +1,38 @@
 package dto
 
-import "server/internal/product/order/model"
+import (
+	"server/internal/product/order/model"
+	"time"
+)
 
 // OrderResponse 订单响应
 type OrderResponse struct {
 	Order *model.Order `json:"order"`
 }
+
+// OrderListResponse 订单列表响应
+type OrderListResponse struct {
+	Orders []*model.Order `json:"orders"`
+}
+
+// PaymentDeadlineResponse 待支付订单的支付截止时间响应
+type PaymentDeadlineResponse struct {
+	OrderNo          string    `json:"order_no"`
+	PayDeadline      time.Time `json:"pay_deadline"`      // 支付截止时间，超过后订单自动取消
+	RemainingSeconds int64     `json:"remaining_seconds"` // 剩余支付时间（秒），已到期时为0
+}
+
+// OrderEventListResponse 订单事件历史响应
+type OrderEventListResponse struct {
+	Events []*model.OrderEvent `json:"events"`
+}
+
+// ArchivedOrderResponse 归档订单响应
+type ArchivedOrderResponse struct {
+	Order *model.ArchivedOrder `json:"order"`
+}
+
+// ArchivedOrderListResponse 归档订单列表响应
+type ArchivedOrderListResponse struct {
+	Orders []*model.ArchivedOrder `json:"orders"`
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"server/internal/product/order/dto"
+	"server/internal/product/order/model"
+	"server/internal/product/order/repository"
+	"server/internal/product/order/service"
+	"server/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderArchiveHandler 处理归档订单相关的HTTP请求（仅管理员）
+type OrderArchiveHandler struct {
+	archiveSvc *service.OrderArchiveService
+}
+
+// NewOrderArchiveHandler 创建一个新的归档订单处理器实例
+func NewOrderArchiveHandler(archiveSvc *service.OrderArchiveService) *OrderArchiveHandler {
+	return &OrderArchiveHandler{archiveSvc: archiveSvc}
+}
+
+// ListArchivedOrders 处理管理员查询归档订单列表请求
+// Query参数示例：/admin/archived-order?user_id=12&status=completed&page=1&page_size=20
+func (h *OrderArchiveHandler) ListArchivedOrders(c *gin.Context) {
+	var req dto.ListArchivedOrderRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	query := repository.ArchivedOrderQuery{
+		UserId: req.UserId,
+		Status: model.OrderStatus(req.Status),
+		Offset: (req.Page - 1) * req.PageSize,
+		Limit:  req.PageSize,
+	}
+	orders, total, err := h.archiveSvc.ListArchivedOrders(query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrderStatus) {
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.ArchivedOrderListResponse{Orders: orders}, response.NewPagination(req.Page, req.PageSize, total))
+}
+
+// GetArchivedOrder 处理管理员查询归档订单详情请求（包含订单行快照）
+func (h *OrderArchiveHandler) GetArchivedOrder(c *gin.Context) {
+	orderNo := c.Param("order_no")
+
+	order, err := h.archiveSvc.GetArchivedOrder(orderNo)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.ArchivedOrderResponse{Order: order})
+}
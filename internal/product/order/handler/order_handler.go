@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"server/internal/product/order/dto"
+	"server/internal/product/order/model"
+	"server/internal/product/order/repository"
 	"server/internal/product/order/service"
+	promotionService "server/internal/product/promotion/service"
 	"server/pkg/response"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -23,13 +27,13 @@ func NewOrderHandler(oSvc *service.OrderService) *OrderHandler {
 // CreateOrder 处理创建订单请求
 // 业务流程：
 // 1. 从JWT中间件获取已认证的用户ID
-// 2. 解析请求体中的订单信息（商品ID、数量、总价、地址）
-// 3. 调用Service层创建订单（包含：扣减Redis库存、创建订单记录、加入延迟取消队列）
-// 4. 返回创建结果
+// 2. 解析请求体中的订单信息（订单行列表、地址，以及可选的券码和用于校验的客户端总价）
+// 3. 调用Service层创建订单（包含：占用优惠券、批量扣减Redis库存、创建订单头和订单行、加入延迟取消队列）
+// 4. 返回创建的订单
 //
 // 注意：
-// - 订单创建后会自动加入15分钟延迟队列，超时未支付将自动取消并归还库存
-// - 库存扣减失败（库存不足或Redis未初始化）会直接返回错误，不会创建订单
+// - 订单创建后会自动加入延迟队列，超过支付时限（见响应中的pay_deadline）未支付将自动取消并归还所有订单行的库存
+// - 所有订单行的库存扣减是原子的，任一商品库存不足都不会创建订单
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	// 从JWT中间件注入的上下文中获取认证后的userID
 	userID, exists := c.Get("userID")
@@ -47,41 +51,77 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	items := make([]model.OrderItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, model.OrderItem{CommodityId: item.CommodityId, Quantity: item.Quantity})
+	}
+
 	// 调用Service层创建订单
-	// 内部流程：扣减Redis库存 -> 创建订单记录 -> 加入延迟取消队列
-	err = h.oSvc.CreateOrder(uid, req.CommodityId, req.Quantity, req.TotalPrice, req.Address)
+	// 内部流程：批量扣减Redis库存 -> 创建订单记录 -> 加入延迟取消队列
+	order, err := h.oSvc.CreateOrder(uid, items, req.CouponCode, req.TotalPrice, req.Address)
 	if err != nil {
-		response.InternalServerError(c, response.CodeInternalError, err.Error())
+		switch {
+		case errors.Is(err, service.ErrInsufficientStock):
+			response.BadRequest(c, response.CodeInsufficientStock, err.Error())
+		case errors.Is(err, service.ErrCommodityNotFound):
+			response.BadRequest(c, response.CodeCommodityNotFound, err.Error())
+		case errors.Is(err, service.ErrPriceMismatch):
+			response.BadRequest(c, response.CodeOrderPriceMismatch, err.Error())
+		case errors.Is(err, service.ErrInvalidTotalPrice):
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		case errors.Is(err, service.ErrEmptyOrderItems):
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		case errors.Is(err, promotionService.ErrCouponNotFound):
+			response.BadRequest(c, response.CodeCouponNotFound, err.Error())
+		case errors.Is(err, promotionService.ErrCouponNotApplicable):
+			response.BadRequest(c, response.CodeCouponNotApplicable, err.Error())
+		case errors.Is(err, promotionService.ErrCouponExhausted):
+			response.BadRequest(c, response.CodeCouponExhausted, err.Error())
+		case errors.Is(err, promotionService.ErrCouponUserLimit):
+			response.BadRequest(c, response.CodeCouponUserLimit, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleNotStarted):
+			response.BadRequest(c, response.CodeFlashSaleNotStarted, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleSoldOut):
+			response.BadRequest(c, response.CodeFlashSaleSoldOut, err.Error())
+		case errors.Is(err, promotionService.ErrFlashSaleUserLimit):
+			response.BadRequest(c, response.CodeFlashSaleUserLimit, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeOrderCreateFailed, err.Error())
+		}
 		return
 	}
 
-	response.Success(c, nil)
-	log.Info("order create success, userID:", uid, "commodityID:", req.CommodityId)
+	response.Success(c, dto.OrderResponse{Order: order})
+	log.Info("order create success, userID:", uid, " orderNo:", order.OrderNo)
 }
 
 // UpdateOrderStatus 处理更新订单请求（状态或地址）
 // 业务流程：
-// 1. 从URL路径中提取订单ID
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
 // 2. 解析请求体，支持更新状态和地址
 // 3. 根据请求内容选择性更新（状态和地址可以单独或同时更新）
 //
-// 支持的订单状态：
-// - pending: 待支付
-// - paid: 已支付
-// - cancelled: 已取消
-// - completed: 已完成
+// 买家只能通过此接口执行以下状态流转（见model.OrderStatus）：
+// - pending → cancelled（取消订单）
+// - shipped → completed（确认收货）
+// 非法流转返回CodeOrderIllegalTransition，订单不存在或不属于当前用户返回404
+// paid状态只能由支付回调写入（POST /payment/callback），shipped只能由管理员写入（PUT /admin/order/:order_no/status），
+// 退款相关状态只能由退款审批写入，通过此接口设置会被拒绝
 func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
-	// 从URL路径参数中获取订单ID（如：/order/123）
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
 		return
 	}
+	uid := userID.(int)
+
+	// 从URL路径参数中获取订单号（如：/order/1234567890123456789）
+	orderNo := c.Param("order_no")
 
 	// 解析请求体，获取要更新的字段
 	var req dto.UpdateOrderRequest
-	err = c.ShouldBindJSON(&req)
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		response.BadRequest(c, response.CodeInvalidJSON, "invalid JSON")
 		return
@@ -89,17 +129,25 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 
 	// 如果提供了状态字段，则更新订单状态
 	if req.Status != "" {
-		err = h.oSvc.UpdateOrderStatus(id, req.Status)
+		err = h.oSvc.UpdateOrderStatus(uid, orderNo, model.OrderStatus(req.Status))
 		if err != nil {
-			response.InternalServerError(c, response.CodeInternalError, "server busy")
+			handleUpdateStatusError(c, err)
 			return
 		}
 	}
 
 	// 如果提供了地址字段，则更新收货地址
 	if req.Address != "" {
-		err = h.oSvc.UpdateOrderAddress(id, req.Address)
+		err = h.oSvc.UpdateOrderAddress(uid, orderNo, req.Address)
 		if err != nil {
+			if errors.Is(err, service.ErrOrderNotFound) {
+				response.NotFound(c, response.CodeOrderNotFound, err.Error())
+				return
+			}
+			if errors.Is(err, service.ErrAddressNotEditable) {
+				response.BadRequest(c, response.CodeAddressNotEditable, err.Error())
+				return
+			}
 			response.InternalServerError(c, response.CodeInternalError, "server busy")
 			return
 		}
@@ -110,58 +158,149 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	return
 }
 
+// AdminUpdateOrderStatus 处理管理员更新订单履约状态请求（仅管理员）
+// 请求体示例：{"status": "shipped"}
+// 管理员只能发货（paid → shipped）或代买家确认收货（shipped → completed），状态流转记录到订单事件中，操作人为管理员账号
+func (h *OrderHandler) AdminUpdateOrderStatus(c *gin.Context) {
+	orderNo := c.Param("order_no")
+
+	var req dto.AdminUpdateOrderStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	account := c.GetString("account")
+	if err := h.oSvc.AdminUpdateOrderStatus(account, orderNo, model.OrderStatus(req.Status)); err != nil {
+		handleUpdateStatusError(c, err)
+		return
+	}
+
+	response.Success(c, nil)
+	log.Infof("admin %s updated order %s to %s", account, orderNo, req.Status)
+}
+
+// handleUpdateStatusError 将订单状态更新的错误转换为HTTP响应
+func handleUpdateStatusError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderNotFound):
+		response.NotFound(c, response.CodeOrderNotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidOrderStatus):
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+	case errors.Is(err, service.ErrIllegalTransition):
+		response.BadRequest(c, response.CodeOrderIllegalTransition, err.Error())
+	default:
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+	}
+}
+
+// CancelOrder 处理买家取消订单请求
+// 业务流程：
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
+// 2. 调用Service层取消订单（校验归属和状态、置为cancelled、归还库存、移除延迟任务）
+// 3. 返回取消结果
+//
+// 注意：
+// - 只能取消自己的待支付订单，已支付订单需走退款流程
+// - 与超时取消共用幂等性键，并发情况下库存也只会归还一次
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	orderNo := c.Param("order_no")
+
+	err := h.oSvc.CancelOrder(uid, orderNo)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+		case errors.Is(err, service.ErrIllegalTransition):
+			response.BadRequest(c, response.CodeOrderIllegalTransition, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	response.SuccessWithMessage(c, "cancel success", nil)
+	log.Info("order cancel success:", orderNo)
+}
+
 // DeleteOrder 处理删除订单请求
 // 业务流程：
-// 1. 从URL路径中提取订单ID
-// 2. 调用Service层删除订单
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
+// 2. 调用Service层删除订单（只能删除自己的订单）
 //
 // 注意：
-// - 此方法仅删除订单记录，不会自动归还库存
-// - 建议在删除前先确认订单状态，避免误删已支付订单
-// - 如需取消订单并归还库存，应使用订单取消功能而非直接删除
+// - 订单不存在或不属于当前用户时返回404
+// - 只能删除已结束的订单（已完成、已取消、已全额退款），其他状态返回CodeOrderNotDeletable
+// - 删除为软删除：订单对买家隐藏，但仍保留用于对账，超过保留期后由归档任务迁移到归档表
+// - 如需取消订单并归还库存，应使用订单取消接口（POST /order/:id/cancel）
 func (h *OrderHandler) DeleteOrder(c *gin.Context) {
-	// 从URL路径参数中获取订单ID
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
 		return
 	}
+	uid := userID.(int)
+
+	// 从URL路径参数中获取订单号
+	orderNo := c.Param("order_no")
 
 	// 调用Service层删除订单
-	err = h.oSvc.DeleteOrder(id)
+	err := h.oSvc.DeleteOrder(uid, orderNo)
 	if err != nil {
-		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+		case errors.Is(err, service.ErrOrderNotDeletable):
+			response.BadRequest(c, response.CodeOrderNotDeletable, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
 		return
 	}
 
 	response.SuccessWithMessage(c, "delete success", nil)
-	log.Info("order delete success:", id)
+	log.Info("order delete success:", orderNo)
 	return
 }
 
 // GetOrder 处理获取订单详情请求
 // 业务流程：
-// 1. 从URL路径中提取订单ID
-// 2. 调用Service层查询订单详情
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
+// 2. 调用Service层查询订单详情（只能查询自己的订单，否则返回404）
 // 3. 将订单模型转换为响应DTO并返回
 //
 // 返回内容包括：
-// - 订单ID、用户ID、商品ID
-// - 商品数量、总价、收货地址
+// - 订单号、用户ID、商品ID
+// - 订单行（商品、数量、单价、小计）、总金额、币种、收货地址
 // - 订单状态、创建时间、更新时间
 func (h *OrderHandler) GetOrder(c *gin.Context) {
-	// 从URL路径参数中获取订单ID
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
 		return
 	}
+	uid := userID.(int)
+
+	// 从URL路径参数中获取订单号
+	orderNo := c.Param("order_no")
 
 	// 调用Service层查询订单详情
-	order, err := h.oSvc.GetOrderById(id)
+	order, err := h.oSvc.GetUserOrder(uid, orderNo)
 	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+			return
+		}
 		response.InternalServerError(c, response.CodeInternalError, "server busy")
 		return
 	}
@@ -169,6 +308,136 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	// 封装响应数据
 	res := dto.OrderResponse{Order: order}
 	response.Success(c, res)
-	log.Info("order get success:", id)
+	log.Info("order get success:", orderNo)
 	return
 }
+
+// ListOrders 处理查询当前用户订单列表请求
+// 业务流程：
+// 1. 从JWT中间件获取已认证的用户ID
+// 2. 解析Query参数（状态过滤、创建时间范围、排序方向、页码、每页条数）
+// 3. 调用Service层分页查询该用户的订单
+// 4. 返回订单列表，分页信息放在响应的pagination字段中
+//
+// Query参数示例：
+// /order?status=pending&start_time=2025-01-01T00:00:00Z&sort=asc&page=2&page_size=10
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	var req dto.ListOrderRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	query := repository.OrderQuery{
+		Status:    model.OrderStatus(req.Status),
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Ascending: req.Sort == "asc",
+		Offset:    (req.Page - 1) * req.PageSize,
+		Limit:     req.PageSize,
+	}
+	orders, total, err := h.oSvc.GetOrdersByUserId(uid, query)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOrderStatus) {
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.OrderListResponse{Orders: orders}, response.NewPagination(req.Page, req.PageSize, total))
+	log.Info("user ", uid, " list orders success")
+}
+
+// GetPaymentDeadline 处理查询待支付订单剩余支付时间请求（只能查询自己的订单）
+// 截止时间读取自延迟队列中取消任务的执行时间，客户端可据此展示倒计时
+// 订单不是待支付状态时返回CodeOrderNotPending
+func (h *OrderHandler) GetPaymentDeadline(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	orderNo := c.Param("order_no")
+
+	order, deadline, err := h.oSvc.GetPaymentDeadline(uid, orderNo)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+		case errors.Is(err, service.ErrOrderNotPending):
+			response.BadRequest(c, response.CodeOrderNotPending, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	remaining := int64(time.Until(deadline).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	response.Success(c, dto.PaymentDeadlineResponse{OrderNo: order.OrderNo, PayDeadline: deadline, RemainingSeconds: remaining})
+}
+
+// ListOrderEvents 处理查询订单事件历史请求（只能查询自己的订单）
+// 返回订单从创建开始的所有变更记录（创建、状态变更、地址修改、取消、退款），按发生顺序排列
+func (h *OrderHandler) ListOrderEvents(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	orderNo := c.Param("order_no")
+
+	events, err := h.oSvc.GetOrderEvents(uid, orderNo)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.OrderEventListResponse{Events: events})
+}
+
+// AdminListOrderEvents 处理管理员查询任意订单事件历史请求，供客服还原订单的变更过程
+func (h *OrderHandler) AdminListOrderEvents(c *gin.Context) {
+	orderNo := c.Param("order_no")
+
+	events, err := h.oSvc.GetOrderEventsForAdmin(orderNo)
+	if err != nil {
+		if errors.Is(err, service.ErrOrderNotFound) {
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.OrderEventListResponse{Events: events})
+	log.Infof("admin %s list events of order %s", c.GetString("account"), orderNo)
+}
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"server/pkg/money"
+	"time"
+)
+
+// ArchivedOrder 归档订单模型，已结束的订单超过保留期后从orders表迁移到archived_orders表
+// 订单头字段原样保留，订单行以JSON快照的形式存储在同一行中
+type ArchivedOrder struct {
+	Id                int    `gorm:"primary_key;autoIncrement:false" json:"-"` // 与原订单ID相同
+	OrderNo           string `gorm:"uniqueIndex;size:32"`                      // 订单号
+	UserId            int
+	TotalAmount       money.Amount
+	DiscountAmount    money.Amount
+	PayAmount         money.Amount
+	CouponId          int
+	Currency          string
+	Address           string
+	WarehouseId       int
+	Status            OrderStatus
+	CancelReason      string
+	CancelledAt       *time.Time
+	Items             OrderItemsSnapshot // 订单行快照
+	CustomerDeletedAt *time.Time         // 买家删除订单的时间，未删除时为空
+	OrderCreatedAt    time.Time          // 原订单创建时间
+	OrderUpdatedAt    time.Time          // 原订单最后更新时间
+	ArchivedAt        time.Time          // 归档时间
+}
+
+// OrderItemsSnapshot 订单行快照，以JSON格式存储在一列中
+type OrderItemsSnapshot []OrderItem
+
+// Value 将订单行快照序列化为JSON写入数据库
+func (s OrderItemsSnapshot) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 从数据库读取JSON并反序列化为订单行快照
+func (s *OrderItemsSnapshot) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("unsupported order items snapshot type %T", value)
+	}
+}
+
+// NewArchivedOrder 根据订单（包含订单行）生成归档记录
+func NewArchivedOrder(order *Order, archivedAt time.Time) *ArchivedOrder {
+	archived := &ArchivedOrder{
+		Id:             order.Id,
+		OrderNo:        order.OrderNo,
+		UserId:         order.UserId,
+		TotalAmount:    order.TotalAmount,
+		DiscountAmount: order.DiscountAmount,
+		PayAmount:      order.PayAmount,
+		CouponId:       order.CouponId,
+		Currency:       order.Currency,
+		Address:        order.Address,
+		WarehouseId:    order.WarehouseId,
+		Status:         order.Status,
+		CancelReason:   order.CancelReason,
+		CancelledAt:    order.CancelledAt,
+		Items:          order.Items,
+		OrderCreatedAt: order.CreatedAt,
+		OrderUpdatedAt: order.UpdatedAt,
+		ArchivedAt:     archivedAt,
+	}
+	if order.DeletedAt.Valid {
+		deletedAt := order.DeletedAt.Time
+		archived.CustomerDeletedAt = &deletedAt
+	}
+	return archived
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// OrderDelayTask 订单延迟任务，仅在库存存储为database时使用（redis存储时延迟队列保存在Redis中）
+// ProcessingUntil为空表示任务在ready队列中，等待ExecuteAt到期；不为空表示任务已被取出处理，超过该时间仍未确认时由RecoveryScheduler移回ready队列
+type OrderDelayTask struct {
+	Id              string     `gorm:"primary_key;size:64"` // 任务ID（订单号）
+	Payload         string     // 任务内容，格式见OrderCancelService
+	ExecuteAt       time.Time  `gorm:"index"` // 执行时间
+	ProcessingUntil *time.Time // 处理超时时间
+	CreatedAt       time.Time
+}
+
+// OrderStockRestoreMark 订单库存已归还的标记，仅在库存存储为database时使用
+// 超时取消和主动取消共用同一个标记，保证同一订单的库存只会被归还一次；过期的标记视为不存在
+type OrderStockRestoreMark struct {
+	OrderNo  string    `gorm:"primary_key;size:32"`
+	ExpireAt time.Time `gorm:"index"`
+}
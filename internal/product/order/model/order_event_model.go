@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// OrderEventType 订单事件类型
+type OrderEventType string
+
+const (
+	EventCreated        OrderEventType = "created"         // 创建订单
+	EventStatusChanged  OrderEventType = "status_changed"  // 订单状态变更（支付、发货、完成等）
+	EventAddressChanged OrderEventType = "address_changed" // 修改收货地址
+	EventCancelled      OrderEventType = "cancelled"       // 取消订单（主动取消、手动取消或超时取消，原因见Detail）
+	EventRefunded       OrderEventType = "refunded"        // 退款（部分退款或全额退款）
+	EventDeleted        OrderEventType = "deleted"         // 买家删除订单（软删除）
+)
+
+// OperatorSystem 系统操作人，用于超时取消、支付回调等非人工操作
+const OperatorSystem = "system"
+
+// OperatorUser 返回用户操作人标识，格式为"user:{userId}"
+func OperatorUser(userId int) string {
+	return "user:" + strconv.Itoa(userId)
+}
+
+// OperatorAdmin 返回管理员操作人标识，格式为"admin:{account}"
+func OperatorAdmin(account string) string {
+	return "admin:" + account
+}
+
+// OrderEvent 订单事件模型，订单的每次变更都追加一条记录，只增不改，用于还原订单的历史
+type OrderEvent struct {
+	Id         int `gorm:"primary_key"`
+	OrderId    int `json:"-"`
+	Type       OrderEventType
+	FromStatus OrderStatus // 变更前的订单状态，创建订单时为空
+	ToStatus   OrderStatus // 变更后的订单状态
+	Operator   string      // 操作人，如user:12、admin:alice、system
+	Detail     string      // 变更详情，如取消原因、新旧地址、退款数量
+	CreatedAt  time.Time
+}
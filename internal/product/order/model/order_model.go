@@ -1,16 +1,56 @@
 package model
 
-import "time"
+import (
+	"server/pkg/money"
+	"time"
 
-// Order 订单模型
-type Order struct{
-	Id          int `gorm:"primary_key"`
-	UserId      int
-	CommodityId int
-	Quantity    int
-	TotalPrice  string
-	Address     string
-	Status      string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	"gorm.io/gorm"
+)
+
+// Order 订单模型（订单头），一个订单可以包含多个商品行
+// 对外只暴露订单号OrderNo，自增ID仅在服务内部使用（关联订单行、支付单等），不出现在接口和响应中
+type Order struct {
+	Id             int    `gorm:"primary_key" json:"-"`
+	OrderNo        string `gorm:"uniqueIndex;size:32"` // 订单号，按时间递增且不可枚举，见pkg/idgen
+	UserId         int
+	TotalAmount    money.Amount // 订单总金额（最小货币单位：分），由服务端根据商品价格计算
+	DiscountAmount money.Amount // 优惠金额（分），未使用优惠券时为0
+	PayAmount      money.Amount // 应付金额（分）= TotalAmount - DiscountAmount
+	CouponId       int          // 使用的优惠券ID，未使用时为0
+	Currency       string       // 币种（ISO 4217 代码）
+	Address        string
+	WarehouseId    int // 发货仓库，下单时按分配策略选定，订单的所有商品从同一仓库扣减库存
+	Status         OrderStatus
+	CancelReason   string      // 取消原因，见CancelReason*常量
+	CancelledAt    *time.Time  // 取消时间
+	PayDeadline    *time.Time  // 支付截止时间，超过后未支付的订单自动取消
+	Items          []OrderItem `gorm:"foreignKey:OrderId"` // 订单行
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt // 软删除时间，买家删除的订单对买家隐藏，但仍保留到归档
+}
+
+// OrderItem 订单行模型，记录订单中单个商品的购买数量和下单时的价格快照
+type OrderItem struct {
+	Id               int `gorm:"primary_key"`
+	OrderId          int `json:"-"`
+	CommodityId      int
+	Quantity         int
+	UnitPrice        money.Amount // 下单时的商品单价（分）
+	Amount           money.Amount // 订单行小计（分）= UnitPrice * Quantity
+	DiscountAmount   money.Amount // 分摊到该订单行的优惠金额（分）
+	FlashSaleId      int          // 下单时参与的秒杀活动ID，未参与时为0；取消订单时据此归还限购计数
+	RefundedQuantity int          // 已退款的数量，不超过Quantity
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// RefundAmount 计算退还该订单行quantity件商品的金额（分），refunded为之前已退款的数量
+// 实付金额（小计减去分摊的优惠）按数量比例计算，最后一件退款时退还剩余的全部实付金额，保证多次部分退款的总额等于实付金额
+func (i *OrderItem) RefundAmount(refunded int, quantity int) money.Amount {
+	paid := i.Amount - i.DiscountAmount
+	if i.Quantity == 0 {
+		return 0
+	}
+	return paid.MulDiv(int64(refunded+quantity), int64(i.Quantity)) - paid.MulDiv(int64(refunded), int64(i.Quantity))
 }
@@ -0,0 +1,76 @@
+package model
+
+// OrderStatus 订单状态
+type OrderStatus string
+
+// 订单生命周期：
+//
+//	pending → paid → shipped → completed
+//	   ↓        ↓        ↓          ↓
+//	cancelled  partially_refunded ←─┘（可多次部分退款）
+//	            ↓
+//	          refunded（paid/shipped/completed也可以直接全额退款）
+const (
+	StatusPending           OrderStatus = "pending"            // 待支付
+	StatusPaid              OrderStatus = "paid"               // 已支付
+	StatusShipped           OrderStatus = "shipped"            // 已发货
+	StatusCompleted         OrderStatus = "completed"          // 已完成
+	StatusCancelled         OrderStatus = "cancelled"          // 已取消（未支付时取消或超时取消）
+	StatusRefunded          OrderStatus = "refunded"           // 已退款
+	StatusPartiallyRefunded OrderStatus = "partially_refunded" // 部分商品已退款
+)
+
+// transitions 订单状态允许的流转关系，未列出的流转均为非法
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusRefunded, StatusPartiallyRefunded},
+	StatusShipped:   {StatusCompleted, StatusRefunded, StatusPartiallyRefunded},
+	StatusCompleted: {StatusRefunded, StatusPartiallyRefunded},
+	// 部分退款后可以继续部分退款，直到所有商品都已退款
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+}
+
+// IsValid 判断是否为已定义的订单状态
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case StatusPending, StatusPaid, StatusShipped, StatusCompleted, StatusCancelled, StatusRefunded, StatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// IsTerminal 判断订单是否已结束（已完成、已取消或已全额退款），只有已结束的订单可以删除和归档
+func (s OrderStatus) IsTerminal() bool {
+	switch s {
+	case StatusCompleted, StatusCancelled, StatusRefunded:
+		return true
+	}
+	return false
+}
+
+// IsAddressEditable 判断订单当前状态能否修改收货地址（待支付或已支付未发货）
+func (s OrderStatus) IsAddressEditable() bool {
+	return s == StatusPending || s == StatusPaid
+}
+
+// IsRefundable 判断订单当前状态能否申请退款（已支付且未全额退款）
+func (s OrderStatus) IsRefundable() bool {
+	return s.CanTransitionTo(StatusRefunded)
+}
+
+// CanTransitionTo 判断订单能否从当前状态流转到目标状态
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 订单取消原因
+const (
+	CancelReasonPaymentTimeout = "payment_timeout" // 超时未支付，由延迟队列自动取消
+	CancelReasonUserCancelled  = "user_cancelled"  // 买家主动取消
+	CancelReasonManual         = "manual"          // 通过订单状态更新接口手动取消
+)
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var allStatuses = []OrderStatus{
+	StatusPending, StatusPaid, StatusShipped, StatusCompleted,
+	StatusCancelled, StatusRefunded, StatusPartiallyRefunded,
+}
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	allowed := map[OrderStatus][]OrderStatus{
+		StatusPending:           {StatusPaid, StatusCancelled},
+		StatusPaid:              {StatusShipped, StatusRefunded, StatusPartiallyRefunded},
+		StatusShipped:           {StatusCompleted, StatusRefunded, StatusPartiallyRefunded},
+		StatusCompleted:         {StatusRefunded, StatusPartiallyRefunded},
+		StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+		StatusCancelled:         nil,
+		StatusRefunded:          nil,
+	}
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			assert.Equalf(t, contains(allowed[from], to), from.CanTransitionTo(to), "%s -> %s", from, to)
+		}
+	}
+	assert.False(t, OrderStatus("unknown").CanTransitionTo(StatusPaid))
+}
+
+func contains(statuses []OrderStatus, status OrderStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func TestOrderStatusPredicates(t *testing.T) {
+	tests := []struct {
+		status          OrderStatus
+		valid           bool
+		terminal        bool
+		addressEditable bool
+		refundable      bool
+	}{
+		{StatusPending, true, false, true, false},
+		{StatusPaid, true, false, true, true},
+		{StatusShipped, true, false, false, true},
+		{StatusCompleted, true, true, false, true},
+		{StatusCancelled, true, true, false, false},
+		{StatusRefunded, true, true, false, false},
+		{StatusPartiallyRefunded, true, false, false, true},
+		{OrderStatus("unknown"), false, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.valid, tt.status.IsValid(), "IsValid")
+			assert.Equal(t, tt.terminal, tt.status.IsTerminal(), "IsTerminal")
+			assert.Equal(t, tt.addressEditable, tt.status.IsAddressEditable(), "IsAddressEditable")
+			assert.Equal(t, tt.refundable, tt.status.IsRefundable(), "IsRefundable")
+		})
+	}
+}
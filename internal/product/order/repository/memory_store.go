@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"sync"
+)
+
+// MemoryStore 订单模块的进程内存储，memory存储模式下由订单、归档订单和订单事件的内存仓储共享
+// 归档时订单的删除和归档记录的写入在同一把锁内完成，与数据库实现的事务语义一致
+// 数据只保存在进程内，重启后丢失
+type MemoryStore struct {
+	mu       sync.RWMutex
+	orders   map[int]*model.Order
+	archived map[int]model.ArchivedOrder
+	events   []model.OrderEvent
+
+	lastOrderId     int
+	lastOrderItemId int
+	lastEventId     int
+}
+
+// NewMemoryStore 创建订单模块的进程内存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:   make(map[int]*model.Order),
+		archived: make(map[int]model.ArchivedOrder),
+	}
+}
+
+// copyOrder 复制订单及其订单行，避免调用方修改返回值时影响存储中的数据
+func copyOrder(order *model.Order) *model.Order {
+	copied := *order
+	copied.Items = append([]model.OrderItem(nil), order.Items...)
+	return &copied
+}
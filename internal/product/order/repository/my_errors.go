@@ -9,6 +9,12 @@ var (
 	// ErrNoTasksDue 队列中没有到期的任务
 	ErrNoTasksDue = errors.New("no tasks are due yet")
 
+	// ErrTaskNotFound 任务不在ready队列中（已被获取处理、已完成或已撤销）
+	ErrTaskNotFound = errors.New("delay task not found")
+
 	// ErrQueueOperationFailed Redis 队列操作失败
 	ErrQueueOperationFailed = errors.New("delay queue operation failed")
+
+	// ErrOrderStatusConflict 订单状态已被并发修改，条件更新未命中
+	ErrOrderStatusConflict = errors.New("order status changed concurrently")
 )
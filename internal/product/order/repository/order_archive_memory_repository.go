@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"server/pkg/memstore"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryOrderArchiveRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryOrderArchiveRepository 创建一个基于进程内存储的订单归档仓储实例，用于memory存储模式
+func NewMemoryOrderArchiveRepository(store *MemoryStore) OrderArchiveRepository {
+	return &memoryOrderArchiveRepository{store: store}
+}
+
+// ArchiveOrders 将状态在statuses中且最后更新时间早于before的订单（包括已软删除的）按ID顺序迁移到归档记录
+// 返回本批归档的订单数量，最多limit个
+func (aRepo *memoryOrderArchiveRepository) ArchiveOrders(statuses []model.OrderStatus, before time.Time, limit int) (int, error) {
+	aRepo.store.mu.Lock()
+	defer aRepo.store.mu.Unlock()
+	matched := make(map[model.OrderStatus]bool, len(statuses))
+	for _, status := range statuses {
+		matched[status] = true
+	}
+	ids := make([]int, 0)
+	for id, order := range aRepo.store.orders {
+		if matched[order.Status] && order.UpdatedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	ids = memstore.Paginate(ids, 0, limit)
+
+	now := time.Now()
+	for _, id := range ids {
+		aRepo.store.archived[id] = *model.NewArchivedOrder(aRepo.store.orders[id], now)
+		delete(aRepo.store.orders, id)
+	}
+	return len(ids), nil
+}
+
+// FindArchivedOrderByOrderNo 根据订单号查找归档订单，不存在时返回gorm.ErrRecordNotFound
+func (aRepo *memoryOrderArchiveRepository) FindArchivedOrderByOrderNo(orderNo string) (*model.ArchivedOrder, error) {
+	aRepo.store.mu.RLock()
+	defer aRepo.store.mu.RUnlock()
+	for _, archived := range aRepo.store.archived {
+		if archived.OrderNo == orderNo {
+			return &archived, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindArchivedOrders 根据查询条件分页查找归档订单，按原订单ID倒序
+// 返回当前页的归档订单以及满足条件的总数
+func (aRepo *memoryOrderArchiveRepository) FindArchivedOrders(query ArchivedOrderQuery) ([]*model.ArchivedOrder, int64, error) {
+	aRepo.store.mu.RLock()
+	defer aRepo.store.mu.RUnlock()
+	archived := make([]*model.ArchivedOrder, 0)
+	for _, order := range aRepo.store.archived {
+		if (query.UserId != 0 && order.UserId != query.UserId) || (query.Status != "" && order.Status != query.Status) {
+			continue
+		}
+		order := order
+		archived = append(archived, &order)
+	}
+	sort.Slice(archived, func(i, j int) bool { return archived[i].Id > archived[j].Id })
+	return memstore.Paginate(archived, query.Offset, query.Limit), int64(len(archived)), nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ArchivedOrderQuery 归档订单列表的查询条件
+type ArchivedOrderQuery struct {
+	UserId int               // 用户ID，0表示不过滤
+	Status model.OrderStatus // 订单状态，为空时不过滤
+	Offset int               // 分页偏移量
+	Limit  int               // 每页条数
+}
+
+// OrderArchiveRepository 订单归档的数据访问接口
+type OrderArchiveRepository interface {
+	ArchiveOrders(statuses []model.OrderStatus, before time.Time, limit int) (int, error)
+	FindArchivedOrderByOrderNo(orderNo string) (*model.ArchivedOrder, error)
+	FindArchivedOrders(query ArchivedOrderQuery) ([]*model.ArchivedOrder, int64, error)
+}
+
+type gormOrderArchiveRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewOrderArchiveRepository 创建一个新的订单归档仓储实例
+func NewOrderArchiveRepository(gDB *gorm.DB) OrderArchiveRepository {
+	return &gormOrderArchiveRepository{gormDB: gDB}
+}
+
+// ArchiveOrders 将状态在statuses中且最后更新时间早于before的订单（包括已软删除的）迁移到归档表
+// 在同一事务中：锁定并读取一批订单 → 写入归档表 → 物理删除订单行和订单头
+// 返回本批归档的订单数量，最多limit个
+func (aRepo *gormOrderArchiveRepository) ArchiveOrders(statuses []model.OrderStatus, before time.Time, limit int) (int, error) {
+	archivedCount := 0
+	err := aRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		var orders []*model.Order
+		err := tx.Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Items").
+			Where("status IN ? AND updated_at < ?", statuses, before).
+			Order("id ASC").
+			Limit(limit).
+			Find(&orders).Error
+		if err != nil || len(orders) == 0 {
+			return err
+		}
+
+		now := time.Now()
+		archived := make([]*model.ArchivedOrder, 0, len(orders))
+		ids := make([]int, 0, len(orders))
+		for _, order := range orders {
+			archived = append(archived, model.NewArchivedOrder(order, now))
+			ids = append(ids, order.Id)
+		}
+		if err = tx.Create(&archived).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("order_id IN ?", ids).Delete(&model.OrderItem{}).Error; err != nil {
+			return err
+		}
+		if err = tx.Unscoped().Where("id IN ?", ids).Delete(&model.Order{}).Error; err != nil {
+			return err
+		}
+		archivedCount = len(orders)
+		return nil
+	})
+	return archivedCount, err
+}
+
+// FindArchivedOrderByOrderNo 根据订单号查找归档订单
+func (aRepo *gormOrderArchiveRepository) FindArchivedOrderByOrderNo(orderNo string) (*model.ArchivedOrder, error) {
+	var archived model.ArchivedOrder
+	err := aRepo.gormDB.Where("order_no = ?", orderNo).First(&archived).Error
+	if err != nil {
+		return nil, err
+	}
+	return &archived, nil
+}
+
+// FindArchivedOrders 根据查询条件分页查找归档订单，按原订单ID倒序
+// 返回当前页的归档订单以及满足条件的总数
+func (aRepo *gormOrderArchiveRepository) FindArchivedOrders(query ArchivedOrderQuery) ([]*model.ArchivedOrder, int64, error) {
+	db := aRepo.gormDB.Model(&model.ArchivedOrder{})
+	if query.UserId != 0 {
+		db = db.Where("user_id = ?", query.UserId)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var archived []*model.ArchivedOrder
+	err := db.Order("id DESC").Offset(query.Offset).Limit(query.Limit).Find(&archived).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return archived, total, nil
+}
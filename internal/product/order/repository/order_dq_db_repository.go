@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	"server/internal/product/order/model"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type dbOrderDQRepository struct {
+	gormDB            *gorm.DB
+	processingTimeout time.Duration
+}
+
+// NewDBOrderDQRepository 创建一个基于数据库的订单延迟队列仓储实例，用于database库存存储，不依赖Redis
+// 任务保存在order_delay_tasks中，与Redis实现一样分为ready和processing两种状态，处理超时的任务由RecoveryScheduler恢复
+// 多个实例通过SELECT ... FOR UPDATE SKIP LOCKED获取到期任务，同一任务不会被重复取出
+func NewDBOrderDQRepository(gDB *gorm.DB, cfg *config.Config) OrderDQRepository {
+	return &dbOrderDQRepository{
+		gormDB:            gDB,
+		processingTimeout: time.Duration(cfg.DelayQueue.ProcessingTimeoutSeconds) * time.Second,
+	}
+}
+
+// EnqueueDelayTask 将延迟任务写入ready状态，execTime后到期；任务已存在时覆盖
+func (dRepo *dbOrderDQRepository) EnqueueDelayTask(ctx context.Context, id, payload string, execTime time.Duration) error {
+	now := time.Now()
+	task := &model.OrderDelayTask{Id: id, Payload: payload, ExecuteAt: now.Add(execTime), CreatedAt: now}
+	return dRepo.gormDB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"payload", "execute_at", "processing_until"}),
+	}).Create(task).Error
+}
+
+// GetReadyTasks 在一个事务中按执行时间锁定最多count个到期任务并标记为处理中，没有到期任务时返回ErrNoTasksInQueue
+// 被其他实例锁定的任务跳过（SKIP LOCKED），处理超时时间由配置delayQueue.processingTimeoutSeconds指定
+func (dRepo *dbOrderDQRepository) GetReadyTasks(ctx context.Context, count int64) ([]string, error) {
+	now := time.Now()
+	var ids []string
+	err := dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.OrderDelayTask{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processing_until IS NULL AND execute_at <= ?", now).
+			Order("execute_at").Limit(int(count)).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Model(&model.OrderDelayTask{}).Where("id IN ?", ids).Update("processing_until", now.Add(dRepo.processingTimeout)).Error
+	})
+	if err != nil {
+		log.Warnf("Failed to get and move tasks: %v", err)
+		return nil, ErrQueueOperationFailed
+	}
+	if len(ids) == 0 {
+		log.Debug("No ready tasks found")
+		return nil, ErrNoTasksInQueue
+	}
+	return ids, nil
+}
+
+// RemoveTask 确认任务完成，删除任务
+func (dRepo *dbOrderDQRepository) RemoveTask(ctx context.Context, id string) error {
+	return dRepo.gormDB.WithContext(ctx).Delete(&model.OrderDelayTask{}, "id = ?", id).Error
+}
+
+// CancelTask 撤销延迟任务，无论任务处于ready还是processing状态都会被删除
+func (dRepo *dbOrderDQRepository) CancelTask(ctx context.Context, id string) error {
+	return dRepo.gormDB.WithContext(ctx).Delete(&model.OrderDelayTask{}, "id = ?", id).Error
+}
+
+// GetTaskTime 读取ready状态任务的执行时间，任务不在ready状态时返回ErrTaskNotFound
+func (dRepo *dbOrderDQRepository) GetTaskTime(ctx context.Context, id string) (time.Time, error) {
+	var task model.OrderDelayTask
+	err := dRepo.gormDB.WithContext(ctx).Where("id = ? AND processing_until IS NULL", id).First(&task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, ErrTaskNotFound
+		}
+		return time.Time{}, err
+	}
+	return task.ExecuteAt.Truncate(time.Second), nil
+}
+
+// GetTaskPayload 读取任务的payload，任务已完成或已撤销时返回ErrTaskNotFound
+func (dRepo *dbOrderDQRepository) GetTaskPayload(ctx context.Context, id string) (string, error) {
+	var task model.OrderDelayTask
+	err := dRepo.gormDB.WithContext(ctx).Select("payload").Where("id = ?", id).First(&task).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrTaskNotFound
+		}
+		return "", err
+	}
+	return task.Payload, nil
+}
+
+// RecoverTimedOutTasks 将处理超时的任务移回ready状态，延迟retryDelay后重试，返回恢复的任务数量
+// 同时清理已过期的库存归还标记
+func (dRepo *dbOrderDQRepository) RecoverTimedOutTasks(ctx context.Context, retryDelay time.Duration) (int, error) {
+	now := time.Now()
+	db := dRepo.gormDB.WithContext(ctx)
+	result := db.Model(&model.OrderDelayTask{}).
+		Where("processing_until < ?", now).
+		Updates(map[string]interface{}{"processing_until": nil, "execute_at": now.Add(retryDelay)})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := db.Where("expire_at <= ?", now).Delete(&model.OrderStockRestoreMark{}).Error; err != nil {
+		log.Warnf("Failed to clean up expired stock restore marks: %v", err)
+	}
+	if result.RowsAffected > 0 {
+		log.Warnf("Recovered %d timed out tasks from processing to ready", result.RowsAffected)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// MarkStockRestored 标记订单库存已归还，标记已存在且未过期时返回false
+// 先删除已过期的标记，再以主键冲突时不插入的方式写入，并发标记时只有一个成功
+func (dRepo *dbOrderDQRepository) MarkStockRestored(ctx context.Context, orderNo string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	marked := false
+	err := dRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_no = ? AND expire_at <= ?", orderNo, now).Delete(&model.OrderStockRestoreMark{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.OrderStockRestoreMark{OrderNo: orderNo, ExpireAt: now.Add(ttl)})
+		if result.Error != nil {
+			return result.Error
+		}
+		marked = result.RowsAffected == 1
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return marked, nil
+}
+
+// UnmarkStockRestored 删除订单库存已归还的标记
+func (dRepo *dbOrderDQRepository) UnmarkStockRestored(ctx context.Context, orderNo string) error {
+	return dRepo.gormDB.WithContext(ctx).Delete(&model.OrderStockRestoreMark{}, "order_no = ?", orderNo).Error
+}
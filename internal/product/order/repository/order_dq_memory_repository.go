@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"server/config"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type memoryOrderDQRepository struct {
+	mu                sync.Mutex
+	ready             map[string]time.Time // 待处理任务 -> 执行时间
+	processing        map[string]time.Time // 处理中任务 -> 处理超时时间
+	payloads          map[string]string
+	restored          map[string]time.Time // 库存已归还的订单号 -> 标记过期时间
+	processingTimeout time.Duration
+}
+
+// NewMemoryOrderDQRepository 创建一个基于进程内存储的订单延迟队列仓储实例，用于memory存储模式
+// 与Redis实现一样使用ready、processing两个队列，处理超时的任务由RecoveryScheduler恢复
+func NewMemoryOrderDQRepository(cfg *config.Config) OrderDQRepository {
+	return &memoryOrderDQRepository{
+		ready:             make(map[string]time.Time),
+		processing:        make(map[string]time.Time),
+		payloads:          make(map[string]string),
+		restored:          make(map[string]time.Time),
+		processingTimeout: time.Duration(cfg.DelayQueue.ProcessingTimeoutSeconds) * time.Second,
+	}
+}
+
+// EnqueueDelayTask 将延迟任务加入ready队列，execTime后到期
+func (mRepo *memoryOrderDQRepository) EnqueueDelayTask(ctx context.Context, id, payload string, execTime time.Duration) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	mRepo.ready[id] = time.Now().Add(execTime)
+	mRepo.payloads[id] = payload
+	return nil
+}
+
+// GetReadyTasks 按执行时间获取最多count个到期任务并移动到processing队列，没有到期任务时返回ErrNoTasksInQueue
+func (mRepo *memoryOrderDQRepository) GetReadyTasks(ctx context.Context, count int64) ([]string, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	now := time.Now()
+	ids := make([]string, 0)
+	for id, execAt := range mRepo.ready {
+		if !execAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		log.Debug("No ready tasks found")
+		return nil, ErrNoTasksInQueue
+	}
+	sort.Slice(ids, func(i, j int) bool { return mRepo.ready[ids[i]].Before(mRepo.ready[ids[j]]) })
+	if int64(len(ids)) > count {
+		ids = ids[:count]
+	}
+	for _, id := range ids {
+		delete(mRepo.ready, id)
+		mRepo.processing[id] = now.Add(mRepo.processingTimeout)
+	}
+	return ids, nil
+}
+
+// RemoveTask 确认任务完成，从processing队列删除并清理payload
+func (mRepo *memoryOrderDQRepository) RemoveTask(ctx context.Context, id string) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	delete(mRepo.processing, id)
+	delete(mRepo.payloads, id)
+	return nil
+}
+
+// CancelTask 撤销延迟任务，无论任务处于ready还是processing队列都会被移除
+func (mRepo *memoryOrderDQRepository) CancelTask(ctx context.Context, id string) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	delete(mRepo.ready, id)
+	delete(mRepo.processing, id)
+	delete(mRepo.payloads, id)
+	return nil
+}
+
+// GetTaskTime 获取ready队列中任务的执行时间，任务不在ready队列中时返回ErrTaskNotFound
+func (mRepo *memoryOrderDQRepository) GetTaskTime(ctx context.Context, id string) (time.Time, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	execAt, ok := mRepo.ready[id]
+	if !ok {
+		return time.Time{}, ErrTaskNotFound
+	}
+	return execAt.Truncate(time.Second), nil
+}
+
+// GetTaskPayload 读取任务的payload，任务已完成或已撤销时返回ErrTaskNotFound
+func (mRepo *memoryOrderDQRepository) GetTaskPayload(ctx context.Context, id string) (string, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	payload, ok := mRepo.payloads[id]
+	if !ok {
+		return "", ErrTaskNotFound
+	}
+	return payload, nil
+}
+
+// RecoverTimedOutTasks 将处理超时的任务移回ready队列，延迟retryDelay后重试，返回恢复的任务数量
+// 同时清理已过期的库存归还标记
+func (mRepo *memoryOrderDQRepository) RecoverTimedOutTasks(ctx context.Context, retryDelay time.Duration) (int, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	now := time.Now()
+	count := 0
+	for id, timeoutAt := range mRepo.processing {
+		if timeoutAt.Before(now) {
+			delete(mRepo.processing, id)
+			mRepo.ready[id] = now.Add(retryDelay)
+			count++
+		}
+	}
+	for orderNo, expireAt := range mRepo.restored {
+		if !expireAt.After(now) {
+			delete(mRepo.restored, orderNo)
+		}
+	}
+	if count > 0 {
+		log.Warnf("Recovered %d timed out tasks from processing to ready", count)
+	}
+	return count, nil
+}
+
+// MarkStockRestored 标记订单库存已归还，标记已存在且未过期时返回false
+func (mRepo *memoryOrderDQRepository) MarkStockRestored(ctx context.Context, orderNo string, ttl time.Duration) (bool, error) {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	now := time.Now()
+	if expireAt, ok := mRepo.restored[orderNo]; ok && expireAt.After(now) {
+		return false, nil
+	}
+	mRepo.restored[orderNo] = now.Add(ttl)
+	return true, nil
+}
+
+// UnmarkStockRestored 清除订单库存已归还的标记
+func (mRepo *memoryOrderDQRepository) UnmarkStockRestored(ctx context.Context, orderNo string) error {
+	mRepo.mu.Lock()
+	defer mRepo.mu.Unlock()
+	delete(mRepo.restored, orderNo)
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"server/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryDQ(processingTimeout int) OrderDQRepository {
+	cfg := &config.Config{}
+	cfg.DelayQueue.ProcessingTimeoutSeconds = processingTimeout
+	return NewMemoryOrderDQRepository(cfg)
+}
+
+func TestMemoryOrderDQRepositoryGetReadyTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryDQ(60)
+	require.NoError(t, repo.EnqueueDelayTask(ctx, "late", "p1", -time.Second))
+	require.NoError(t, repo.EnqueueDelayTask(ctx, "early", "p2", -time.Minute))
+	require.NoError(t, repo.EnqueueDelayTask(ctx, "future", "p3", time.Hour))
+
+	ids, err := repo.GetReadyTasks(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"early", "late"}, ids, "due tasks are returned in execution order")
+
+	_, err = repo.GetReadyTasks(ctx, 10)
+	assert.ErrorIs(t, err, ErrNoTasksInQueue, "tasks in processing are not handed out again")
+
+	_, err = repo.GetTaskTime(ctx, "early")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+	payload, err := repo.GetTaskPayload(ctx, "early")
+	require.NoError(t, err)
+	assert.Equal(t, "p2", payload)
+
+	require.NoError(t, repo.RemoveTask(ctx, "early"))
+	_, err = repo.GetTaskPayload(ctx, "early")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestMemoryOrderDQRepositoryRecoverTimedOutTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryDQ(-1) // 取出后立即超时
+	require.NoError(t, repo.EnqueueDelayTask(ctx, "task", "payload", 0))
+	_, err := repo.GetReadyTasks(ctx, 1)
+	require.NoError(t, err)
+
+	count, err := repo.RecoverTimedOutTasks(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	ids, err := repo.GetReadyTasks(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"task"}, ids)
+
+	require.NoError(t, repo.CancelTask(ctx, "task"))
+	count, err = repo.RecoverTimedOutTasks(ctx, 0)
+	require.NoError(t, err)
+	assert.Zero(t, count, "cancelled tasks are not recovered")
+}
+
+func TestMemoryOrderDQRepositoryMarkStockRestored(t *testing.T) {
+	ctx := context.Background()
+	repo := newMemoryDQ(60)
+
+	marked, err := repo.MarkStockRestored(ctx, "O1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = repo.MarkStockRestored(ctx, "O1", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, marked, "stock must be restored only once")
+
+	require.NoError(t, repo.UnmarkStockRestored(ctx, "O1"))
+	marked, err = repo.MarkStockRestored(ctx, "O1", -time.Second)
+	require.NoError(t, err)
+	assert.True(t, marked)
+	marked, err = repo.MarkStockRestored(ctx, "O1", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, marked, "an expired mark no longer blocks")
+}
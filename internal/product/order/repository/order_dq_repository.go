@@ -2,12 +2,13 @@ package repository
 
 import (
 	"context"
-
+	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 
+	"server/config"
 	myRedis "server/pkg/redis"
 )
 
@@ -16,15 +17,29 @@ type OrderDQRepository interface {
 	EnqueueDelayTask(ctx context.Context, id, payload string, execTime time.Duration) error // 将延迟任务加入队列
 	GetReadyTasks(ctx context.Context, count int64) ([]string, error)                       // 获取到期的任务
 	RemoveTask(ctx context.Context, id string) error                                        // 从队列中移除任务
+	CancelTask(ctx context.Context, id string) error                                        // 撤销尚未到期的任务
+	GetTaskTime(ctx context.Context, id string) (time.Time, error)                          // 获取尚未到期任务的执行时间
+	GetTaskPayload(ctx context.Context, id string) (string, error)                          // 获取任务的payload
+	RecoverTimedOutTasks(ctx context.Context, retryDelay time.Duration) (int, error)        // 将处理超时的任务移回ready队列
+	MarkStockRestored(ctx context.Context, orderNo string, ttl time.Duration) (bool, error) // 标记订单库存已归还，已标记过时返回false
+	UnmarkStockRestored(ctx context.Context, orderNo string) error                          // 清除订单库存已归还的标记，允许之后重试归还
+}
+
+// getStockRestoredKey 生成订单库存已归还标记的key
+// 格式：order_cancel_idempotent:{orderNo}
+// 超时取消和主动取消共用同一个标记，保证同一订单的库存只会被归还一次
+func getStockRestoredKey(orderNo string) string {
+	return "order_cancel_idempotent:" + orderNo
 }
 
 type redisOrderDQRepository struct {
-	redisDB *redis.Client
+	redisDB           *redis.Client
+	processingTimeout int64 // 任务处理超时时间（秒）
 }
 
 // NewOrderDQRepository 创建一个新的订单延迟队列仓储实例
-func NewOrderDQRepository(rDB *redis.Client) OrderDQRepository {
-	return &redisOrderDQRepository{redisDB: rDB}
+func NewOrderDQRepository(rDB *redis.Client, cfg *config.Config) OrderDQRepository {
+	return &redisOrderDQRepository{redisDB: rDB, processingTimeout: int64(cfg.DelayQueue.ProcessingTimeoutSeconds)}
 }
 
 // EnqueueDelayTask 将订单延迟任务加入Redis队列
@@ -37,13 +52,13 @@ func (oRedisRepo *redisOrderDQRepository) EnqueueDelayTask(ctx context.Context,
 // 使用三队列模型防止任务重复处理：
 // 1. 原子性从ready队列获取到期任务
 // 2. 立即移动到processing队列（防止其他调度器重复获取）
-// 3. 处理超时设置为300秒，超时后任务会被RecoveryScheduler恢复
+// 3. 处理超时由配置delayQueue.processingTimeoutSeconds指定（默认300秒），超时后任务会被RecoveryScheduler恢复
 func (oRedisRepo *redisOrderDQRepository) GetReadyTasks(ctx context.Context, count int64) ([]string, error) {
 	// 使用GetAndMoveToProcessing原子性获取任务并移动到processing队列
-	// 处理超时设置为300秒（5分钟），超时后任务会被RecoveryScheduler恢复到ready队列
-	ids, err := myRedis.GetAndMoveToProcessing(ctx, oRedisRepo.redisDB, count, 300)
+	// 超过处理超时时间仍未确认的任务会被RecoveryScheduler恢复到ready队列
+	ids, err := myRedis.GetAndMoveToProcessing(ctx, oRedisRepo.redisDB, count, oRedisRepo.processingTimeout)
 	if err != nil {
-		log.Warn("Failed to get and move tasks: %v", err)
+		log.Warnf("Failed to get and move tasks: %v", err)
 		return nil, ErrQueueOperationFailed
 	}
 
@@ -60,3 +75,47 @@ func (oRedisRepo *redisOrderDQRepository) GetReadyTasks(ctx context.Context, cou
 func (oRedisRepo *redisOrderDQRepository) RemoveTask(ctx context.Context, id string) error {
 	return myRedis.Ack(ctx, oRedisRepo.redisDB, id)
 }
+
+// CancelTask 撤销延迟任务，无论任务处于ready还是processing队列都会被移除
+func (oRedisRepo *redisOrderDQRepository) CancelTask(ctx context.Context, id string) error {
+	return myRedis.RemoveDelayTask(ctx, oRedisRepo.redisDB, id)
+}
+
+// GetTaskTime 从ready队列的score读取任务的执行时间，任务不在ready队列中时返回ErrTaskNotFound
+func (oRedisRepo *redisOrderDQRepository) GetTaskTime(ctx context.Context, id string) (time.Time, error) {
+	unixTime, err := myRedis.GetDelayTaskTime(ctx, oRedisRepo.redisDB, id)
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return time.Time{}, ErrTaskNotFound
+		}
+		return time.Time{}, err
+	}
+	return time.Unix(unixTime, 0), nil
+}
+
+// GetTaskPayload 读取任务的payload，任务已完成或已撤销时返回ErrTaskNotFound
+func (oRedisRepo *redisOrderDQRepository) GetTaskPayload(ctx context.Context, id string) (string, error) {
+	payload, err := oRedisRepo.redisDB.Get(ctx, "dq:payload:"+id).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", ErrTaskNotFound
+		}
+		return "", err
+	}
+	return payload, nil
+}
+
+// RecoverTimedOutTasks 将processing队列中处理超时的任务移回ready队列，延迟retryDelay后重试，返回恢复的任务数量
+func (oRedisRepo *redisOrderDQRepository) RecoverTimedOutTasks(ctx context.Context, retryDelay time.Duration) (int, error) {
+	return myRedis.RecoverTimedOutTasks(ctx, oRedisRepo.redisDB, int64(retryDelay.Seconds()))
+}
+
+// MarkStockRestored 使用SET NX标记订单库存已归还，标记已存在时返回false
+func (oRedisRepo *redisOrderDQRepository) MarkStockRestored(ctx context.Context, orderNo string, ttl time.Duration) (bool, error) {
+	return oRedisRepo.redisDB.SetNX(ctx, getStockRestoredKey(orderNo), "1", ttl).Result()
+}
+
+// UnmarkStockRestored 删除订单库存已归还的标记
+func (oRedisRepo *redisOrderDQRepository) UnmarkStockRestored(ctx context.Context, orderNo string) error {
+	return oRedisRepo.redisDB.Del(ctx, getStockRestoredKey(orderNo)).Err()
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"time"
+)
+
+type memoryOrderEventRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryOrderEventRepository 创建一个基于进程内存储的订单事件仓储实例，用于memory存储模式
+func NewMemoryOrderEventRepository(store *MemoryStore) OrderEventRepository {
+	return &memoryOrderEventRepository{store: store}
+}
+
+// CreateEvent 追加一条订单事件，并回写分配的事件ID
+func (eRepo *memoryOrderEventRepository) CreateEvent(event *model.OrderEvent) error {
+	eRepo.store.mu.Lock()
+	defer eRepo.store.mu.Unlock()
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	eRepo.store.lastEventId++
+	event.Id = eRepo.store.lastEventId
+	eRepo.store.events = append(eRepo.store.events, *event)
+	return nil
+}
+
+// FindEventsByOrderId 按发生顺序查找订单的所有事件
+func (eRepo *memoryOrderEventRepository) FindEventsByOrderId(orderId int) ([]*model.OrderEvent, error) {
+	eRepo.store.mu.RLock()
+	defer eRepo.store.mu.RUnlock()
+	events := make([]*model.OrderEvent, 0)
+	for _, event := range eRepo.store.events {
+		if event.OrderId == orderId {
+			event := event
+			events = append(events, &event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+
+	"gorm.io/gorm"
+)
+
+// OrderEventRepository 订单事件的数据访问接口，事件只允许追加，不允许修改和删除
+type OrderEventRepository interface {
+	CreateEvent(event *model.OrderEvent) error
+	FindEventsByOrderId(orderId int) ([]*model.OrderEvent, error)
+}
+
+type gormOrderEventRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewOrderEventRepository 创建一个新的订单事件仓储实例
+func NewOrderEventRepository(gDB *gorm.DB) OrderEventRepository {
+	return &gormOrderEventRepository{gormDB: gDB}
+}
+
+// CreateEvent 追加一条订单事件
+func (eRepo *gormOrderEventRepository) CreateEvent(event *model.OrderEvent) error {
+	return eRepo.gormDB.Create(event).Error
+}
+
+// FindEventsByOrderId 按发生顺序查找订单的所有事件
+func (eRepo *gormOrderEventRepository) FindEventsByOrderId(orderId int) ([]*model.OrderEvent, error) {
+	var events []*model.OrderEvent
+	err := eRepo.gormDB.Where("order_id = ?", orderId).Order("created_at ASC, id ASC").Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"server/pkg/memstore"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryOrderRepository struct {
+	store *MemoryStore
+}
+
+// NewMemoryOrderRepository 创建一个基于进程内存储的订单仓储实例，用于memory存储模式
+func NewMemoryOrderRepository(store *MemoryStore) OrderRepository {
+	return &memoryOrderRepository{store: store}
+}
+
+// CreateOrder 创建新订单记录（包含订单行），并回写分配的订单ID和订单行ID
+func (oRepo *memoryOrderRepository) CreateOrder(order *model.Order) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	now := time.Now()
+	if order.CreatedAt.IsZero() {
+		order.CreatedAt = now
+	}
+	if order.UpdatedAt.IsZero() {
+		order.UpdatedAt = now
+	}
+	oRepo.store.lastOrderId++
+	order.Id = oRepo.store.lastOrderId
+	for i := range order.Items {
+		item := &order.Items[i]
+		oRepo.store.lastOrderItemId++
+		item.Id = oRepo.store.lastOrderItemId
+		item.OrderId = order.Id
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = now
+		}
+		if item.UpdatedAt.IsZero() {
+			item.UpdatedAt = now
+		}
+	}
+	oRepo.store.orders[order.Id] = copyOrder(order)
+	return nil
+}
+
+// UpdateOrder 更新订单信息，与GORM的Updates一致只更新订单头的非零值字段
+func (oRepo *memoryOrderRepository) UpdateOrder(order *model.Order) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	current := oRepo.findOrder(order.Id)
+	if current == nil {
+		return nil
+	}
+	if order.OrderNo != "" {
+		current.OrderNo = order.OrderNo
+	}
+	if order.UserId != 0 {
+		current.UserId = order.UserId
+	}
+	if order.TotalAmount != 0 {
+		current.TotalAmount = order.TotalAmount
+	}
+	if order.DiscountAmount != 0 {
+		current.DiscountAmount = order.DiscountAmount
+	}
+	if order.PayAmount != 0 {
+		current.PayAmount = order.PayAmount
+	}
+	if order.CouponId != 0 {
+		current.CouponId = order.CouponId
+	}
+	if order.Currency != "" {
+		current.Currency = order.Currency
+	}
+	if order.Address != "" {
+		current.Address = order.Address
+	}
+	if order.WarehouseId != 0 {
+		current.WarehouseId = order.WarehouseId
+	}
+	if order.Status != "" {
+		current.Status = order.Status
+	}
+	if order.CancelReason != "" {
+		current.CancelReason = order.CancelReason
+	}
+	if order.CancelledAt != nil {
+		current.CancelledAt = order.CancelledAt
+	}
+	if order.PayDeadline != nil {
+		current.PayDeadline = order.PayDeadline
+	}
+	current.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateOrderStatus 仅当订单当前状态为from时才更新为to，状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *memoryOrderRepository) UpdateOrderStatus(orderId int, from, to model.OrderStatus) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil || order.Status != from {
+		return ErrOrderStatusConflict
+	}
+	order.Status = to
+	order.UpdatedAt = time.Now()
+	return nil
+}
+
+// UpdateOrderAddress 仅当订单当前状态为from时才修改收货地址，状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *memoryOrderRepository) UpdateOrderAddress(orderId int, from model.OrderStatus, address string) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil || order.Status != from {
+		return ErrOrderStatusConflict
+	}
+	order.Address = address
+	order.UpdatedAt = time.Now()
+	return nil
+}
+
+// CancelOrder 仅当订单当前状态为from时才置为已取消，并记录取消原因和取消时间
+// 状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *memoryOrderRepository) CancelOrder(orderId int, from model.OrderStatus, reason string) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil || order.Status != from {
+		return ErrOrderStatusConflict
+	}
+	now := time.Now()
+	order.Status = model.StatusCancelled
+	order.CancelReason = reason
+	order.CancelledAt = &now
+	order.UpdatedAt = now
+	return nil
+}
+
+// ApplyRefund 累加订单行的已退款数量，并以条件更新的方式修改订单状态
+// 订单状态已被修改或某个订单行的退款数量超过购买数量时返回ErrOrderStatusConflict，不做任何修改
+func (oRepo *memoryOrderRepository) ApplyRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil || order.Status != from {
+		return ErrOrderStatusConflict
+	}
+	indexes := make(map[int]int, len(order.Items))
+	for i, item := range order.Items {
+		indexes[item.Id] = i
+	}
+	for itemId, quantity := range quantities {
+		i, ok := indexes[itemId]
+		if !ok || order.Items[i].RefundedQuantity+quantity > order.Items[i].Quantity {
+			return ErrOrderStatusConflict
+		}
+	}
+
+	now := time.Now()
+	for itemId, quantity := range quantities {
+		item := &order.Items[indexes[itemId]]
+		item.RefundedQuantity += quantity
+		item.UpdatedAt = now
+	}
+	order.Status = to
+	order.UpdatedAt = now
+	return nil
+}
+
+// RevertRefund 撤销ApplyRefund写入的退款：扣减订单行的已退款数量，并以条件更新将订单状态从from恢复为to
+// 订单状态已被修改或某个订单行的已退款数量不足时返回ErrOrderStatusConflict，不做任何修改
+func (oRepo *memoryOrderRepository) RevertRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil || order.Status != from {
+		return ErrOrderStatusConflict
+	}
+	indexes := make(map[int]int, len(order.Items))
+	for i, item := range order.Items {
+		indexes[item.Id] = i
+	}
+	for itemId, quantity := range quantities {
+		i, ok := indexes[itemId]
+		if !ok || order.Items[i].RefundedQuantity < quantity {
+			return ErrOrderStatusConflict
+		}
+	}
+
+	now := time.Now()
+	for itemId, quantity := range quantities {
+		item := &order.Items[indexes[itemId]]
+		item.RefundedQuantity -= quantity
+		item.UpdatedAt = now
+	}
+	order.Status = to
+	order.UpdatedAt = now
+	return nil
+}
+
+// DeleteOrder 根据ID软删除订单（写入DeletedAt），订单不存在或已删除时返回gorm.ErrRecordNotFound
+func (oRepo *memoryOrderRepository) DeleteOrder(orderId int) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil {
+		return gorm.ErrRecordNotFound
+	}
+	order.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return nil
+}
+
+// PurgeOrder 根据ID物理删除订单记录及其订单行，订单不存在时返回gorm.ErrRecordNotFound
+func (oRepo *memoryOrderRepository) PurgeOrder(orderId int) error {
+	oRepo.store.mu.Lock()
+	defer oRepo.store.mu.Unlock()
+	if _, ok := oRepo.store.orders[orderId]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(oRepo.store.orders, orderId)
+	return nil
+}
+
+// FindOrderById 根据ID查找订单（不包括已软删除的），订单不存在时返回gorm.ErrRecordNotFound
+func (oRepo *memoryOrderRepository) FindOrderById(orderId int) (*model.Order, error) {
+	oRepo.store.mu.RLock()
+	defer oRepo.store.mu.RUnlock()
+	order := oRepo.findOrder(orderId)
+	if order == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyOrder(order), nil
+}
+
+// FindOrderByOrderNo 根据订单号查找订单，包括买家已软删除的订单，供系统任务和管理员使用
+func (oRepo *memoryOrderRepository) FindOrderByOrderNo(orderNo string) (*model.Order, error) {
+	oRepo.store.mu.RLock()
+	defer oRepo.store.mu.RUnlock()
+	for _, order := range oRepo.store.orders {
+		if order.OrderNo == orderNo {
+			return copyOrder(order), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindOrderByOrderNoAndUserId 根据订单号查找属于指定用户的订单（不包括已软删除的）
+// 订单不存在或不属于该用户时都返回gorm.ErrRecordNotFound
+func (oRepo *memoryOrderRepository) FindOrderByOrderNoAndUserId(orderNo string, userId int) (*model.Order, error) {
+	oRepo.store.mu.RLock()
+	defer oRepo.store.mu.RUnlock()
+	for _, order := range oRepo.store.orders {
+		if order.OrderNo == orderNo && order.UserId == userId && !order.DeletedAt.Valid {
+			return copyOrder(order), nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindOrdersByUserId 根据用户ID和查询条件分页查找该用户的订单（不包括已软删除的）
+// 返回当前页的订单（包含订单行）以及满足条件的订单总数
+func (oRepo *memoryOrderRepository) FindOrdersByUserId(userId int, query OrderQuery) ([]*model.Order, int64, error) {
+	oRepo.store.mu.RLock()
+	defer oRepo.store.mu.RUnlock()
+	orders := make([]*model.Order, 0)
+	for _, order := range oRepo.store.orders {
+		if order.UserId != userId || order.DeletedAt.Valid ||
+			(query.Status != "" && order.Status != query.Status) ||
+			(!query.StartTime.IsZero() && order.CreatedAt.Before(query.StartTime)) ||
+			(!query.EndTime.IsZero() && !order.CreatedAt.Before(query.EndTime)) {
+			continue
+		}
+		orders = append(orders, copyOrder(order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt) == query.Ascending
+		}
+		return (orders[i].Id < orders[j].Id) == query.Ascending
+	})
+	return memstore.Paginate(orders, query.Offset, query.Limit), int64(len(orders)), nil
+}
+
+// findOrder 查找未软删除的订单，调用方需持有锁
+func (oRepo *memoryOrderRepository) findOrder(orderId int) *model.Order {
+	order, ok := oRepo.store.orders[orderId]
+	if !ok || order.DeletedAt.Valid {
+		return nil
+	}
+	return order
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"server/internal/product/order/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newOrderFixture 创建内存订单仓储和一个指定状态的订单，订单行1购买2件，订单行2购买1件
+func newOrderFixture(t *testing.T, status model.OrderStatus) (OrderRepository, *model.Order) {
+	t.Helper()
+	repo := NewMemoryOrderRepository(NewMemoryStore())
+	order := &model.Order{
+		OrderNo: "O1",
+		UserId:  1,
+		Status:  status,
+		Items: []model.OrderItem{
+			{CommodityId: 10, Quantity: 2},
+			{CommodityId: 11, Quantity: 1},
+		},
+	}
+	require.NoError(t, repo.CreateOrder(order))
+	return repo, order
+}
+
+func TestMemoryOrderRepositoryConditionalUpdates(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  model.OrderStatus
+		update  func(repo OrderRepository, orderId int) error
+		wantErr error
+		want    model.OrderStatus
+	}{
+		{
+			name:   "update status",
+			status: model.StatusPending,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.UpdateOrderStatus(orderId, model.StatusPending, model.StatusPaid)
+			},
+			want: model.StatusPaid,
+		},
+		{
+			name:   "update status conflict",
+			status: model.StatusCancelled,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.UpdateOrderStatus(orderId, model.StatusPending, model.StatusPaid)
+			},
+			wantErr: ErrOrderStatusConflict,
+			want:    model.StatusCancelled,
+		},
+		{
+			name:   "update status of missing order",
+			status: model.StatusPending,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.UpdateOrderStatus(orderId+1, model.StatusPending, model.StatusPaid)
+			},
+			wantErr: ErrOrderStatusConflict,
+			want:    model.StatusPending,
+		},
+		{
+			name:   "update address conflict",
+			status: model.StatusShipped,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.UpdateOrderAddress(orderId, model.StatusPaid, "new address")
+			},
+			wantErr: ErrOrderStatusConflict,
+			want:    model.StatusShipped,
+		},
+		{
+			name:   "cancel",
+			status: model.StatusPending,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.CancelOrder(orderId, model.StatusPending, model.CancelReasonUserCancelled)
+			},
+			want: model.StatusCancelled,
+		},
+		{
+			name:   "cancel conflict",
+			status: model.StatusPaid,
+			update: func(repo OrderRepository, orderId int) error {
+				return repo.CancelOrder(orderId, model.StatusPending, model.CancelReasonUserCancelled)
+			},
+			wantErr: ErrOrderStatusConflict,
+			want:    model.StatusPaid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, order := newOrderFixture(t, tt.status)
+			err := tt.update(repo, order.Id)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			got, err := repo.FindOrderById(order.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Status)
+		})
+	}
+}
+
+func TestMemoryOrderRepositoryCancelRecordsReason(t *testing.T) {
+	repo, order := newOrderFixture(t, model.StatusPending)
+	require.NoError(t, repo.CancelOrder(order.Id, model.StatusPending, model.CancelReasonUserCancelled))
+
+	got, err := repo.FindOrderById(order.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.CancelReasonUserCancelled, got.CancelReason)
+	assert.NotNil(t, got.CancelledAt)
+}
+
+func TestMemoryOrderRepositoryApplyRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		from       model.OrderStatus
+		quantities func(order *model.Order) map[int]int
+		wantErr    error
+		wantStatus model.OrderStatus
+		wantItems  []int // 各订单行的已退款数量
+	}{
+		{
+			name: "partial refund",
+			from: model.StatusPaid,
+			quantities: func(order *model.Order) map[int]int {
+				return map[int]int{order.Items[0].Id: 1}
+			},
+			wantStatus: model.StatusPartiallyRefunded,
+			wantItems:  []int{1, 0},
+		},
+		{
+			name: "status changed concurrently",
+			from: model.StatusShipped,
+			quantities: func(order *model.Order) map[int]int {
+				return map[int]int{order.Items[0].Id: 1}
+			},
+			wantErr:    ErrOrderStatusConflict,
+			wantStatus: model.StatusPaid,
+			wantItems:  []int{0, 0},
+		},
+		{
+			name: "quantity exceeds purchase leaves every row untouched",
+			from: model.StatusPaid,
+			quantities: func(order *model.Order) map[int]int {
+				return map[int]int{order.Items[0].Id: 1, order.Items[1].Id: 2}
+			},
+			wantErr:    ErrOrderStatusConflict,
+			wantStatus: model.StatusPaid,
+			wantItems:  []int{0, 0},
+		},
+		{
+			name: "unknown order item",
+			from: model.StatusPaid,
+			quantities: func(order *model.Order) map[int]int {
+				return map[int]int{order.Items[1].Id + 1: 1}
+			},
+			wantErr:    ErrOrderStatusConflict,
+			wantStatus: model.StatusPaid,
+			wantItems:  []int{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, order := newOrderFixture(t, model.StatusPaid)
+			err := repo.ApplyRefund(order.Id, tt.from, model.StatusPartiallyRefunded, tt.quantities(order))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			got, err := repo.FindOrderById(order.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			refunded := make([]int, 0, len(got.Items))
+			for _, item := range got.Items {
+				refunded = append(refunded, item.RefundedQuantity)
+			}
+			assert.Equal(t, tt.wantItems, refunded)
+		})
+	}
+}
+
+func TestMemoryOrderRepositoryRevertRefund(t *testing.T) {
+	repo, order := newOrderFixture(t, model.StatusPaid)
+	itemId := order.Items[0].Id
+	require.NoError(t, repo.ApplyRefund(order.Id, model.StatusPaid, model.StatusRefunded, map[int]int{itemId: 2}))
+
+	err := repo.RevertRefund(order.Id, model.StatusRefunded, model.StatusPaid, map[int]int{itemId: 3})
+	assert.ErrorIs(t, err, ErrOrderStatusConflict, "cannot revert more than was refunded")
+	err = repo.RevertRefund(order.Id, model.StatusPaid, model.StatusPaid, map[int]int{itemId: 2})
+	assert.ErrorIs(t, err, ErrOrderStatusConflict, "status must match")
+
+	require.NoError(t, repo.RevertRefund(order.Id, model.StatusRefunded, model.StatusPaid, map[int]int{itemId: 2}))
+	got, err := repo.FindOrderById(order.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPaid, got.Status)
+	assert.Equal(t, 0, got.Items[0].RefundedQuantity)
+}
+
+func TestMemoryOrderRepositoryDeleteAndPurge(t *testing.T) {
+	repo, order := newOrderFixture(t, model.StatusCompleted)
+
+	require.NoError(t, repo.DeleteOrder(order.Id))
+	_, err := repo.FindOrderById(order.Id)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "soft deleted orders are hidden")
+	assert.ErrorIs(t, repo.DeleteOrder(order.Id), gorm.ErrRecordNotFound)
+
+	require.NoError(t, repo.PurgeOrder(order.Id))
+	assert.ErrorIs(t, repo.PurgeOrder(order.Id), gorm.ErrRecordNotFound)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"server/internal/product/order/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -10,13 +11,31 @@ import (
 type orderWriter interface {
 	CreateOrder(order *model.Order) error
 	UpdateOrder(order *model.Order) error
+	UpdateOrderStatus(orderId int, from, to model.OrderStatus) error
+	UpdateOrderAddress(orderId int, from model.OrderStatus, address string) error
+	CancelOrder(orderId int, from model.OrderStatus, reason string) error
+	ApplyRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error
+	RevertRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error
 	DeleteOrder(orderId int) error
+	PurgeOrder(orderId int) error
 }
 
 // orderReader 定义订单读操作接口
 type orderReader interface {
 	FindOrderById(orderId int) (*model.Order, error)
-	FindOrdersByUserId(userId int) ([]*model.Order, error)
+	FindOrderByOrderNo(orderNo string) (*model.Order, error)
+	FindOrderByOrderNoAndUserId(orderNo string, userId int) (*model.Order, error)
+	FindOrdersByUserId(userId int, query OrderQuery) ([]*model.Order, int64, error)
+}
+
+// OrderQuery 订单列表的查询条件
+type OrderQuery struct {
+	Status    model.OrderStatus // 订单状态，为空时不过滤
+	StartTime time.Time         // 创建时间下限（包含），零值表示不限
+	EndTime   time.Time         // 创建时间上限（不包含），零值表示不限
+	Ascending bool              // 是否按创建时间升序，默认降序（最新的在前）
+	Offset    int               // 分页偏移量
+	Limit     int               // 每页条数
 }
 
 // OrderRepository 订单操作的数据访问接口，组合了读写操作
@@ -34,7 +53,7 @@ func NewOrderRepository(gDB *gorm.DB) OrderRepository {
 	return &gormOrderRepository{gormDB: gDB}
 }
 
-// CreateOrder 在数据库中创建新订单记录
+// CreateOrder 在数据库中创建新订单记录，订单行随订单头在同一事务中一并写入
 func (oRepo *gormOrderRepository) CreateOrder(order *model.Order) error {
 	return oRepo.gormDB.Create(order).Error
 }
@@ -44,31 +63,214 @@ func (oRepo *gormOrderRepository) UpdateOrder(order *model.Order) error {
 	return oRepo.gormDB.Where("id=?", order.Id).Updates(order).Error
 }
 
-// DeleteOrder 根据ID从数据库中删除订单记录
+// UpdateOrderStatus 以条件更新的方式修改订单状态（仅当当前状态为from时才更新为to）
+// 用于防止并发的状态变更互相覆盖，例如超时取消与支付同时发生
+// 状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *gormOrderRepository) UpdateOrderStatus(orderId int, from, to model.OrderStatus) error {
+	result := oRepo.gormDB.Model(&model.Order{}).
+		Where("id = ? AND status = ?", orderId, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStatusConflict
+	}
+	return nil
+}
+
+// UpdateOrderAddress 以条件更新的方式修改订单的收货地址（仅当当前状态为from时才更新）
+// 防止地址修改与发货并发时把新地址写到已发货的订单上，状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *gormOrderRepository) UpdateOrderAddress(orderId int, from model.OrderStatus, address string) error {
+	result := oRepo.gormDB.Model(&model.Order{}).
+		Where("id = ? AND status = ?", orderId, from).
+		Updates(map[string]interface{}{"address": address, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStatusConflict
+	}
+	return nil
+}
+
+// CancelOrder 以条件更新的方式将订单置为已取消，并记录取消原因和取消时间
+// 仅当当前状态为from时才更新，状态已被其他请求修改时返回ErrOrderStatusConflict
+func (oRepo *gormOrderRepository) CancelOrder(orderId int, from model.OrderStatus, reason string) error {
+	now := time.Now()
+	result := oRepo.gormDB.Model(&model.Order{}).
+		Where("id = ? AND status = ?", orderId, from).
+		Updates(map[string]interface{}{
+			"status":        model.StatusCancelled,
+			"cancel_reason": reason,
+			"cancelled_at":  now,
+			"updated_at":    now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrderStatusConflict
+	}
+	return nil
+}
+
+// ApplyRefund 在同一事务中累加订单行的已退款数量，并以条件更新的方式修改订单状态
+// quantities为订单行ID到本次退款数量的映射
+// 订单状态已被修改或某个订单行的退款数量超过购买数量时返回ErrOrderStatusConflict，事务回滚
+func (oRepo *gormOrderRepository) ApplyRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error {
+	return oRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND status = ?", orderId, from).
+			Updates(map[string]interface{}{"status": to, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderStatusConflict
+		}
+
+		for itemId, quantity := range quantities {
+			result = tx.Model(&model.OrderItem{}).
+				Where("id = ? AND order_id = ? AND refunded_quantity + ? <= quantity", itemId, orderId, quantity).
+				Updates(map[string]interface{}{
+					"refunded_quantity": gorm.Expr("refunded_quantity + ?", quantity),
+					"updated_at":        now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOrderStatusConflict
+			}
+		}
+		return nil
+	})
+}
+
+// RevertRefund 撤销ApplyRefund写入的退款：在同一事务中扣减订单行的已退款数量，并以条件更新将订单状态从from恢复为to
+// 用于网关退款失败时回滚预留的退款，订单状态已被修改或某个订单行的已退款数量不足时返回ErrOrderStatusConflict，事务回滚
+func (oRepo *gormOrderRepository) RevertRefund(orderId int, from, to model.OrderStatus, quantities map[int]int) error {
+	return oRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.Order{}).
+			Where("id = ? AND status = ?", orderId, from).
+			Updates(map[string]interface{}{"status": to, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrderStatusConflict
+		}
+
+		for itemId, quantity := range quantities {
+			result = tx.Model(&model.OrderItem{}).
+				Where("id = ? AND order_id = ? AND refunded_quantity >= ?", itemId, orderId, quantity).
+				Updates(map[string]interface{}{
+					"refunded_quantity": gorm.Expr("refunded_quantity - ?", quantity),
+					"updated_at":        now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrOrderStatusConflict
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteOrder 根据ID软删除订单（写入deleted_at），订单及其订单行仍保留在数据库中
+// 软删除后的订单不会再被普通查询返回，直到被归档任务迁移到归档表
 func (oRepo *gormOrderRepository) DeleteOrder(orderId int) error {
-	err := oRepo.gormDB.Delete(&model.Order{}, orderId)
-	if err.RowsAffected == 0 {
+	result := oRepo.gormDB.Delete(&model.Order{}, orderId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
 		return gorm.ErrRecordNotFound
 	}
-	return err.Error
+	return nil
+}
+
+// PurgeOrder 根据ID从数据库中物理删除订单记录及其订单行
+// 只用于回滚刚创建、尚未对外暴露的订单，其他场景应使用DeleteOrder
+func (oRepo *gormOrderRepository) PurgeOrder(orderId int) error {
+	return oRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ?", orderId).Delete(&model.OrderItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&model.Order{}, orderId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
 }
 
 // FindOrderById 根据ID从数据库中查找订单
 func (oRepo *gormOrderRepository) FindOrderById(orderId int) (*model.Order, error) {
 	var order model.Order
-	err := oRepo.gormDB.First(&order, orderId).Error
+	err := oRepo.gormDB.Preload("Items").First(&order, orderId).Error
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-// FindOrdersByUserId 根据用户ID从数据库中查找该用户的所有订单
-func (oRepo *gormOrderRepository) FindOrdersByUserId(userId int) ([]*model.Order, error) {
-	var orders []*model.Order
-	err := oRepo.gormDB.Where("user_id = ?", userId).Find(&orders).Error
+// FindOrderByOrderNo 根据订单号查找订单，包括买家已软删除的订单，供系统任务和管理员使用
+func (oRepo *gormOrderRepository) FindOrderByOrderNo(orderNo string) (*model.Order, error) {
+	var order model.Order
+	err := oRepo.gormDB.Unscoped().Preload("Items").Where("order_no = ?", orderNo).First(&order).Error
 	if err != nil {
 		return nil, err
 	}
-	return orders, nil
+	return &order, nil
+}
+
+// FindOrderByOrderNoAndUserId 根据订单号查找属于指定用户的订单
+// 订单不存在或不属于该用户时都返回gorm.ErrRecordNotFound，避免泄露其他用户订单是否存在
+func (oRepo *gormOrderRepository) FindOrderByOrderNoAndUserId(orderNo string, userId int) (*model.Order, error) {
+	var order model.Order
+	err := oRepo.gormDB.Preload("Items").Where("order_no = ? AND user_id = ?", orderNo, userId).First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// FindOrdersByUserId 根据用户ID和查询条件分页查找该用户的订单
+// 返回当前页的订单（包含订单行）以及满足条件的订单总数
+func (oRepo *gormOrderRepository) FindOrdersByUserId(userId int, query OrderQuery) ([]*model.Order, int64, error) {
+	db := oRepo.gormDB.Model(&model.Order{}).Where("user_id = ?", userId)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if !query.StartTime.IsZero() {
+		db = db.Where("created_at >= ?", query.StartTime)
+	}
+	if !query.EndTime.IsZero() {
+		db = db.Where("created_at < ?", query.EndTime)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC, id DESC"
+	if query.Ascending {
+		order = "created_at ASC, id ASC"
+	}
+	var orders []*model.Order
+	err := db.Preload("Items").Order(order).Offset(query.Offset).Limit(query.Limit).Find(&orders).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
 }
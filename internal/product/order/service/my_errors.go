@@ -0,0 +1,41 @@
+package service
+
+import "errors"
+
+var (
+	// ErrEmptyOrderItems 订单中没有商品行
+	ErrEmptyOrderItems = errors.New("order has no items")
+
+	// ErrInsufficientStock 商品库存不足
+	ErrInsufficientStock = errors.New("insufficient stock")
+
+	// ErrCommodityNotFound 下单的商品不存在
+	ErrCommodityNotFound = errors.New("commodity not found")
+
+	// ErrInvalidTotalPrice 客户端提供的总价格式错误
+	ErrInvalidTotalPrice = errors.New("invalid total price")
+
+	// ErrPriceMismatch 客户端提供的总价与服务端计算的总价不一致（通常是商品价格已变动）
+	ErrPriceMismatch = errors.New("total price mismatch")
+
+	// ErrOrderNotFound 订单不存在
+	ErrOrderNotFound = errors.New("order not found")
+
+	// ErrInvalidOrderStatus 未定义的订单状态
+	ErrInvalidOrderStatus = errors.New("invalid order status")
+
+	// ErrIllegalTransition 订单当前状态不允许流转到目标状态
+	ErrIllegalTransition = errors.New("illegal order status transition")
+
+	// ErrInvalidRefundItems 退款的商品不在订单中或退款数量超过可退数量
+	ErrInvalidRefundItems = errors.New("invalid refund items")
+
+	// ErrOrderNotPending 订单不是待支付状态，没有支付截止时间
+	ErrOrderNotPending = errors.New("order is not pending payment")
+
+	// ErrAddressNotEditable 订单已发货或已结束，不能修改收货地址
+	ErrAddressNotEditable = errors.New("order address is not editable")
+
+	// ErrOrderNotDeletable 订单尚未结束，不能删除（待支付订单需先取消，已支付订单需先完成或退款）
+	ErrOrderNotDeletable = errors.New("order is not deletable")
+)
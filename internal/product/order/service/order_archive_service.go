@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"server/internal/product/order/model"
+	"server/internal/product/order/repository"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// archiveBatchSize 每批归档的订单数量，避免单个事务锁定过多行
+const archiveBatchSize = 100
+
+// archivableStatuses 可以归档的订单状态
+var archivableStatuses = []model.OrderStatus{model.StatusCompleted, model.StatusCancelled}
+
+// OrderArchiveService 提供订单归档相关的业务逻辑服务
+type OrderArchiveService struct {
+	aRepo repository.OrderArchiveRepository
+}
+
+// NewOrderArchiveService 创建一个新的订单归档服务实例
+func NewOrderArchiveService(aRepo repository.OrderArchiveRepository) *OrderArchiveService {
+	return &OrderArchiveService{aRepo: aRepo}
+}
+
+// ArchiveOrdersBefore 将最后更新时间早于before的已完成/已取消订单迁移到归档表
+// 按批次归档（每批一个事务），直到没有满足条件的订单，返回归档的订单总数
+// 某一批失败时返回错误，之前已提交的批次不受影响
+func (as *OrderArchiveService) ArchiveOrdersBefore(before time.Time) (int, error) {
+	total := 0
+	for {
+		count, err := as.aRepo.ArchiveOrders(archivableStatuses, before, archiveBatchSize)
+		total += count
+		if err != nil {
+			return total, err
+		}
+		if count < archiveBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Infof("Archived %d orders updated before %s", total, before.Format(time.RFC3339))
+	}
+	return total, nil
+}
+
+// GetArchivedOrder 根据订单号获取归档订单，不存在时返回ErrOrderNotFound
+func (as *OrderArchiveService) GetArchivedOrder(orderNo string) (*model.ArchivedOrder, error) {
+	archived, err := as.aRepo.FindArchivedOrderByOrderNo(orderNo)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return archived, err
+}
+
+// ListArchivedOrders 根据查询条件分页获取归档订单，同时返回满足条件的总数
+func (as *OrderArchiveService) ListArchivedOrders(query repository.ArchivedOrderQuery) ([]*model.ArchivedOrder, int64, error) {
+	if query.Status != "" && !query.Status.IsValid() {
+		return nil, 0, ErrInvalidOrderStatus
+	}
+	return as.aRepo.FindArchivedOrders(query)
+}
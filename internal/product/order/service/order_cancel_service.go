@@ -3,63 +3,72 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
+	"server/config"
+	commodityModel "server/internal/product/commodity/model"
 	commodityRepository "server/internal/product/commodity/repository"
+	"server/internal/product/order/model"
 	"server/internal/product/order/repository"
+	promotionService "server/internal/product/promotion/service"
 )
 
 // OrderCancelService 订单取消服务接口，负责超时订单的自动取消和库存归还
 type OrderCancelService interface {
-	createOrderTask(orderId int, commodityId int, stock int) error // 创建订单取消任务（15分钟后执行）
-	RemoveTimeoutOrderTasks() error                                // 处理超时订单，归还库存
+	createOrderTask(orderNo string, items []commodityRepository.StockItem, timeout time.Duration) error                                                // 创建订单取消任务（支付时限到期后执行）
+	cancelOrderTask(orderNo string) error                                                                                                              // 撤销订单取消任务
+	orderTaskTime(orderNo string) (time.Time, error)                                                                                                   // 获取订单取消任务的执行时间
+	restoreOrderStock(orderNo string, items []commodityRepository.StockItem, reason commodityModel.StockMovementReason, operator string) (bool, error) // 归还订单库存（幂等）
+	releaseOrderCoupon(orderId int)                                                                                                                    // 归还订单使用的优惠券次数（幂等）
+	RemoveTimeoutOrderTasks() error                                                                                                                    // 处理超时订单，归还库存
 }
 
 type cancelService struct {
 	cRedisRepo  commodityRepository.StockCacheRepository
 	oRepo       repository.OrderRepository
+	eRepo       repository.OrderEventRepository
 	redisDQRepo repository.OrderDQRepository
-	rDB         *redis.Client
+	couponSvc   *promotionService.CouponService
+	batchSize   int64 // 每次处理的最大到期任务数
 }
 
 // NewOrderCancelService 创建一个新的订单取消服务实例
-func NewOrderCancelService(redisDQRepo repository.OrderDQRepository, oRepo repository.OrderRepository, cRedisRepo commodityRepository.StockCacheRepository, rDB *redis.Client) OrderCancelService {
+func NewOrderCancelService(redisDQRepo repository.OrderDQRepository, oRepo repository.OrderRepository, eRepo repository.OrderEventRepository, cRedisRepo commodityRepository.StockCacheRepository, couponSvc *promotionService.CouponService, cfg *config.Config) OrderCancelService {
 	return &cancelService{
 		redisDQRepo: redisDQRepo,
 		oRepo:       oRepo,
+		eRepo:       eRepo,
 		cRedisRepo:  cRedisRepo,
-		rDB:         rDB,
+		couponSvc:   couponSvc,
+		batchSize:   int64(cfg.DelayQueue.BatchSize),
 	}
 }
 
-// createOrderTask 创建订单超时取消任务，15分钟后自动取消未支付订单
+// createOrderTask 创建订单超时取消任务，支付时限到期后自动取消未支付订单
 // 业务流程：
-// 1. 构建payload字符串（格式："commodityId,stock"）
+// 1. 构建payload字符串（格式："commodityId,stock;commodityId,stock"，每个订单行一段）
 // 2. 将任务加入Redis延迟队列（使用ZSet实现，score为执行时间戳）
-// 3. 15分钟后，订单取消调度器会扫描到期任务并处理
+// 3. 支付时限到期后，订单取消调度器会扫描到期任务并处理
 //
 // 延迟队列实现：
 // - 使用Redis ZSet存储任务，key为"dq:ready"
-// - score为任务执行时间的Unix时间戳（当前时间 + 15分钟）
-// - member为订单ID
-// - payload单独存储在"dq:payload:{orderId}"中
+// - score为任务执行时间的Unix时间戳（当前时间 + 支付时限）
+// - member为订单号
+// - payload单独存储在"dq:payload:{orderNo}"中
 //
 // 参数说明：
-// - orderId: 订单ID，用作延迟队列的任务ID
-// - commodityId: 商品ID，用于归还库存时定位商品
-// - stock: 需要归还的库存数量
-func (s *cancelService) createOrderTask(orderId int, commodityId int, stock int) error {
-	// 构建payload字符串，格式："commodityId,stock"
-	// 例如："123,5" 表示商品ID=123，数量=5
-	payload := strconv.Itoa(commodityId) + "," + strconv.Itoa(stock)
-
-	// 将任务加入延迟队列，15分钟后执行
-	err := s.redisDQRepo.EnqueueDelayTask(context.TODO(), strconv.Itoa(orderId), payload, time.Minute*15)
+// - orderNo: 订单号，用作延迟队列的任务ID
+// - items: 订单行对应的库存条目，用于归还库存时定位商品和数量
+// - timeout: 订单的支付时限
+func (s *cancelService) createOrderTask(orderNo string, items []commodityRepository.StockItem, timeout time.Duration) error {
+	// 将任务加入延迟队列，支付时限到期后执行
+	err := s.redisDQRepo.EnqueueDelayTask(context.TODO(), orderNo, encodeTaskPayload(items), timeout)
 	if err != nil {
 		return err
 	}
@@ -67,30 +76,137 @@ func (s *cancelService) createOrderTask(orderId int, commodityId int, stock int)
 	return nil
 }
 
-// RemoveTimeoutOrderTasks 扫描并处理超时订单，自动归还库存到Redis
+// cancelOrderTask 撤销订单的超时取消任务，移除延迟队列中的任务及其payload
+func (s *cancelService) cancelOrderTask(orderNo string) error {
+	return s.redisDQRepo.CancelTask(context.TODO(), orderNo)
+}
+
+// orderTaskTime 从延迟队列读取订单取消任务的执行时间（即订单的实际支付截止时间）
+// 任务已被取出处理、已完成或已撤销时返回repository.ErrTaskNotFound
+func (s *cancelService) orderTaskTime(orderNo string) (time.Time, error) {
+	return s.redisDQRepo.GetTaskTime(context.TODO(), orderNo)
+}
+
+// restoreOrderStock 归还订单所有订单行的库存，使用库存归还标记（见OrderDQRepository.MarkStockRestored）保证同一订单只归还一次
+// 归还时在同一原子操作中按reason（超时取消或主动取消）和operator记录库存流水
+// 返回值：
+// - bool: 本次是否实际归还了库存（标记已存在说明已经归还过，返回false）
+// - error: 标记失败或归还失败，此时标记会被清除，允许之后重试
+func (s *cancelService) restoreOrderStock(orderNo string, items []commodityRepository.StockItem, reason commodityModel.StockMovementReason, operator string) (bool, error) {
+	ctx := context.TODO()
+	success, err := s.redisDQRepo.MarkStockRestored(ctx, orderNo, time.Hour*24)
+	if err != nil {
+		return false, err
+	}
+	if !success {
+		log.Warnf("Stock of order %s already restored (idempotent key exists)", orderNo)
+		return false, nil
+	}
+
+	movement := &commodityRepository.MovementInfo{Reason: reason, OrderNo: orderNo, Operator: operator}
+	if err = s.cRedisRepo.IncreaseStockBatch(ctx, items, movement); err != nil {
+		if unmarkErr := s.redisDQRepo.UnmarkStockRestored(ctx, orderNo); unmarkErr != nil {
+			log.Errorf("Failed to unmark restored stock of order %s: %v", orderNo, unmarkErr)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseOrderCoupon 归还订单使用的优惠券次数，使用记录的条件更新保证同一订单只归还一次
+// 归还失败只记录日志：使用次数少归还一次只会让优惠券提前用完，不会超发
+func (s *cancelService) releaseOrderCoupon(orderId int) {
+	released, err := s.couponSvc.ReleaseOrderCoupon(orderId)
+	if err != nil {
+		log.Errorf("Failed to release coupon of order %d: %v", orderId, err)
+		return
+	}
+	if released {
+		log.Infof("Released coupon usage of order %d", orderId)
+	}
+}
+
+// encodeTaskPayload 将库存条目编码为延迟任务的payload
+// 例如："123,5,1;456,1,1,7,42" 表示仓库1中商品123数量5、商品456数量1，
+// 商品456参与了秒杀活动7（下单用户42），订单记录丢失时仍能据此归还限购计数
+func encodeTaskPayload(items []commodityRepository.StockItem) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		part := strconv.Itoa(item.CommodityId) + "," + strconv.Itoa(item.Quantity) + "," + strconv.Itoa(item.WarehouseId)
+		if item.Limit != nil {
+			part += "," + strconv.Itoa(item.Limit.FlashSaleId) + "," + strconv.Itoa(item.Limit.UserId)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ";")
+}
+
+// decodeTaskPayload 解析延迟任务的payload，兼容不含仓库的旧格式"commodityId,stock"（归属默认仓库）
+// 带秒杀活动和用户的条目解析为带Limit的库存条目，归还时同时归还限购计数
+func decodeTaskPayload(payload string) ([]commodityRepository.StockItem, error) {
+	segments := strings.Split(payload, ";")
+	items := make([]commodityRepository.StockItem, 0, len(segments))
+	for _, segment := range segments {
+		parts := strings.Split(segment, ",")
+		if len(parts) != 2 && len(parts) != 3 && len(parts) != 5 {
+			return nil, fmt.Errorf("invalid payload segment %q", segment)
+		}
+		commodityId, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid commodityId %q", parts[0])
+		}
+		stock, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid stock %q", parts[1])
+		}
+		warehouseId := commodityModel.DefaultWarehouseId
+		if len(parts) >= 3 {
+			if warehouseId, err = strconv.Atoi(parts[2]); err != nil {
+				return nil, fmt.Errorf("invalid warehouseId %q", parts[2])
+			}
+		}
+		item := commodityRepository.StockItem{CommodityId: commodityId, WarehouseId: warehouseId, Quantity: stock}
+		if len(parts) == 5 {
+			flashSaleId, err := strconv.Atoi(parts[3])
+			if err != nil {
+				return nil, fmt.Errorf("invalid flashSaleId %q", parts[3])
+			}
+			userId, err := strconv.Atoi(parts[4])
+			if err != nil {
+				return nil, fmt.Errorf("invalid userId %q", parts[4])
+			}
+			item.Limit = &commodityRepository.PurchaseLimit{FlashSaleId: flashSaleId, UserId: userId}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// RemoveTimeoutOrderTasks 扫描并处理超时订单，取消未支付的订单并归还库存到Redis
 // 业务流程：
-//  1. 从延迟队列中获取到期的任务（最多100个）
-//  2. 遍历每个到期任务：
-//     a. 设置幂等性键（防止重复处理）
-//     b. 解析任务ID（订单ID）
-//     c. 从Redis获取payload（包含商品ID和库存数量）
-//     d. 调用IncreaseStock归还库存到Redis
-//     e. 从延迟队列中移除任务
-//  3. 记录处理日志
+//  1. 从延迟队列中获取到期的任务（每次最多delayQueue.batchSize个）
+//  2. 遍历每个到期任务（任务ID即订单号），通过OrderRepository读取订单当前状态：
+//     a. pending: 以条件更新将订单置为cancelled，记录取消原因为payment_timeout
+//     b. cancelled: 归还所有订单行的库存（幂等，已归还过则跳过），并归还订单使用的优惠券次数
+//     c. paid/shipped等其他状态: 订单已支付，不取消、不归还库存
+//     d. 订单不存在: 依据payload归还库存
+//  3. 处理完成后从延迟队列中移除任务
 //
 // 错误处理：
 // - ErrNoTasksDue: 没有到期任务，等待500ms后返回
 // - ErrNoTasksInQueue: 队列为空，等待1秒后返回
 // - ErrQueueOperationFailed: 队列操作失败，等待200ms后返回错误
+// - 单个任务处理失败时任务保留在processing队列，由RecoveryScheduler恢复后重试
 //
 // 幂等性保护：
-// - 使用Redis SetNX设置幂等性键（格式：order_cancel_idempotent:{orderId}）
-// - 如果幂等性键已存在，说明订单已处理过，只删除任务不归还库存
+// - 使用Redis SetNX设置幂等性键（格式：order_cancel_idempotent:{orderNo}）
+// - 如果幂等性键已存在，说明库存已归还过，只删除任务不归还库存
 // - 幂等性键24小时后自动过期
-// - 解决了"归还库存成功但删除任务失败"导致的重复归还问题
+// - 先写入cancelled状态再归还库存：与支付并发时条件更新只有一方能成功，已支付订单不会被归还库存
+// - 订单已是cancelled但归还库存失败时，重试会按幂等性键补偿归还
 func (s *cancelService) RemoveTimeoutOrderTasks() error {
-	// 从延迟队列获取到期任务（最多100个）
-	ids, err := s.redisDQRepo.GetReadyTasks(context.TODO(), 100)
+	// 从延迟队列获取到期任务（每次最多batchSize个）
+	ids, err := s.redisDQRepo.GetReadyTasks(context.TODO(), s.batchSize)
 
 	// 处理预期的错误情况（这些不是真正的错误，只是队列状态）
 	if err != nil {
@@ -117,97 +233,96 @@ func (s *cancelService) RemoveTimeoutOrderTasks() error {
 	}
 
 	// 处理获取到的过期订单
-	for _, id := range ids {
-		// 解析订单ID
-		orderId, err := strconv.Atoi(id)
-		if err != nil {
-			log.Warn("invalid order id:", err)
-			continue // 跳过无效ID，继续处理下一个
+	var errs []error
+	for _, orderNo := range ids {
+		if err := s.handleTimeoutTask(orderNo); err != nil {
+			// 任务保留在processing队列中，超时后由RecoveryScheduler移回ready队列重试
+			log.Errorf("Failed to handle timeout task of order %s: %v", orderNo, err)
+			errs = append(errs, err)
 		}
+	}
+	return errors.Join(errs...)
+}
 
-		// 幂等性保护：尝试设置幂等性键
-		// 格式：order_cancel_idempotent:{orderId}
-		// SetNX：只在键不存在时设置，返回true表示设置成功（首次处理）
-		idempotentKey := "order_cancel_idempotent:" + id
-		ctx := context.TODO()
-		success, err := s.rDB.SetNX(ctx, idempotentKey, "1", time.Hour*24).Result()
-		if err != nil {
-			log.Errorf("Failed to set idempotent key for order %d: %v", orderId, err)
-			continue // Redis错误，跳过此任务，下次重试
+// handleTimeoutTask 处理单个到期的订单超时任务，任务ID即订单号
+func (s *cancelService) handleTimeoutTask(orderNo string) error {
+	ctx := context.TODO()
+	order, err := s.oRepo.FindOrderByOrderNo(orderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 订单记录已不存在，只能依据payload归还库存
+			return s.restoreStockFromPayload(orderNo)
 		}
+		return err
+	}
 
-		if !success {
-			// 幂等性键已存在，说明这个订单已经处理过了
-			log.Warnf("Order %d already cancelled (idempotent key exists), only removing task", orderId)
-
-			// 只删除任务，不归还库存（因为库存已经归还过了）
-			err = s.redisDQRepo.RemoveTask(ctx, id)
-			if err != nil {
-				log.Errorf("Failed to remove duplicate task %s: %v", id, err)
-				// 不return，继续处理下一个任务
-			} else {
-				log.Infof("Successfully removed duplicate task for order %d", orderId)
+	// 待支付订单：先写入cancelled状态（条件更新），防止与支付并发时归还已支付订单的库存
+	if order.Status == model.StatusPending {
+		err = s.oRepo.CancelOrder(order.Id, model.StatusPending, model.CancelReasonPaymentTimeout)
+		switch {
+		case err == nil:
+			order.Status = model.StatusCancelled
+			log.Infof("Order %s cancelled: %s", orderNo, model.CancelReasonPaymentTimeout)
+			recordOrderEvent(s.eRepo, &model.OrderEvent{
+				OrderId:    order.Id,
+				Type:       model.EventCancelled,
+				FromStatus: model.StatusPending,
+				ToStatus:   model.StatusCancelled,
+				Operator:   model.OperatorSystem,
+				Detail:     model.CancelReasonPaymentTimeout,
+			})
+		case errors.Is(err, repository.ErrOrderStatusConflict):
+			// 状态被并发修改（如刚刚完成支付），重新读取订单状态
+			if order, err = s.oRepo.FindOrderByOrderNo(orderNo); err != nil {
+				return err
 			}
-			continue
+		default:
+			return err
 		}
+	}
 
-		// 从Redis获取payload（格式："commodityId,stock"）
-		payload, err := s.rDB.Get(ctx, "dq:payload:"+id).Result()
+	if order.Status == model.StatusCancelled {
+		restored, err := s.restoreOrderStock(orderNo, toStockItems(order.WarehouseId, order.UserId, order.Items), commodityModel.MovementOrderTimeout, model.OperatorSystem)
 		if err != nil {
-			log.Warnf("Failed to get payload for order %d: %v", orderId, err)
-			// payload不存在，删除幂等性键，下次重试
-			s.rDB.Del(ctx, idempotentKey)
-			continue
+			return err
 		}
-
-		// 解析payload，提取商品ID和库存数量
-		parts := strings.Split(payload, ",")
-		if len(parts) != 2 {
-			log.Warnf("Invalid payload format for order %d: %s", orderId, payload)
-			// payload格式错误，删除幂等性键和任务
-			s.rDB.Del(ctx, idempotentKey)
-			_ = s.redisDQRepo.RemoveTask(ctx, id)
-			continue
+		if restored {
+			log.Infof("Successfully restored stock of cancelled order %s", orderNo)
 		}
-
-		// 将payload解析成commodityId和stock
-		commodityId, err := strconv.Atoi(parts[0])
-		if err != nil {
-			log.Warnf("Invalid commodityId in payload: %s", parts[0])
-			s.rDB.Del(ctx, idempotentKey)
-			_ = s.redisDQRepo.RemoveTask(ctx, id)
-			continue
-		}
-		stock, err := strconv.Atoi(parts[1])
-		if err != nil {
-			log.Warnf("Invalid stock in payload: %s", parts[1])
-			s.rDB.Del(ctx, idempotentKey)
-			_ = s.redisDQRepo.RemoveTask(ctx, id)
-			continue
+		if order.CouponId != 0 {
+			s.releaseOrderCoupon(order.Id)
 		}
+	} else {
+		log.Infof("Order %s is %s, skip timeout cancellation", orderNo, order.Status)
+	}
 
-		// 归还库存到Redis（使用Lua脚本保证原子性）
-		err = s.cRedisRepo.IncreaseStock(ctx, commodityId, stock)
-		if err != nil {
-			log.Errorf("Failed to increase stock for order %d: %v", orderId, err)
-			// 归还失败，删除幂等性键，任务保留在队列中，下次重试
-			s.rDB.Del(ctx, idempotentKey)
-			return err
-		}
+	// 从延迟队列中移除任务
+	// 删除失败时库存已归还，幂等性键会阻止下次重复归还，只需等待重试删除任务
+	if err = s.redisDQRepo.RemoveTask(ctx, orderNo); err != nil {
+		log.Errorf("Failed to remove task %s (safe to retry): %v", orderNo, err)
+	}
+	return nil
+}
 
-		//  库存归还成功，幂等性键保留
-		// 从延迟队列中移除任务
-		err = s.redisDQRepo.RemoveTask(ctx, id)
-		if err != nil {
-			// ⚠️ 任务删除失败，但库存已归还
-			// 由于幂等性键已存在，下次处理时会检测到并跳过库存归还，只删除任务
-			// 不会重复归还库存！
-			log.Errorf("Failed to remove task %s (stock already restored, safe to retry): %v", id, err)
-			// 不return，继续处理下一个任务
-			continue
-		}
+// restoreStockFromPayload 订单记录不存在时，依据延迟任务的payload归还库存
+func (s *cancelService) restoreStockFromPayload(orderNo string) error {
+	ctx := context.TODO()
+	// 获取任务的payload（格式："commodityId,stock,warehouseId[,flashSaleId,userId];..."，见encodeTaskPayload）
+	payload, err := s.redisDQRepo.GetTaskPayload(ctx, orderNo)
+	if err != nil {
+		return fmt.Errorf("failed to get payload for order %s: %w", orderNo, err)
+	}
 
-		log.Infof("Successfully cancelled expired order %d and restored stock %d for commodity %d", orderId, stock, commodityId)
+	items, err := decodeTaskPayload(payload)
+	if err != nil {
+		// payload格式错误，无法归还库存，直接删除任务
+		log.Warnf("Invalid payload format for order %s: %s (%v)", orderNo, payload, err)
+		return s.redisDQRepo.RemoveTask(ctx, orderNo)
 	}
-	return nil
+
+	if _, err = s.restoreOrderStock(orderNo, items, commodityModel.MovementOrderTimeout, model.OperatorSystem); err != nil {
+		return err
+	}
+	log.Warnf("Order %s not found, restored stock %v from task payload", orderNo, items)
+	return s.redisDQRepo.RemoveTask(ctx, orderNo)
 }
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"server/internal/product/order/model"
+	"server/internal/product/order/repository"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// recordOrderEvent 追加一条订单事件
+// 事件在订单变更成功后写入，写入失败只记录日志，不影响已完成的变更
+func recordOrderEvent(eRepo repository.OrderEventRepository, event *model.OrderEvent) {
+	event.CreatedAt = time.Now()
+	if err := eRepo.CreateEvent(event); err != nil {
+		log.Errorf("Failed to record %s event of order %d: %v", event.Type, event.OrderId, err)
+	}
+}
+
+// GetOrderEvents 获取用户自己订单的事件历史
+func (os *OrderService) GetOrderEvents(userId int, orderNo string) ([]*model.OrderEvent, error) {
+	order, err := os.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	return os.eRepo.FindEventsByOrderId(order.Id)
+}
+
+// GetOrderEventsForAdmin 获取任意订单的事件历史，供客服和管理员排查问题
+// 已删除和已归档订单的事件仍然保留：订单号先在订单表（包括已软删除的订单）中查找，找不到时再查找归档订单
+func (os *OrderService) GetOrderEventsForAdmin(orderNo string) ([]*model.OrderEvent, error) {
+	var orderId int
+	order, err := os.oRepo.FindOrderByOrderNo(orderNo)
+	switch {
+	case err == nil:
+		orderId = order.Id
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		archived, err := os.aRepo.FindArchivedOrderByOrderNo(orderNo)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrOrderNotFound
+			}
+			return nil, err
+		}
+		orderId = archived.Id
+	default:
+		return nil, err
+	}
+	return os.eRepo.FindEventsByOrderId(orderId)
+}
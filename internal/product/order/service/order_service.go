@@ -2,124 +2,799 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"server/config"
+	commodityModel "server/internal/product/commodity/model"
 	commodityRepository "server/internal/product/commodity/repository"
+	commodityService "server/internal/product/commodity/service"
 	"server/internal/product/order/model"
 	"server/internal/product/order/repository"
+	promotionModel "server/internal/product/promotion/model"
+	promotionService "server/internal/product/promotion/service"
+	"server/pkg/idgen"
+	"server/pkg/money"
 	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 // OrderService 提供订单相关的业务逻辑服务
 type OrderService struct {
 	oRepo              repository.OrderRepository
+	eRepo              repository.OrderEventRepository
+	aRepo              repository.OrderArchiveRepository
 	cRedisRepo         commodityRepository.StockCacheRepository
 	commodityRepo      commodityRepository.CommodityRepository
+	stockCacheSvc      *commodityService.StockCacheService
+	warehouseSvc       *commodityService.WarehouseService
+	couponSvc          *promotionService.CouponService
+	flashSaleSvc       *promotionService.FlashSaleService
 	orderCancelService OrderCancelService
+	idGen              *idgen.Generator
+	cfg                *config.Config
 }
 
 // NewOrderService 创建一个新的订单服务实例
-func NewOrderService(oRepo repository.OrderRepository, cRedisRepo commodityRepository.StockCacheRepository, commodityRepo commodityRepository.CommodityRepository, orderCancelService OrderCancelService) *OrderService {
+func NewOrderService(oRepo repository.OrderRepository, eRepo repository.OrderEventRepository, aRepo repository.OrderArchiveRepository, cRedisRepo commodityRepository.StockCacheRepository, commodityRepo commodityRepository.CommodityRepository, stockCacheSvc *commodityService.StockCacheService, warehouseSvc *commodityService.WarehouseService, couponSvc *promotionService.CouponService, flashSaleSvc *promotionService.FlashSaleService, orderCancelService OrderCancelService, idGen *idgen.Generator, cfg *config.Config) *OrderService {
 	return &OrderService{
 		oRepo:              oRepo,
+		eRepo:              eRepo,
+		aRepo:              aRepo,
 		cRedisRepo:         cRedisRepo,
 		commodityRepo:      commodityRepo,
+		stockCacheSvc:      stockCacheSvc,
+		warehouseSvc:       warehouseSvc,
+		couponSvc:          couponSvc,
+		flashSaleSvc:       flashSaleSvc,
 		orderCancelService: orderCancelService,
+		idGen:              idGen,
+		cfg:                cfg,
 	}
 }
 
-// CreateOrder 创建订单，先扣减Redis库存，成功后创建订单并加入延迟取消队列
+// CreateOrder 创建订单，由服务端计算订单金额，先原子性扣减所有商品的Redis库存，成功后创建订单并加入延迟取消队列
 // 业务流程：
-// 1. 构建订单对象（状态为pending，设置创建时间和更新时间）
-// 2. 尝试从Redis扣减库存（使用Lua脚本保证原子性）
-// 3. 如果Redis缓存未初始化（code=2），从MySQL查询库存并初始化Redis缓存
-// 4. 扣减成功后创建订单记录
-// 5. 将订单加入延迟取消队列（15分钟后自动取消）
+// 1. 合并相同商品的订单行（同一商品只保留一行，数量累加）
+// 2. 根据商品当前价格计算每个订单行的小计和订单总金额（以分为单位），秒杀进行中的商品按秒杀价计算，秒杀未开始的商品直接拒绝
+// 3. 如果提供了券码，校验优惠券、计算优惠金额并在Redis中原子性地占用一次使用次数
+// 4. 如果客户端提供了总价，校验与服务端计算的应付金额是否一致，不一致则拒绝下单
+// 5. 批量扣减Redis库存（Lua脚本保证所有商品全部成功或全部失败，秒杀商品在同一脚本中检查用户限购和活动配额）
+// 6. 如果某个商品的Redis缓存未初始化（code=2），从MySQL加载该商品库存后重新扣减
+// 7. 扣减成功后创建订单头和订单行，并记录优惠券使用
+// 8. 将订单加入延迟取消队列（超过支付时限后自动取消，归还所有订单行的库存和优惠券次数）
 //
-// 库存扣减返回码说明：
-// - code=0: 扣减成功
-// - code=1: 扣减失败（网络错误等）
-// - code=2: Redis缓存未初始化
-// - code=3: 库存不足
+// 支付时限默认为配置order.paymentTimeoutMinutes，订单中的商品在order.paymentTimeoutOverrides中有覆盖时取最短的时限
 //
-// ⚠️ 已知问题（需修复）：
-// 1. 当code=2时，只初始化了缓存但没有重新扣减库存
-// 2. 当code=3时（库存不足），没有return，会继续创建订单
-// 3. 这两个问题会导致：超卖、库存数据不一致
+// 参数说明：
+// - couponCode: 优惠券券码，为空时不使用优惠券
+// - expectedTotal: 客户端展示的应付金额（如"12.34"），为空时不校验
 //
-// 正确的处理逻辑应该是：
-// - code=2: 初始化缓存后重新调用DecreaseStock
-// - code=3: 直接return错误，不创建订单
-func (os *OrderService) CreateOrder(userId int, commodityId int, quantity int, totalPrice string, address string) error {
+// 失败回滚：
+// - 占用优惠券后的任何失败：归还占用的优惠券次数
+// - 创建订单记录失败：归还已扣减的库存
+// - 记录优惠券使用或加入延迟队列失败：删除订单记录并归还已扣减的库存
+func (os *OrderService) CreateOrder(userId int, items []model.OrderItem, couponCode string, expectedTotal string, address string) (*model.Order, error) {
+	items = mergeOrderItems(items)
+	if len(items) == 0 {
+		return nil, ErrEmptyOrderItems
+	}
+
+	// 根据商品当前价格计算订单金额
+	totalAmount, flashSales, err := os.priceOrderItems(items)
+	if err != nil {
+		return nil, err
+	}
+	var expected money.Amount
+	if expectedTotal != "" {
+		if expected, err = money.Parse(expectedTotal); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTotalPrice, err)
+		}
+	}
+
+	// 使用优惠券时先占用一次使用次数，之后的任何失败都要归还
+	var discount *promotionService.Discount
+	if couponCode != "" {
+		if discount, err = os.couponSvc.ReserveCoupon(userId, couponCode, toItemAmounts(items)); err != nil {
+			return nil, err
+		}
+	}
+	releaseCoupon := func() {
+		if discount != nil {
+			os.couponSvc.ReleaseCoupon(discount, userId)
+		}
+	}
+
+	payAmount := totalAmount
+	if discount != nil {
+		payAmount -= discount.Amount
+	}
+	if expectedTotal != "" && expected != payAmount {
+		releaseCoupon()
+		return nil, fmt.Errorf("%w: expected %s, actual %s", ErrPriceMismatch, expected, payAmount)
+	}
+	// 对外暴露的订单号，同时用作延迟队列的任务ID和库存流水的关联订单
+	orderNo := os.idGen.NextNo()
+
+	ctx := context.TODO()
+	// 选择发货仓库并扣减所有订单行的库存（全部成功或全部失败，防止超卖）
+	stockItems, err := os.reserveStock(ctx, orderNo, userId, address, items, flashSales)
+	if err != nil {
+		releaseCoupon()
+		return nil, err
+	}
+
 	// 构建订单对象，初始状态为pending（待支付）
+	now := time.Now()
+	timeout := os.paymentTimeout(items)
+	payDeadline := now.Add(timeout)
+	for i := range items {
+		items[i].CreatedAt = now
+		items[i].UpdatedAt = now
+	}
 	order := &model.Order{
+		OrderNo:     orderNo,
 		UserId:      userId,
-		CommodityId: commodityId,
-		Quantity:    quantity,
-		TotalPrice:  totalPrice,
-		Status:      "pending",  // 订单状态：pending待支付、paid已支付、cancelled已取消、completed已完成
+		TotalAmount: totalAmount,
+		PayAmount:   payAmount,
+		Currency:    money.DefaultCurrency,
+		Status:      model.StatusPending, // 订单初始状态为待支付，后续流转见model.OrderStatus
 		Address:     address,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		WarehouseId: stockItems[0].WarehouseId,
+		PayDeadline: &payDeadline,
+		Items:       items,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	// 创建订单记录（订单头和订单行在同一事务中写入）
+	if discount != nil {
+		order.DiscountAmount = discount.Amount
+		order.CouponId = discount.CouponId
+		for i := range order.Items {
+			order.Items[i].DiscountAmount = discount.Items[order.Items[i].CommodityId]
+		}
+	}
+	if err := os.oRepo.CreateOrder(order); err != nil {
+		os.releaseStock(ctx, orderNo, stockItems)
+		releaseCoupon()
+		return nil, err
+	}
+	rollback := func() {
+		if delErr := os.oRepo.PurgeOrder(order.Id); delErr != nil {
+			log.Errorf("Failed to rollback order %d: %v", order.Id, delErr)
+		}
+		os.releaseStock(ctx, orderNo, stockItems)
+	}
+
+	// 记录优惠券使用，之后订单取消时按使用记录归还次数
+	if discount != nil {
+		if err := os.couponSvc.ConfirmCoupon(discount, userId, order.Id); err != nil {
+			rollback()
+			releaseCoupon()
+			return nil, err
+		}
+	}
+
+	// 将订单加入延迟取消队列（支付时限到期后如果还是pending状态，会自动取消并归还库存）
+	if err := os.orderCancelService.createOrderTask(order.OrderNo, stockItems, timeout); err != nil {
+		rollback()
+		if discount != nil {
+			os.orderCancelService.releaseOrderCoupon(order.Id)
+		}
+		return nil, err
+	}
+
+	recordOrderEvent(os.eRepo, &model.OrderEvent{
+		OrderId:  order.Id,
+		Type:     model.EventCreated,
+		ToStatus: order.Status,
+		Operator: model.OperatorUser(userId),
+		Detail:   orderCreatedDetail(order, discount),
+	})
+	return order, nil
+}
+
+// orderCreatedDetail 生成订单创建事件的说明，包含金额、商品行数和使用的优惠券
+func orderCreatedDetail(order *model.Order, discount *promotionService.Discount) string {
+	detail := fmt.Sprintf("total %s %s, %d items", order.TotalAmount, order.Currency, len(order.Items))
+	if discount != nil {
+		detail += fmt.Sprintf(", coupon %s -%s, pay %s", discount.Code, discount.Amount, order.PayAmount)
+	}
+	return detail
+}
+
+// paymentTimeout 计算订单的支付时限，订单中任一商品配置了更短的时限时以最短的为准
+func (os *OrderService) paymentTimeout(items []model.OrderItem) time.Duration {
+	minutes := os.cfg.Order.PaymentTimeoutMinutes
+	for _, item := range items {
+		if override, ok := os.cfg.Order.PaymentTimeoutOverrides[item.CommodityId]; ok && override > 0 && override < minutes {
+			minutes = override
+		}
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// GetPaymentDeadline 获取用户待支付订单的支付截止时间
+// 优先读取延迟队列中取消任务的执行时间（实际取消时间），任务已被取出处理时使用订单上记录的截止时间
+// 订单不是pending状态时返回ErrOrderNotPending
+func (os *OrderService) GetPaymentDeadline(userId int, orderNo string) (*model.Order, time.Time, error) {
+	order, err := os.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if order.Status != model.StatusPending {
+		return nil, time.Time{}, fmt.Errorf("%w: order %s is %s", ErrOrderNotPending, orderNo, order.Status)
+	}
+
+	deadline, err := os.orderCancelService.orderTaskTime(order.OrderNo)
+	if err != nil {
+		if !errors.Is(err, repository.ErrTaskNotFound) {
+			return nil, time.Time{}, err
+		}
+		if order.PayDeadline == nil {
+			// 任务已被取出等待取消，且订单没有记录截止时间（旧订单），视为已到期
+			return order, time.Now(), nil
+		}
+		deadline = *order.PayDeadline
+	}
+	return order, deadline, nil
+}
+
+// priceOrderItems 根据商品当前价格填充每个订单行的单价和小计，返回订单总金额和订单行参与的秒杀活动（商品ID -> 秒杀活动）
+// 秒杀进行中的商品按秒杀价计算并记录秒杀活动ID；秒杀已启用但尚未开始的商品返回ErrFlashSaleNotStarted
+func (os *OrderService) priceOrderItems(items []model.OrderItem) (money.Amount, map[int]*promotionModel.FlashSale, error) {
+	var total money.Amount
+	now := time.Now()
+	flashSales := make(map[int]*promotionModel.FlashSale)
+	for i := range items {
+		commodity, err := os.commodityRepo.FindCommodityById(items[i].CommodityId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return 0, nil, fmt.Errorf("%w: %d", ErrCommodityNotFound, items[i].CommodityId)
+			}
+			return 0, nil, err
+		}
+		items[i].UnitPrice = money.FromFloat(commodity.Price)
+
+		sale, err := os.flashSaleSvc.CurrentFlashSale(items[i].CommodityId, now)
+		if err != nil {
+			return 0, nil, err
+		}
+		if sale != nil {
+			items[i].UnitPrice = sale.SalePrice
+			items[i].FlashSaleId = sale.Id
+			flashSales[items[i].CommodityId] = sale
+		}
+		items[i].Amount = items[i].UnitPrice.Mul(items[i].Quantity)
+		total += items[i].Amount
+	}
+	return total, flashSales, nil
+}
+
+// reserveStock 按仓库分配策略依次尝试在候选仓库中批量扣减订单的全部库存，返回在选中仓库扣减的库存条目
+// 遇到缓存未初始化的商品时从MySQL加载后重试，某个仓库库存不足时尝试下一个仓库，扣减成功后记录下单扣减的库存流水
+// 库存扣减返回码说明：
+// - code=0: 扣减成功
+// - code=1: 扣减失败（网络错误等）
+// - code=2: 某个商品的Redis缓存未初始化
+// - code=3: 某个商品库存不足
+//
+// - code=4: 某个秒杀商品的用户限购已达上限
+// - code=5: 某个秒杀商品的活动配额已售完
+//
+// 注意：订单不拆分，所有商品从同一仓库发货；所有仓库都不足时返回最后一个仓库中不足的商品
+// 限购和配额与仓库无关，不满足时直接返回，不再尝试其他仓库
+func (os *OrderService) reserveStock(ctx context.Context, orderNo string, userId int, address string, orderItems []model.OrderItem, flashSales map[int]*promotionModel.FlashSale) ([]commodityRepository.StockItem, error) {
+	candidates, err := os.warehouseSvc.AllocationCandidates(ctx, address, toStockItems(0, userId, orderItems))
+	if err != nil {
+		return nil, err
+	}
+	movement := &commodityRepository.MovementInfo{Reason: commodityModel.MovementOrderReserve, OrderNo: orderNo, Operator: model.OperatorUser(userId)}
+	insufficient := 0
+	for _, warehouseId := range candidates {
+		items := toStockItems(warehouseId, userId, orderItems)
+		for _, item := range items {
+			if sale, ok := flashSales[item.CommodityId]; ok && item.Limit != nil {
+				item.Limit.PerUserLimit = sale.PerUserLimit
+				item.Limit.Quota = sale.Quota
+				item.Limit.ExpireAt = os.flashSaleSvc.CounterExpireAt(sale)
+			}
+		}
+		code, commodityId, err := os.decreaseStock(ctx, items, movement)
+		switch code {
+		case 0: // 扣减成功
+			return items, nil
+		case 3: // 该仓库库存不足，尝试下一个仓库
+			insufficient = commodityId
+		case 4: // 用户限购已达上限
+			return nil, fmt.Errorf("%w: commodity %d", promotionService.ErrFlashSaleUserLimit, commodityId)
+		case 5: // 秒杀配额已售完
+			return nil, fmt.Errorf("%w: commodity %d", promotionService.ErrFlashSaleSoldOut, commodityId)
+		default:
+			return nil, err
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w: no available warehouse", ErrInsufficientStock)
+	}
+	return nil, fmt.Errorf("%w: commodity %d", ErrInsufficientStock, insufficient)
+}
+
+// decreaseStock 在单个仓库中批量扣减库存并记录下单扣减的库存流水，遇到缓存未初始化的商品时从MySQL加载后重试
+// 返回码同DecreaseStockBatch，code=3时返回库存不足的商品ID
+func (os *OrderService) decreaseStock(ctx context.Context, items []commodityRepository.StockItem, movement *commodityRepository.MovementInfo) (int, int, error) {
+	// 每个商品最多初始化一次缓存，因此最多重试len(items)次
+	for attempt := 0; attempt <= len(items); attempt++ {
+		code, commodityId, err := os.cRedisRepo.DecreaseStockBatch(ctx, items, movement)
+		switch code {
+		case 0, 3, 4, 5:
+			return code, commodityId, nil
+		case 2: // Redis缓存未初始化，从MySQL加载商品库存到缓存后重试（并发未命中只加载一次）
+			if err := os.stockCacheSvc.LoadStockCache(ctx, commodityId, items[0].WarehouseId); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return 1, commodityId, fmt.Errorf("%w: %d", ErrCommodityNotFound, commodityId)
+				}
+				return 1, commodityId, err
+			}
+		default: // 扣减失败（网络错误、参数错误等）
+			return 1, commodityId, err
+		}
+	}
+	return 1, 0, fmt.Errorf("failed to initialize stock cache")
+}
+
+// releaseStock 归还已扣减的库存并记录回滚的库存流水，用于创建订单失败时的回滚
+func (os *OrderService) releaseStock(ctx context.Context, orderNo string, items []commodityRepository.StockItem) {
+	movement := &commodityRepository.MovementInfo{Reason: commodityModel.MovementOrderRelease, OrderNo: orderNo, Operator: model.OperatorSystem}
+	if err := os.cRedisRepo.IncreaseStockBatch(ctx, items, movement); err != nil {
+		log.Errorf("Failed to release reserved stock %v: %v", items, err)
+	}
+}
+
+// mergeOrderItems 合并相同商品的订单行，保持商品首次出现的顺序
+func mergeOrderItems(items []model.OrderItem) []model.OrderItem {
+	merged := make([]model.OrderItem, 0, len(items))
+	index := make(map[int]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.CommodityId]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.CommodityId] = len(merged)
+		merged = append(merged, model.OrderItem{CommodityId: item.CommodityId, Quantity: item.Quantity})
+	}
+	return merged
+}
+
+// toItemAmounts 将订单行转换为优惠计算的商品小计
+func toItemAmounts(items []model.OrderItem) []promotionService.ItemAmount {
+	amounts := make([]promotionService.ItemAmount, 0, len(items))
+	for _, item := range items {
+		amounts = append(amounts, promotionService.ItemAmount{CommodityId: item.CommodityId, Amount: item.Amount})
+	}
+	return amounts
+}
+
+// toStockItems 将订单行转换为在指定仓库扣减/归还的库存条目
+// 参与秒杀的订单行带上秒杀活动和下单用户，归还库存时同时归还限购计数；扣减时由调用方补充限购条件
+func toStockItems(warehouseId int, userId int, items []model.OrderItem) []commodityRepository.StockItem {
+	stockItems := make([]commodityRepository.StockItem, 0, len(items))
+	for _, item := range items {
+		stockItem := commodityRepository.StockItem{CommodityId: item.CommodityId, WarehouseId: warehouseId, Quantity: item.Quantity}
+		if item.FlashSaleId != 0 {
+			stockItem.Limit = &commodityRepository.PurchaseLimit{FlashSaleId: item.FlashSaleId, UserId: userId}
+		}
+		stockItems = append(stockItems, stockItem)
+	}
+	return stockItems
+}
+
+// UpdateOrderStatus 按订单状态机更新用户自己订单的状态，并执行状态流转的副作用
+// 业务流程：
+// 1. 校验目标状态，买家只能取消订单（cancelled）或确认收货（completed）
+// 2. 查询属于该用户的订单（不属于该用户的订单视为不存在）
+// 3. 校验当前状态能否流转到目标状态（见model.OrderStatus.CanTransitionTo），即只能pending → cancelled、shipped → completed
+// 4. 以条件更新的方式写入新状态（当前状态被并发修改时视为非法流转）
+// 5. 执行状态流转的副作用：
+//   - pending → cancelled: 归还所有订单行的库存和优惠券次数，并撤销延迟取消任务
+//
+// 注意：
+// - 订单只能通过支付回调置为paid（见MarkOrderPaid），不能通过该方法直接修改
+// - 发货只能由管理员操作（见AdminUpdateOrderStatus）
+// - 退款相关状态只能通过退款审批写入（见ReserveRefund）
+func (os *OrderService) UpdateOrderStatus(userId int, orderNo string, status model.OrderStatus) error {
+	if !status.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidOrderStatus, status)
+	}
+	if err := checkManualTransition(status); err != nil {
+		return err
+	}
+	if status != model.StatusCancelled && status != model.StatusCompleted {
+		return fmt.Errorf("%w: buyer can only cancel an order or confirm receipt", ErrIllegalTransition)
+	}
+	order, err := os.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return err
 	}
+	return os.transitionOrder(order, status, model.OperatorUser(userId), "")
+}
+
+// AdminUpdateOrderStatus 管理员按订单状态机更新任意订单的履约状态
+// 管理员只能发货（paid → shipped）或代买家确认收货（shipped → completed），
+// 支付、取消和退款分别由支付回调、买家和退款审批写入
+// account为管理员账号，记录到订单事件中
+func (os *OrderService) AdminUpdateOrderStatus(account string, orderNo string, status model.OrderStatus) error {
+	if !status.IsValid() {
+		return fmt.Errorf("%w: %s", ErrInvalidOrderStatus, status)
+	}
+	if err := checkManualTransition(status); err != nil {
+		return err
+	}
+	if status != model.StatusShipped && status != model.StatusCompleted {
+		return fmt.Errorf("%w: admin can only ship an order or mark it completed", ErrIllegalTransition)
+	}
+	order, err := os.oRepo.FindOrderByOrderNo(orderNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrderNotFound
+		}
+		return err
+	}
+	return os.transitionOrder(order, status, model.OperatorAdmin(account), "")
+}
+
+// checkManualTransition 拒绝只能由支付回调或退款审批写入的目标状态
+func checkManualTransition(status model.OrderStatus) error {
+	switch status {
+	case model.StatusPaid:
+		return fmt.Errorf("%w: order can only be paid through payment", ErrIllegalTransition)
+	case model.StatusRefunded, model.StatusPartiallyRefunded:
+		return fmt.Errorf("%w: order can only be refunded through refund approval", ErrIllegalTransition)
+	}
+	return nil
+}
 
-	// 尝试从Redis扣减库存（使用Lua脚本保证原子性，防止超卖）
-	code, err := os.cRedisRepo.DecreaseStock(context.TODO(), commodityId, quantity)
-	switch code {
-	case 1: // 扣减失败（网络错误等）
+// MarkOrderPaid 支付成功后将订单置为已支付，由支付回调调用（系统操作，不校验订单归属）
+// 订单已是paid状态时直接返回（网关重复回调），否则按状态机流转并撤销延迟取消任务
+// 订单已被取消等无法流转到paid时返回ErrIllegalTransition
+// detail为记录在订单事件中的支付信息（如支付单号）
+func (os *OrderService) MarkOrderPaid(orderId int, detail string) error {
+	order, err := os.GetOrderById(orderId)
+	if err != nil {
 		return err
-	case 2: // Redis缓存未初始化
-		// 从MySQL查询商品库存
-		commodity, err := os.commodityRepo.FindCommodityById(commodityId)
+	}
+	if order.Status == model.StatusPaid {
+		return nil
+	}
+	return os.transitionOrder(order, model.StatusPaid, model.OperatorSystem, detail)
+}
+
+// RefundQuantities 校验退款数量并转换为订单行ID到退款数量的映射
+// quantities为商品ID到退款数量的映射，为空时表示退还所有未退款的商品
+// 商品不在订单中或退款数量超过可退数量（购买数量-已退款数量）时返回ErrInvalidRefundItems
+func RefundQuantities(order *model.Order, quantities map[int]int) (map[int]int, error) {
+	refundable := make(map[int]model.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		refundable[item.CommodityId] = item
+	}
+
+	result := make(map[int]int)
+	if len(quantities) == 0 {
+		for _, item := range order.Items {
+			if remaining := item.Quantity - item.RefundedQuantity; remaining > 0 {
+				result[item.Id] = remaining
+			}
+		}
+	}
+	for commodityId, quantity := range quantities {
+		item, ok := refundable[commodityId]
+		if !ok {
+			return nil, fmt.Errorf("%w: commodity %d not in order", ErrInvalidRefundItems, commodityId)
+		}
+		if quantity <= 0 || quantity > item.Quantity-item.RefundedQuantity {
+			return nil, fmt.Errorf("%w: commodity %d refundable quantity is %d", ErrInvalidRefundItems, commodityId, item.Quantity-item.RefundedQuantity)
+		}
+		result[item.Id] = quantity
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: nothing to refund", ErrInvalidRefundItems)
+	}
+	return result, nil
+}
+
+// RefundReservation 已写入订单、尚未完成网关退款的退款，由ReserveRefund返回
+// 网关退款成功后调用ConfirmRefund记录事件并归还库存，网关退款失败时调用ReleaseRefund撤销
+type RefundReservation struct {
+	OrderId    int
+	OrderNo    string
+	From       model.OrderStatus // 预留前的订单状态
+	To         model.OrderStatus // 预留后的订单状态（refunded或partially_refunded）
+	Quantities map[int]int       // 订单行ID到退款数量的映射
+	stockItems []commodityRepository.StockItem
+}
+
+// ReserveRefund 在调用网关退款之前预留订单侧的退款（系统操作，不校验订单归属）
+// 业务流程：
+// 1. 校验本次退款数量不超过各订单行的可退数量
+// 2. 所有订单行都已全部退款时订单置为refunded，否则置为partially_refunded
+// 3. 在同一事务中累加订单行的已退款数量并以条件更新写入订单状态
+//
+// 预留成功后，同一订单行的可退数量已被占用，重复或并发的退款会在这一步失败，不会再调用网关
+// 库存和订单事件要等网关退款成功后由ConfirmRefund处理
+//
+// 参数说明：
+// - quantities: 订单行ID到退款数量的映射
+func (os *OrderService) ReserveRefund(orderId int, quantities map[int]int) (*RefundReservation, error) {
+	order, err := os.GetOrderById(orderId)
+	if err != nil {
+		return nil, err
+	}
+
+	status := model.StatusRefunded
+	stockItems := make([]commodityRepository.StockItem, 0, len(quantities))
+	for _, item := range order.Items {
+		quantity := quantities[item.Id]
+		if quantity < 0 || item.RefundedQuantity+quantity > item.Quantity {
+			return nil, fmt.Errorf("%w: order item %d", ErrInvalidRefundItems, item.Id)
+		}
+		if item.RefundedQuantity+quantity < item.Quantity {
+			status = model.StatusPartiallyRefunded
+		}
+		if quantity > 0 {
+			stockItem := commodityRepository.StockItem{CommodityId: item.CommodityId, WarehouseId: order.WarehouseId, Quantity: quantity}
+			if item.FlashSaleId != 0 {
+				// 秒杀商品退款时同时归还活动配额和买家的限购计数
+				stockItem.Limit = &commodityRepository.PurchaseLimit{FlashSaleId: item.FlashSaleId, UserId: order.UserId}
+			}
+			stockItems = append(stockItems, stockItem)
+		}
+	}
+	if len(stockItems) != len(quantities) {
+		return nil, fmt.Errorf("%w: order items not in order %d", ErrInvalidRefundItems, orderId)
+	}
+	if !order.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, order.Status, status)
+	}
+
+	if err = os.oRepo.ApplyRefund(orderId, order.Status, status, quantities); err != nil {
+		if errors.Is(err, repository.ErrOrderStatusConflict) {
+			return nil, fmt.Errorf("%w: %v", ErrIllegalTransition, err)
+		}
+		return nil, err
+	}
+	log.Infof("Order %d status changed: %s -> %s (refund reserved)", orderId, order.Status, status)
+	return &RefundReservation{
+		OrderId:    orderId,
+		OrderNo:    order.OrderNo,
+		From:       order.Status,
+		To:         status,
+		Quantities: quantities,
+		stockItems: stockItems,
+	}, nil
+}
+
+// ConfirmRefund 网关退款成功后完成预留的退款：记录退款事件，将退款的商品数量归还到库存
+// operator为批准退款的操作人，note为记录在事件中的退款说明
+func (os *OrderService) ConfirmRefund(reservation *RefundReservation, operator string, note string) {
+	recordOrderEvent(os.eRepo, &model.OrderEvent{
+		OrderId:    reservation.OrderId,
+		Type:       model.EventRefunded,
+		FromStatus: reservation.From,
+		ToStatus:   reservation.To,
+		Operator:   operator,
+		Detail:     note,
+	})
+	os.restoreRefundedStock(context.TODO(), reservation.OrderNo, operator, reservation.stockItems)
+}
+
+// ReleaseRefund 网关退款失败时撤销预留的退款：扣减订单行的已退款数量，订单恢复为预留前的状态
+func (os *OrderService) ReleaseRefund(reservation *RefundReservation) error {
+	err := os.oRepo.RevertRefund(reservation.OrderId, reservation.To, reservation.From, reservation.Quantities)
+	if err != nil {
+		return err
+	}
+	log.Infof("Order %d status changed: %s -> %s (refund released)", reservation.OrderId, reservation.To, reservation.From)
+	return nil
+}
+
+// restoreRefundedStock 将退款商品的数量逐个归还到Redis库存，缓存未加载的商品先从MySQL加载后再归还
+// 秒杀商品（带Limit的条目）通过IncreaseStockBatch同时归还活动配额和买家的限购计数
+// 归还与退款的库存流水在同一原子操作中记录；归还失败只记录日志，不影响已完成的退款
+func (os *OrderService) restoreRefundedStock(ctx context.Context, orderNo string, operator string, items []commodityRepository.StockItem) {
+	movement := &commodityRepository.MovementInfo{Reason: commodityModel.MovementRefund, OrderNo: orderNo, Operator: operator}
+	for _, item := range items {
+		err := os.cRedisRepo.IncreaseStockBatch(ctx, []commodityRepository.StockItem{item}, movement)
 		if err != nil {
-			return err
+			// 缓存可能尚未加载，从MySQL加载后重试一次
+			if err = os.stockCacheSvc.LoadStockCache(ctx, item.CommodityId, item.WarehouseId); err == nil {
+				err = os.cRedisRepo.IncreaseStockBatch(ctx, []commodityRepository.StockItem{item}, movement)
+			}
 		}
-		// 初始化Redis库存缓存
-		err = os.cRedisRepo.InitStockCache(context.TODO(), commodityId, commodity.Stock)
 		if err != nil {
-			return err
+			log.Errorf("Failed to restore refunded stock of commodity %d in warehouse %d (quantity %d): %v", item.CommodityId, item.WarehouseId, item.Quantity, err)
 		}
-		// ⚠️ BUG: 这里应该重新调用DecreaseStock扣减库存，否则库存未扣减就创建了订单
-	case 3: // 库存不足
-		// ⚠️ BUG: 这里应该return错误，否则库存不足也会创建订单
 	}
+}
 
-	// 创建订单记录
-	err = os.oRepo.CreateOrder(order)
+// transitionOrder 校验并执行订单状态流转，成功后记录事件并执行流转的副作用
+func (os *OrderService) transitionOrder(order *model.Order, status model.OrderStatus, operator string, detail string) error {
+	if !order.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, order.Status, status)
+	}
+
+	var err error
+	if status == model.StatusCancelled {
+		err = os.oRepo.CancelOrder(order.Id, order.Status, model.CancelReasonManual)
+	} else {
+		err = os.oRepo.UpdateOrderStatus(order.Id, order.Status, status)
+	}
 	if err != nil {
+		if errors.Is(err, repository.ErrOrderStatusConflict) {
+			return fmt.Errorf("%w: %v", ErrIllegalTransition, err)
+		}
 		return err
 	}
+	from := order.Status
+	order.Status = status
+
+	event := &model.OrderEvent{
+		OrderId:    order.Id,
+		Type:       model.EventStatusChanged,
+		FromStatus: from,
+		ToStatus:   status,
+		Operator:   operator,
+		Detail:     detail,
+	}
+	if status == model.StatusCancelled {
+		event.Type = model.EventCancelled
+		event.Detail = model.CancelReasonManual
+	}
+	recordOrderEvent(os.eRepo, event)
 
-	// 将订单加入延迟取消队列（15分钟后如果还是pending状态，会自动取消并归还库存）
-	err = os.orderCancelService.createOrderTask(order.Id, commodityId, quantity)
+	os.onStatusChanged(order, from, operator)
+	return nil
+}
+
+// CancelOrder 买家主动取消待支付订单
+// 业务流程：
+// 1. 查询属于该用户的订单（不属于该用户的订单视为不存在）
+// 2. 校验订单状态，只有pending（待支付）订单可以取消
+// 3. 以条件更新将订单置为cancelled，记录取消原因为user_cancelled（与超时取消/支付并发时只有一方能成功）
+// 4. 归还所有订单行的库存（与超时取消共用幂等性键，不会重复归还）和订单使用的优惠券次数
+// 5. 从延迟队列中移除任务及其payload
+func (os *OrderService) CancelOrder(userId int, orderNo string) error {
+	order, err := os.GetUserOrder(userId, orderNo)
 	if err != nil {
 		return err
 	}
+	if !order.Status.CanTransitionTo(model.StatusCancelled) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, order.Status, model.StatusCancelled)
+	}
 
+	if err = os.oRepo.CancelOrder(order.Id, order.Status, model.CancelReasonUserCancelled); err != nil {
+		if errors.Is(err, repository.ErrOrderStatusConflict) {
+			return fmt.Errorf("%w: %v", ErrIllegalTransition, err)
+		}
+		return err
+	}
+	from := order.Status
+	order.Status = model.StatusCancelled
+
+	recordOrderEvent(os.eRepo, &model.OrderEvent{
+		OrderId:    order.Id,
+		Type:       model.EventCancelled,
+		FromStatus: from,
+		ToStatus:   model.StatusCancelled,
+		Operator:   model.OperatorUser(userId),
+		Detail:     model.CancelReasonUserCancelled,
+	})
+
+	os.onStatusChanged(order, from, model.OperatorUser(userId))
 	return nil
 }
 
-// UpdateOrderStatus 更新订单状态
-func (os *OrderService) UpdateOrderStatus(id int, status string) error {
-	order := &model.Order{Status: status, Id: id}
-	return os.oRepo.UpdateOrder(order)
+// onStatusChanged 执行订单状态流转的副作用，operator为触发流转的操作人，记录到库存流水中
+// 副作用失败不会回滚已写入的状态，只记录日志：
+// - 撤销任务失败：超时任务到期后会发现订单已不是pending状态，不会再归还库存
+// - 归还库存失败：延迟任务保留在队列中，到期后由超时取消流程按幂等性键补偿归还
+func (os *OrderService) onStatusChanged(order *model.Order, from model.OrderStatus, operator string) {
+	switch order.Status {
+	case model.StatusPaid:
+		if err := os.orderCancelService.cancelOrderTask(order.OrderNo); err != nil {
+			log.Errorf("Failed to cancel timeout task of paid order %d: %v", order.Id, err)
+		}
+	case model.StatusCancelled:
+		if order.CouponId != 0 {
+			os.orderCancelService.releaseOrderCoupon(order.Id)
+		}
+		if _, err := os.orderCancelService.restoreOrderStock(order.OrderNo, toStockItems(order.WarehouseId, order.UserId, order.Items), commodityModel.MovementOrderCancel, operator); err != nil {
+			log.Errorf("Failed to restore stock of cancelled order %d: %v", order.Id, err)
+			return
+		}
+		if err := os.orderCancelService.cancelOrderTask(order.OrderNo); err != nil {
+			log.Errorf("Failed to cancel timeout task of cancelled order %d: %v", order.Id, err)
+		}
+	}
+	log.Infof("Order %d status changed: %s -> %s", order.Id, from, order.Status)
 }
 
-// UpdateOrderAddress 更新订单地址
-func (os *OrderService) UpdateOrderAddress(id int, address string) error {
-	order := &model.Order{Address: address, Id: id}
-	return os.oRepo.UpdateOrder(order)
+// UpdateOrderAddress 更新用户自己订单的收货地址，并记录新旧地址
+// 只有待支付和已支付（未发货）的订单可以修改地址，其他状态或修改期间订单状态发生变化时返回ErrAddressNotEditable
+func (os *OrderService) UpdateOrderAddress(userId int, orderNo string, address string) error {
+	order, err := os.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return err
+	}
+	if !order.Status.IsAddressEditable() {
+		return fmt.Errorf("%w: order %s is %s", ErrAddressNotEditable, orderNo, order.Status)
+	}
+	err = os.oRepo.UpdateOrderAddress(order.Id, order.Status, address)
+	if errors.Is(err, repository.ErrOrderStatusConflict) {
+		return fmt.Errorf("%w: order %s status changed", ErrAddressNotEditable, orderNo)
+	}
+	if err != nil {
+		return err
+	}
+
+	recordOrderEvent(os.eRepo, &model.OrderEvent{
+		OrderId:    order.Id,
+		Type:       model.EventAddressChanged,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		Operator:   model.OperatorUser(userId),
+		Detail:     fmt.Sprintf("%q -> %q", order.Address, address),
+	})
+	return nil
 }
 
-// DeleteOrder 删除订单
-func (os *OrderService) DeleteOrder(orderId int) error {
-	return os.oRepo.DeleteOrder(orderId)
+// DeleteOrder 删除用户自己的订单（软删除）
+// 只有已结束的订单（已完成、已取消、已全额退款）可以删除，删除后对买家隐藏，
+// 但订单、订单行和事件历史都会保留，直到超过保留期后被归档
+func (os *OrderService) DeleteOrder(userId int, orderNo string) error {
+	order, err := os.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return err
+	}
+	if !order.Status.IsTerminal() {
+		return fmt.Errorf("%w: order %s is %s", ErrOrderNotDeletable, orderNo, order.Status)
+	}
+	if err = os.oRepo.DeleteOrder(order.Id); err != nil {
+		return err
+	}
+
+	recordOrderEvent(os.eRepo, &model.OrderEvent{
+		OrderId:    order.Id,
+		Type:       model.EventDeleted,
+		FromStatus: order.Status,
+		ToStatus:   order.Status,
+		Operator:   model.OperatorUser(userId),
+	})
+	return nil
 }
 
-// GetOrderById 根据订单ID获取订单
+// GetOrderById 根据订单ID获取订单，订单不存在时返回ErrOrderNotFound
 func (os *OrderService) GetOrderById(orderId int) (*model.Order, error) {
-	return os.oRepo.FindOrderById(orderId)
+	order, err := os.oRepo.FindOrderById(orderId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
+}
+
+// GetUserOrder 根据订单号获取属于指定用户的订单，订单不存在或不属于该用户时都返回ErrOrderNotFound
+func (os *OrderService) GetUserOrder(userId int, orderNo string) (*model.Order, error) {
+	order, err := os.oRepo.FindOrderByOrderNoAndUserId(orderNo, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	return order, err
 }
 
-// GetOrdersByUserId 根据用户ID获取该用户的所有订单
-func (os *OrderService) GetOrdersByUserId(userId int) ([]*model.Order, error) {
-	return os.oRepo.FindOrdersByUserId(userId)
+// GetOrdersByUserId 根据用户ID和查询条件分页获取该用户的订单，同时返回满足条件的订单总数
+func (os *OrderService) GetOrdersByUserId(userId int, query repository.OrderQuery) ([]*model.Order, int64, error) {
+	if query.Status != "" && !query.Status.IsValid() {
+		return nil, 0, fmt.Errorf("%w: %s", ErrInvalidOrderStatus, query.Status)
+	}
+	return os.oRepo.FindOrdersByUserId(userId, query)
 }
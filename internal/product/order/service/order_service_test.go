@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	commodityModel "server/internal/product/commodity/model"
+	commodityRepository "server/internal/product/commodity/repository"
+	commodityService "server/internal/product/commodity/service"
+	"server/internal/product/order/model"
+	"server/internal/product/order/repository"
+	promotionModel "server/internal/product/promotion/model"
+	promotionRepository "server/internal/product/promotion/repository"
+	promotionService "server/internal/product/promotion/service"
+	"server/pkg/idgen"
+	"server/pkg/money"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDQRepository 加入延迟队列总是失败的延迟队列仓储，用于测试下单失败的回滚
+type failingDQRepository struct {
+	repository.OrderDQRepository
+}
+
+func (failingDQRepository) EnqueueDelayTask(ctx context.Context, id, payload string, execTime time.Duration) error {
+	return errors.New("delay queue unavailable")
+}
+
+// orderFixture memory存储模式下的订单服务及其依赖的仓储
+type orderFixture struct {
+	svc        *OrderService
+	dqRepo     repository.OrderDQRepository
+	stockRepo  commodityRepository.StockCacheRepository
+	movements  commodityRepository.StockMovementRepository
+	commodity  commodityRepository.CommodityRepository
+	couponSvc  *promotionService.CouponService
+	commodity1 int // 单价12.50，默认仓库库存10
+	commodity2 int // 单价3.00，默认仓库库存2
+}
+
+// newOrderFixture 以memory存储模式的仓储构建订单服务，创建两个商品和券码SAVE10（减免10.00，每人限用一次）
+// dqRepo不为nil时替换延迟队列仓储
+func newOrderFixture(t *testing.T, dqRepo repository.OrderDQRepository) *orderFixture {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Order.PaymentTimeoutMinutes = 15
+	cfg.Warehouse.AllocationStrategy = commodityService.AllocationPriority
+	cfg.DelayQueue.ProcessingTimeoutSeconds = 300
+	cfg.DelayQueue.BatchSize = 100
+
+	commodityStore := commodityRepository.NewMemoryStore()
+	commodityRepo := commodityRepository.NewMemoryCommodityRepository(commodityStore)
+	warehouseRepo := commodityRepository.NewMemoryWarehouseRepository(commodityStore)
+	stockRepo := commodityRepository.NewMemoryStockRepository(commodityStore)
+	stockCacheSvc := commodityService.NewStockCacheService(stockRepo, commodityRepo, warehouseRepo)
+	warehouseSvc := commodityService.NewWarehouseService(warehouseRepo, stockCacheSvc, cfg)
+
+	couponSvc := promotionService.NewCouponService(promotionRepository.NewMemoryCouponRepository(),
+		promotionRepository.NewMemoryCouponUsageRepository(), promotionRepository.NewMemoryCouponCounterRepository())
+	flashSaleSvc := promotionService.NewFlashSaleService(promotionRepository.NewMemoryFlashSaleRepository())
+
+	orderStore := repository.NewMemoryStore()
+	oRepo := repository.NewMemoryOrderRepository(orderStore)
+	eRepo := repository.NewMemoryOrderEventRepository(orderStore)
+	if dqRepo == nil {
+		dqRepo = repository.NewMemoryOrderDQRepository(cfg)
+	}
+	cancelSvc := NewOrderCancelService(dqRepo, oRepo, eRepo, stockRepo, couponSvc, cfg)
+	idGen, err := idgen.NewGenerator(0)
+	require.NoError(t, err)
+
+	f := &orderFixture{
+		svc: NewOrderService(oRepo, eRepo, repository.NewMemoryOrderArchiveRepository(orderStore), stockRepo, commodityRepo,
+			stockCacheSvc, warehouseSvc, couponSvc, flashSaleSvc, cancelSvc, idGen, cfg),
+		dqRepo:    dqRepo,
+		stockRepo: stockRepo,
+		movements: commodityRepository.NewMemoryStockMovementRepository(commodityStore),
+		commodity: commodityRepo,
+		couponSvc: couponSvc,
+	}
+	f.commodity1 = f.createCommodity(t, 12.5, 10)
+	f.commodity2 = f.createCommodity(t, 3, 2)
+
+	now := time.Now()
+	require.NoError(t, couponSvc.CreateCoupon(&promotionModel.Coupon{
+		Code:         "SAVE10",
+		Type:         promotionModel.CouponTypeFixed,
+		AmountOff:    1000,
+		PerUserLimit: 1,
+		StartsAt:     now.Add(-time.Hour),
+		EndsAt:       now.Add(time.Hour),
+	}))
+	return f
+}
+
+func (f *orderFixture) createCommodity(t *testing.T, price float64, stock int) int {
+	t.Helper()
+	commodity := &commodityModel.Commodity{Name: "commodity", Price: price, Status: true}
+	require.NoError(t, f.commodity.CreateCommodity(commodity))
+	code, _, err := f.stockRepo.AdjustStock(context.Background(), commodity.ID, commodityModel.DefaultWarehouseId, stock, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+	return commodity.ID
+}
+
+// stock 返回商品的总库存
+func (f *orderFixture) stock(t *testing.T, commodityId int) int {
+	t.Helper()
+	commodity, err := f.commodity.FindCommodityById(commodityId)
+	require.NoError(t, err)
+	return commodity.Stock
+}
+
+// movementReasons 返回商品的库存流水原因，按发生顺序
+func (f *orderFixture) movementReasons(t *testing.T, commodityId int) []commodityModel.StockMovementReason {
+	t.Helper()
+	movements, _, err := f.movements.FindMovements(commodityRepository.StockMovementQuery{CommodityId: commodityId})
+	require.NoError(t, err)
+	reasons := make([]commodityModel.StockMovementReason, 0, len(movements))
+	for i := len(movements) - 1; i >= 0; i-- {
+		reasons = append(reasons, movements[i].Reason)
+	}
+	return reasons
+}
+
+func (f *orderFixture) userOrders(t *testing.T, userId int) []*model.Order {
+	t.Helper()
+	orders, _, err := f.svc.GetOrdersByUserId(userId, repository.OrderQuery{})
+	require.NoError(t, err)
+	return orders
+}
+
+func TestCreateOrder(t *testing.T) {
+	f := newOrderFixture(t, nil)
+	items := []model.OrderItem{
+		{CommodityId: f.commodity1, Quantity: 2},
+		{CommodityId: f.commodity2, Quantity: 1},
+		{CommodityId: f.commodity1, Quantity: 1},
+	}
+	order, err := f.svc.CreateOrder(7, items, "SAVE10", "30.50", "address")
+	require.NoError(t, err)
+
+	assert.Equal(t, model.StatusPending, order.Status)
+	assert.Equal(t, money.Amount(4050), order.TotalAmount)
+	assert.Equal(t, money.Amount(1000), order.DiscountAmount)
+	assert.Equal(t, money.Amount(3050), order.PayAmount)
+	require.Len(t, order.Items, 2, "rows of the same commodity are merged")
+	assert.Equal(t, 3, order.Items[0].Quantity)
+	assert.Equal(t, order.DiscountAmount, order.Items[0].DiscountAmount+order.Items[1].DiscountAmount)
+
+	assert.Equal(t, 7, f.stock(t, f.commodity1))
+	assert.Equal(t, 1, f.stock(t, f.commodity2))
+	assert.Equal(t, []commodityModel.StockMovementReason{commodityModel.MovementOrderReserve}, f.movementReasons(t, f.commodity1))
+
+	_, err = f.dqRepo.GetTaskTime(context.Background(), order.OrderNo)
+	assert.NoError(t, err, "the order is queued for cancellation after the payment timeout")
+	assert.Len(t, f.userOrders(t, 7), 1)
+}
+
+func TestCreateOrderRollback(t *testing.T) {
+	tests := []struct {
+		name          string
+		dqRepo        repository.OrderDQRepository
+		items         func(f *orderFixture) []model.OrderItem
+		expectedTotal string
+		wantErr       error
+		wantReasons   []commodityModel.StockMovementReason // 商品1的库存流水
+	}{
+		{
+			name: "insufficient stock of one item deducts nothing",
+			items: func(f *orderFixture) []model.OrderItem {
+				return []model.OrderItem{{CommodityId: f.commodity1, Quantity: 1}, {CommodityId: f.commodity2, Quantity: 3}}
+			},
+			wantErr:     ErrInsufficientStock,
+			wantReasons: []commodityModel.StockMovementReason{},
+		},
+		{
+			name: "price mismatch",
+			items: func(f *orderFixture) []model.OrderItem {
+				return []model.OrderItem{{CommodityId: f.commodity1, Quantity: 1}}
+			},
+			expectedTotal: "12.50",
+			wantErr:       ErrPriceMismatch,
+			wantReasons:   []commodityModel.StockMovementReason{},
+		},
+		{
+			name:   "delay queue failure releases the reserved stock",
+			dqRepo: failingDQRepository{},
+			items: func(f *orderFixture) []model.OrderItem {
+				return []model.OrderItem{{CommodityId: f.commodity1, Quantity: 1}, {CommodityId: f.commodity2, Quantity: 2}}
+			},
+			wantReasons: []commodityModel.StockMovementReason{commodityModel.MovementOrderReserve, commodityModel.MovementOrderRelease},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOrderFixture(t, tt.dqRepo)
+			_, err := f.svc.CreateOrder(7, tt.items(f), "SAVE10", tt.expectedTotal, "address")
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+
+			assert.Equal(t, 10, f.stock(t, f.commodity1))
+			assert.Equal(t, 2, f.stock(t, f.commodity2))
+			assert.Equal(t, tt.wantReasons, f.movementReasons(t, f.commodity1))
+			assert.Empty(t, f.userOrders(t, 7), "no order is left behind")
+
+			// 优惠券每人限用一次，回滚后归还了占用的次数才能再次使用
+			discount, err := f.couponSvc.ReserveCoupon(7, "SAVE10",
+				[]promotionService.ItemAmount{{CommodityId: f.commodity1, Amount: 1250}})
+			require.NoError(t, err, "the coupon usage is released")
+			assert.Equal(t, money.Amount(1000), discount.Amount)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package dto
+
+// RefundItemRequest 退款行请求
+type RefundItemRequest struct {
+	CommodityId int `json:"commodity_id" binding:"required"`
+	Quantity    int `json:"quantity" binding:"required,min=1"`
+}
+
+// RefundRequest 申请退款请求
+type RefundRequest struct {
+	Items  []RefundItemRequest `json:"items" binding:"omitempty,dive"` // 可选，退货的商品和数量，为空时全额退款
+	Reason string              `json:"reason" binding:"required,max=255"`
+}
+
+// ReviewRefundRequest 审批退款请求
+type ReviewRefundRequest struct {
+	Note string `json:"note" binding:"max=255"` // 审批备注
+}
+
+// ListRefundRequest 查询退款单列表请求（Query参数）
+type ListRefundRequest struct {
+	Status   string `form:"status"`                                      // 退款单状态过滤，为空时返回全部状态
+	Page     int    `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "server/internal/product/payment/model"
+
+// PaymentResponse 发起支付响应
+type PaymentResponse struct {
+	Payment *model.Payment `json:"payment"`
+	PayURL  string         `json:"pay_url"` // 买家完成支付的地址
+}
+
+// RefundResponse 退款单响应
+type RefundResponse struct {
+	Refund *model.Refund `json:"refund"`
+}
+
+// RefundListResponse 退款单列表响应
+type RefundListResponse struct {
+	Refunds []*model.Refund `json:"refunds"`
+}
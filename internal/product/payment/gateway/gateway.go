@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"fmt"
+	"server/config"
+	"server/internal/product/payment/model"
+	"server/pkg/money"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Intent 网关为支付单创建的支付意图，买家通过PayURL完成支付
+type Intent struct {
+	TradeNo string // 网关交易号
+	PayURL  string // 买家完成支付的地址
+}
+
+// Notification 网关回调通知的内容（已通过签名校验）
+type Notification struct {
+	TradeNo string       `json:"trade_no"` // 网关交易号
+	Status  string       `json:"status"`   // 支付结果，见NotifyStatus*常量
+	Amount  money.Amount `json:"amount"`   // 实际支付金额（分）
+}
+
+// 回调通知中的支付结果
+const (
+	NotifyStatusSuccess = "success"
+	NotifyStatusFailed  = "failed"
+)
+
+// PaymentGateway 支付网关接口，不同的支付渠道实现该接口即可接入
+type PaymentGateway interface {
+	// Name 返回网关名称，记录在支付单上
+	Name() string
+	// CreatePayment 在网关创建支付意图
+	CreatePayment(payment *model.Payment) (*Intent, error)
+	// VerifyCallback 校验回调签名并解析通知内容，签名无效时返回ErrInvalidSignature
+	VerifyCallback(body []byte, signature string) (*Notification, error)
+	// Refund 将退款单的金额原路退回到原支付单，返回网关退款单号
+	Refund(payment *model.Payment, refund *model.Refund) (string, error)
+}
+
+// NewPaymentGateway 根据配置创建支付网关，未配置时使用本地模拟网关
+// 签名密钥为空时任何人都能伪造支付成功回调，因此返回错误，除非显式开启payment.allowInsecureMock（仅用于本地开发）
+func NewPaymentGateway(cfg *config.Config) (PaymentGateway, error) {
+	switch cfg.Payment.Gateway {
+	case "", MockGatewayName:
+		if cfg.Payment.Secret == "" {
+			if !cfg.Payment.AllowInsecureMock {
+				return nil, fmt.Errorf("支付回调签名密钥未配置: payment.secret")
+			}
+			log.Warn("payment secret is empty and allowInsecureMock is enabled, callbacks of mock gateway can be forged")
+		}
+		return NewMockGateway(cfg.Payment.Secret), nil
+	default:
+		return nil, fmt.Errorf("不支持的支付网关: %s", cfg.Payment.Gateway)
+	}
+}
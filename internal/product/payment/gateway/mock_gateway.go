@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"server/internal/product/payment/model"
+	"time"
+)
+
+// MockGatewayName 本地模拟网关的名称
+const MockGatewayName = "mock"
+
+// mockGateway 本地开发用的模拟支付网关
+// 不会真正扣款，开发者使用Sign对回调内容签名后调用回调接口即可模拟支付结果
+type mockGateway struct {
+	secret string
+}
+
+// NewMockGateway 创建一个本地模拟支付网关，secret用于回调的HMAC-SHA256签名
+func NewMockGateway(secret string) PaymentGateway {
+	return &mockGateway{secret: secret}
+}
+
+// Name 返回网关名称
+func (g *mockGateway) Name() string {
+	return MockGatewayName
+}
+
+// CreatePayment 生成模拟交易号，不访问任何外部服务
+func (g *mockGateway) CreatePayment(payment *model.Payment) (*Intent, error) {
+	tradeNo := fmt.Sprintf("mock_%d_%d", payment.Id, time.Now().UnixNano())
+	return &Intent{
+		TradeNo: tradeNo,
+		PayURL:  "/mock-pay/" + tradeNo,
+	}, nil
+}
+
+// Refund 生成模拟退款单号，不访问任何外部服务
+func (g *mockGateway) Refund(payment *model.Payment, refund *model.Refund) (string, error) {
+	if refund.Amount > payment.Amount {
+		return "", fmt.Errorf("refund amount %s exceeds payment amount %s", refund.Amount, payment.Amount)
+	}
+	return fmt.Sprintf("mock_refund_%d_%d", refund.Id, time.Now().UnixNano()), nil
+}
+
+// VerifyCallback 校验回调体的HMAC-SHA256签名（十六进制）并解析通知内容
+func (g *mockGateway) VerifyCallback(body []byte, signature string) (*Notification, error) {
+	if !hmac.Equal([]byte(signature), []byte(Sign(g.secret, body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	if notification.TradeNo == "" {
+		return nil, fmt.Errorf("%w: missing trade_no", ErrInvalidNotification)
+	}
+	return &notification, nil
+}
+
+// Sign 使用secret对回调内容签名，返回十六进制签名，供本地开发模拟网关回调使用
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
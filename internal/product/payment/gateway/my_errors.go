@@ -0,0 +1,11 @@
+package gateway
+
+import "errors"
+
+var (
+	// ErrInvalidSignature 回调签名校验失败
+	ErrInvalidSignature = errors.New("invalid callback signature")
+
+	// ErrInvalidNotification 回调内容无法解析或缺少必要字段
+	ErrInvalidNotification = errors.New("invalid callback notification")
+)
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	orderService "server/internal/product/order/service"
+	"server/internal/product/payment/dto"
+	"server/internal/product/payment/gateway"
+	"server/internal/product/payment/service"
+	"server/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// SignatureHeader 支付网关回调携带签名的请求头
+const SignatureHeader = "X-Signature"
+
+// PaymentHandler 处理支付相关的HTTP请求
+type PaymentHandler struct {
+	pSvc *service.PaymentService
+}
+
+// NewPaymentHandler 创建一个新的支付处理器实例
+func NewPaymentHandler(pSvc *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{pSvc: pSvc}
+}
+
+// PayOrder 处理发起订单支付请求
+// 业务流程：
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
+// 2. 调用Service层创建支付单并在支付网关创建支付意图
+// 3. 返回支付单和支付地址
+//
+// 注意：
+// - 只能支付自己的待支付订单，订单不存在或不属于当前用户返回404
+// - 订单状态在网关回调确认支付成功后才会变为paid
+// - 订单已有等待支付的支付单时返回该支付单和原支付地址，不会重复创建
+func (h *PaymentHandler) PayOrder(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	// 从URL路径参数中获取订单号（如：/order/1234567890123456789/pay）
+	orderNo := c.Param("order_no")
+
+	payment, intent, err := h.pSvc.CreatePayment(uid, orderNo)
+	if err != nil {
+		switch {
+		case errors.Is(err, orderService.ErrOrderNotFound):
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+		case errors.Is(err, service.ErrOrderNotPayable):
+			response.BadRequest(c, response.CodeOrderNotPayable, err.Error())
+		default:
+			response.InternalServerError(c, response.CodePaymentCreateFailed, "server busy")
+		}
+		return
+	}
+
+	response.Success(c, dto.PaymentResponse{Payment: payment, PayURL: intent.PayURL})
+	log.Info("payment create success, orderNo:", orderNo, " paymentID:", payment.Id)
+}
+
+// PaymentCallback 处理支付网关的支付结果回调（公开接口，通过签名认证）
+// 业务流程：
+// 1. 读取原始请求体和X-Signature请求头
+// 2. 调用Service层校验签名并处理支付结果（支付成功时订单置为paid并撤销延迟取消任务）
+// 3. 返回处理结果，非2xx响应会使网关稍后重试回调
+//
+// 注意：
+// - 签名无效返回401，不做任何处理
+// - 重复回调是幂等的，返回成功
+// - 支付成功但订单已关闭（如已超时取消）或已由其他支付单支付时返回409，支付单置为refund_required，需要人工退款
+func (h *PaymentHandler) PaymentCallback(c *gin.Context) {
+	// 签名是对原始请求体计算的，不能先反序列化再序列化
+	body, err := c.GetRawData()
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid body")
+		return
+	}
+
+	err = h.pSvc.HandleCallback(body, c.GetHeader(SignatureHeader))
+	if err != nil {
+		switch {
+		case errors.Is(err, gateway.ErrInvalidSignature):
+			response.Unauthorized(c, response.CodePaymentSignatureInvalid, err.Error())
+		case errors.Is(err, gateway.ErrInvalidNotification):
+			response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		case errors.Is(err, service.ErrPaymentNotFound):
+			response.NotFound(c, response.CodePaymentNotFound, err.Error())
+		case errors.Is(err, service.ErrPaymentAmountMismatch):
+			response.BadRequest(c, response.CodePaymentAmountMismatch, err.Error())
+		case errors.Is(err, service.ErrOrderClosed):
+			response.Conflict(c, response.CodePaymentOrderClosed, err.Error())
+		case errors.Is(err, service.ErrOrderAlreadyPaid):
+			response.Conflict(c, response.CodePaymentDuplicated, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		log.Warn("payment callback rejected:", err)
+		return
+	}
+
+	response.Success(c, nil)
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"errors"
+	orderService "server/internal/product/order/service"
+	"server/internal/product/payment/dto"
+	"server/internal/product/payment/model"
+	"server/internal/product/payment/service"
+	"server/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// RefundHandler 处理退款申请和审批相关的HTTP请求
+type RefundHandler struct {
+	rSvc *service.RefundService
+}
+
+// NewRefundHandler 创建一个新的退款处理器实例
+func NewRefundHandler(rSvc *service.RefundService) *RefundHandler {
+	return &RefundHandler{rSvc: rSvc}
+}
+
+// RequestRefund 处理买家申请退款请求
+// 业务流程：
+// 1. 从JWT中间件获取已认证的用户ID，从URL路径中提取订单号
+// 2. 解析请求体（退货商品和数量、退款原因），不提供商品时全额退款
+// 3. 调用Service层创建待审批的退款单
+//
+// 注意：
+// - 只能为自己已支付且未全额退款的订单申请，同一订单同时只能有一个处理中的退款
+// - 退款需要管理员审批后才会退回金额、更新订单状态并归还库存
+func (h *RefundHandler) RequestRefund(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	orderNo := c.Param("order_no")
+
+	var req dto.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, "invalid JSON")
+		return
+	}
+	quantities := make(map[int]int, len(req.Items))
+	for _, item := range req.Items {
+		quantities[item.CommodityId] += item.Quantity
+	}
+
+	refund, err := h.rSvc.RequestRefund(uid, orderNo, quantities, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, orderService.ErrOrderNotFound):
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+		case errors.Is(err, service.ErrOrderNotRefundable):
+			response.BadRequest(c, response.CodeOrderNotRefundable, err.Error())
+		case errors.Is(err, service.ErrRefundInProgress):
+			response.Conflict(c, response.CodeRefundInProgress, err.Error())
+		case errors.Is(err, orderService.ErrInvalidRefundItems):
+			response.BadRequest(c, response.CodeInvalidRefundItems, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	response.Success(c, dto.RefundResponse{Refund: refund})
+	log.Info("refund request success, orderNo:", orderNo, " refundID:", refund.Id)
+}
+
+// ListOrderRefunds 处理查询订单退款记录请求（只能查询自己的订单）
+func (h *RefundHandler) ListOrderRefunds(c *gin.Context) {
+	// 从JWT中间件注入的上下文中获取认证后的userID
+	userID, exists := c.Get("userID")
+	if !exists {
+		response.Unauthorized(c, response.CodeUnauthorized, "user not authenticated")
+		return
+	}
+	uid := userID.(int)
+
+	orderNo := c.Param("order_no")
+
+	refunds, err := h.rSvc.GetOrderRefunds(uid, orderNo)
+	if err != nil {
+		if errors.Is(err, orderService.ErrOrderNotFound) {
+			response.NotFound(c, response.CodeOrderNotFound, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.RefundListResponse{Refunds: refunds})
+}
+
+// ListRefunds 处理管理员查询退款单列表请求
+// Query参数示例：/admin/refund?status=requested&page=1&page_size=20
+func (h *RefundHandler) ListRefunds(c *gin.Context) {
+	var req dto.ListRefundRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	refunds, total, err := h.rSvc.ListRefunds(model.RefundStatus(req.Status), (req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.RefundListResponse{Refunds: refunds}, response.NewPagination(req.Page, req.PageSize, total))
+}
+
+// ApproveRefund 处理管理员批准退款请求
+// 业务流程：
+// 1. 从URL路径中提取退款单ID，解析审批备注
+// 2. 调用Service层审批（网关退款、更新订单状态和已退款数量、归还库存）
+// 3. 返回审批后的退款单
+func (h *RefundHandler) ApproveRefund(c *gin.Context) {
+	h.reviewRefund(c, true)
+}
+
+// RejectRefund 处理管理员拒绝退款请求，订单状态和库存不变
+func (h *RefundHandler) RejectRefund(c *gin.Context) {
+	h.reviewRefund(c, false)
+}
+
+// reviewRefund 审批退款的公共处理逻辑，approve为true时批准，否则拒绝
+func (h *RefundHandler) reviewRefund(c *gin.Context, approve bool) {
+	// 审批人为管理员账号（由AuthMiddleWare注入，AdminMiddleWare已校验）
+	reviewer := c.GetString("account")
+
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.ReviewRefundRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, "invalid JSON")
+		return
+	}
+
+	var refund *model.Refund
+	if approve {
+		refund, err = h.rSvc.ApproveRefund(id, reviewer, req.Note)
+	} else {
+		refund, err = h.rSvc.RejectRefund(id, reviewer, req.Note)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRefundNotFound):
+			response.NotFound(c, response.CodeRefundNotFound, err.Error())
+		case errors.Is(err, service.ErrRefundNotPending):
+			response.Conflict(c, response.CodeRefundNotPending, err.Error())
+		case errors.Is(err, service.ErrRefundFailed):
+			response.InternalServerError(c, response.CodeRefundFailed, err.Error())
+		case errors.Is(err, orderService.ErrIllegalTransition), errors.Is(err, orderService.ErrInvalidRefundItems):
+			response.Conflict(c, response.CodeOrderIllegalTransition, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	response.Success(c, dto.RefundResponse{Refund: refund})
+	log.Infof("refund %d reviewed by %s, status: %s", id, reviewer, refund.Status)
+}
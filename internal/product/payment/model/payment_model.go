@@ -0,0 +1,35 @@
+package model
+
+import (
+	"server/pkg/money"
+	"time"
+)
+
+// PaymentStatus 支付单状态
+type PaymentStatus string
+
+const (
+	PaymentStatusCreated   PaymentStatus = "created"   // 已创建，等待买家在网关完成支付
+	PaymentStatusSucceeded PaymentStatus = "succeeded" // 网关回调确认支付成功
+	PaymentStatusFailed    PaymentStatus = "failed"    // 网关回调通知支付失败
+	// PaymentStatusRefundRequired 网关确认支付成功，但订单已由其他支付单支付或已关闭，需要将款项退回给买家
+	PaymentStatusRefundRequired PaymentStatus = "refund_required"
+)
+
+// Payment 支付单模型，记录一次订单支付请求及其在支付网关中的交易信息
+// 一个订单可以有多个支付单（如支付失败后重新发起），但最多只有一个支付成功，多出的成功支付置为refund_required
+type Payment struct {
+	Id        int    `gorm:"primary_key"`
+	OrderId   int    `json:"-"`
+	OrderNo   string // 订单号
+	UserId    int
+	Gateway   string        // 支付网关名称，如mock
+	TradeNo   string        // 网关交易号，用于回调时定位支付单
+	PayURL    string        `json:"-"` // 网关返回的支付地址，重复发起支付时复用
+	Amount    money.Amount  // 支付金额（分），等于订单的应付金额
+	Currency  string        // 币种（ISO 4217 代码）
+	Status    PaymentStatus // 支付单状态
+	PaidAt    *time.Time    // 支付成功时间
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
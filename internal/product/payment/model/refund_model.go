@@ -0,0 +1,54 @@
+package model
+
+import (
+	"server/pkg/money"
+	"time"
+)
+
+// RefundStatus 退款单状态
+type RefundStatus string
+
+// 退款单生命周期：
+//
+//	requested → processing → succeeded
+//	    ↓            ↓
+//	 rejected      failed
+const (
+	RefundStatusRequested  RefundStatus = "requested"  // 买家已申请，等待管理员审批
+	RefundStatusRejected   RefundStatus = "rejected"   // 管理员拒绝
+	RefundStatusProcessing RefundStatus = "processing" // 管理员已批准，正在通过网关退款
+	RefundStatusSucceeded  RefundStatus = "succeeded"  // 退款成功，订单和库存已更新
+	RefundStatusFailed     RefundStatus = "failed"     // 网关退款失败
+)
+
+// Refund 退款单模型，一次退款申请可以退订单中的部分或全部商品，退款原路退回到原支付单
+type Refund struct {
+	Id         int    `gorm:"primary_key"`
+	OrderId    int    `json:"-"`
+	OrderNo    string // 订单号
+	PaymentId  int    // 原支付单ID
+	UserId     int
+	Amount     money.Amount // 退款金额（分）= 各退款行金额之和
+	Currency   string       // 币种（ISO 4217 代码）
+	Reason     string       // 买家填写的退款原因
+	Status     RefundStatus // 退款单状态
+	RefundNo   string       // 网关退款单号
+	Reviewer   string       // 审批的管理员账号
+	ReviewNote string       // 审批备注
+	ReviewedAt *time.Time   // 审批时间
+	Items      []RefundItem `gorm:"foreignKey:RefundId"` // 退款行
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// RefundItem 退款行模型，记录退款的订单行和退货数量
+type RefundItem struct {
+	Id          int `gorm:"primary_key"`
+	RefundId    int
+	OrderItemId int
+	CommodityId int
+	Quantity    int          // 退货数量
+	Amount      money.Amount // 退款行金额（分）= 订单行单价 * Quantity
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
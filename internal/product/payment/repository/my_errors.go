@@ -0,0 +1,14 @@
+package repository
+
+import "errors"
+
+var (
+	// ErrPaymentStatusConflict 支付单状态已被并发修改（如网关重复回调），条件更新未命中
+	ErrPaymentStatusConflict = errors.New("payment status changed concurrently")
+
+	// ErrRefundStatusConflict 退款单状态已被并发修改（如被其他管理员审批），条件更新未命中
+	ErrRefundStatusConflict = errors.New("refund status changed concurrently")
+
+	// ErrOpenRefundExists 订单已有未结束（待审批或退款中）的退款单，不能再创建新的退款单
+	ErrOpenRefundExists = errors.New("order has an open refund")
+)
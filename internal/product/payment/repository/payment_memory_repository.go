@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"server/internal/product/payment/model"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryPaymentRepository struct {
+	mu       sync.RWMutex
+	payments map[int]model.Payment
+	lastId   int
+}
+
+// NewMemoryPaymentRepository 创建一个基于进程内存储的支付单仓储实例，用于memory存储模式
+func NewMemoryPaymentRepository() PaymentRepository {
+	return &memoryPaymentRepository{payments: make(map[int]model.Payment)}
+}
+
+// CreatePayment 创建新支付单记录，并回写分配的支付单ID
+func (pRepo *memoryPaymentRepository) CreatePayment(payment *model.Payment) error {
+	pRepo.mu.Lock()
+	defer pRepo.mu.Unlock()
+	now := time.Now()
+	if payment.CreatedAt.IsZero() {
+		payment.CreatedAt = now
+	}
+	if payment.UpdatedAt.IsZero() {
+		payment.UpdatedAt = now
+	}
+	pRepo.lastId++
+	payment.Id = pRepo.lastId
+	pRepo.payments[payment.Id] = *payment
+	return nil
+}
+
+// UpdateIntent 记录网关返回的交易号和支付地址
+func (pRepo *memoryPaymentRepository) UpdateIntent(paymentId int, tradeNo string, payURL string) error {
+	pRepo.mu.Lock()
+	defer pRepo.mu.Unlock()
+	if payment, ok := pRepo.payments[paymentId]; ok {
+		payment.TradeNo = tradeNo
+		payment.PayURL = payURL
+		payment.UpdatedAt = time.Now()
+		pRepo.payments[paymentId] = payment
+	}
+	return nil
+}
+
+// UpdatePaymentStatus 仅当支付单当前状态为from时才更新为to，状态已被修改时返回ErrPaymentStatusConflict
+func (pRepo *memoryPaymentRepository) UpdatePaymentStatus(paymentId int, from, to model.PaymentStatus, paidAt *time.Time) error {
+	pRepo.mu.Lock()
+	defer pRepo.mu.Unlock()
+	payment, ok := pRepo.payments[paymentId]
+	if !ok || payment.Status != from {
+		return ErrPaymentStatusConflict
+	}
+	payment.Status = to
+	payment.PaidAt = paidAt
+	payment.UpdatedAt = time.Now()
+	pRepo.payments[paymentId] = payment
+	return nil
+}
+
+// FindPaymentById 根据ID查找支付单，不存在时返回gorm.ErrRecordNotFound
+func (pRepo *memoryPaymentRepository) FindPaymentById(paymentId int) (*model.Payment, error) {
+	pRepo.mu.RLock()
+	defer pRepo.mu.RUnlock()
+	payment, ok := pRepo.payments[paymentId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &payment, nil
+}
+
+// FindPaymentByTradeNo 根据网关和网关交易号查找支付单，不存在时返回gorm.ErrRecordNotFound
+func (pRepo *memoryPaymentRepository) FindPaymentByTradeNo(gateway string, tradeNo string) (*model.Payment, error) {
+	pRepo.mu.RLock()
+	defer pRepo.mu.RUnlock()
+	for _, payment := range pRepo.payments {
+		if payment.Gateway == gateway && payment.TradeNo == tradeNo {
+			return &payment, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindPaymentsByOrderId 查找订单的所有支付单，按创建时间倒序
+func (pRepo *memoryPaymentRepository) FindPaymentsByOrderId(orderId int) ([]*model.Payment, error) {
+	pRepo.mu.RLock()
+	defer pRepo.mu.RUnlock()
+	payments := make([]*model.Payment, 0)
+	for _, payment := range pRepo.payments {
+		if payment.OrderId == orderId {
+			payment := payment
+			payments = append(payments, &payment)
+		}
+	}
+	sort.Slice(payments, func(i, j int) bool { return payments[i].Id > payments[j].Id })
+	return payments, nil
+}
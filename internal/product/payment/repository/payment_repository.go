@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"server/internal/product/payment/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// paymentWriter 定义支付单写操作接口
+type paymentWriter interface {
+	CreatePayment(payment *model.Payment) error
+	UpdateIntent(paymentId int, tradeNo string, payURL string) error
+	UpdatePaymentStatus(paymentId int, from, to model.PaymentStatus, paidAt *time.Time) error
+}
+
+// paymentReader 定义支付单读操作接口
+type paymentReader interface {
+	FindPaymentById(paymentId int) (*model.Payment, error)
+	FindPaymentByTradeNo(gateway string, tradeNo string) (*model.Payment, error)
+	FindPaymentsByOrderId(orderId int) ([]*model.Payment, error)
+}
+
+// PaymentRepository 支付单操作的数据访问接口，组合了读写操作
+type PaymentRepository interface {
+	paymentWriter
+	paymentReader
+}
+
+type gormPaymentRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewPaymentRepository 创建一个新的支付单仓储实例
+func NewPaymentRepository(gDB *gorm.DB) PaymentRepository {
+	return &gormPaymentRepository{gormDB: gDB}
+}
+
+// CreatePayment 在数据库中创建新支付单记录
+func (pRepo *gormPaymentRepository) CreatePayment(payment *model.Payment) error {
+	return pRepo.gormDB.Create(payment).Error
+}
+
+// UpdateIntent 记录网关返回的交易号和支付地址
+func (pRepo *gormPaymentRepository) UpdateIntent(paymentId int, tradeNo string, payURL string) error {
+	return pRepo.gormDB.Model(&model.Payment{}).
+		Where("id = ?", paymentId).
+		Updates(map[string]interface{}{"trade_no": tradeNo, "pay_url": payURL, "updated_at": time.Now()}).Error
+}
+
+// UpdatePaymentStatus 以条件更新的方式修改支付单状态（仅当当前状态为from时才更新为to）
+// 网关可能重复回调，条件更新保证同一支付单只会被处理一次，状态已被修改时返回ErrPaymentStatusConflict
+func (pRepo *gormPaymentRepository) UpdatePaymentStatus(paymentId int, from, to model.PaymentStatus, paidAt *time.Time) error {
+	result := pRepo.gormDB.Model(&model.Payment{}).
+		Where("id = ? AND status = ?", paymentId, from).
+		Updates(map[string]interface{}{"status": to, "paid_at": paidAt, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPaymentStatusConflict
+	}
+	return nil
+}
+
+// FindPaymentById 根据ID查找支付单
+func (pRepo *gormPaymentRepository) FindPaymentById(paymentId int) (*model.Payment, error) {
+	var payment model.Payment
+	err := pRepo.gormDB.First(&payment, paymentId).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// FindPaymentByTradeNo 根据网关和网关交易号查找支付单
+func (pRepo *gormPaymentRepository) FindPaymentByTradeNo(gateway string, tradeNo string) (*model.Payment, error) {
+	var payment model.Payment
+	err := pRepo.gormDB.Where("gateway = ? AND trade_no = ?", gateway, tradeNo).First(&payment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// FindPaymentsByOrderId 查找订单的所有支付单，按创建时间倒序
+func (pRepo *gormPaymentRepository) FindPaymentsByOrderId(orderId int) ([]*model.Payment, error) {
+	var payments []*model.Payment
+	err := pRepo.gormDB.Where("order_id = ?", orderId).Order("id DESC").Find(&payments).Error
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
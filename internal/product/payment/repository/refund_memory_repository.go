@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"server/internal/product/payment/model"
+	"server/pkg/memstore"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryRefundRepository struct {
+	mu         sync.RWMutex
+	refunds    map[int]*model.Refund
+	lastId     int
+	lastItemId int
+}
+
+// NewMemoryRefundRepository 创建一个基于进程内存储的退款单仓储实例，用于memory存储模式
+func NewMemoryRefundRepository() RefundRepository {
+	return &memoryRefundRepository{refunds: make(map[int]*model.Refund)}
+}
+
+// copyRefund 复制退款单及其退款行，避免调用方修改返回值时影响存储中的数据
+func copyRefund(refund *model.Refund) *model.Refund {
+	copied := *refund
+	copied.Items = append([]model.RefundItem(nil), refund.Items...)
+	return &copied
+}
+
+// CreateRefund 在订单没有未结束的退款单时创建新退款单记录（包含退款行），并回写分配的退款单ID和退款行ID
+// 检查和写入在同一把锁内完成，订单已有待审批或退款中的退款单时返回ErrOpenRefundExists
+func (rRepo *memoryRefundRepository) CreateRefund(refund *model.Refund) error {
+	rRepo.mu.Lock()
+	defer rRepo.mu.Unlock()
+	for _, existing := range rRepo.refunds {
+		if existing.OrderId == refund.OrderId &&
+			(existing.Status == model.RefundStatusRequested || existing.Status == model.RefundStatusProcessing) {
+			return ErrOpenRefundExists
+		}
+	}
+	now := time.Now()
+	if refund.CreatedAt.IsZero() {
+		refund.CreatedAt = now
+	}
+	if refund.UpdatedAt.IsZero() {
+		refund.UpdatedAt = now
+	}
+	rRepo.lastId++
+	refund.Id = rRepo.lastId
+	for i := range refund.Items {
+		item := &refund.Items[i]
+		rRepo.lastItemId++
+		item.Id = rRepo.lastItemId
+		item.RefundId = refund.Id
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = now
+		}
+		if item.UpdatedAt.IsZero() {
+			item.UpdatedAt = now
+		}
+	}
+	rRepo.refunds[refund.Id] = copyRefund(refund)
+	return nil
+}
+
+// ReviewRefund 仅当退款单当前状态为requested时才更新为审批结果，并记录审批人和审批时间
+// 退款单已被其他管理员审批时返回ErrRefundStatusConflict
+func (rRepo *memoryRefundRepository) ReviewRefund(refundId int, to model.RefundStatus, reviewer string, note string) error {
+	rRepo.mu.Lock()
+	defer rRepo.mu.Unlock()
+	refund, ok := rRepo.refunds[refundId]
+	if !ok || refund.Status != model.RefundStatusRequested {
+		return ErrRefundStatusConflict
+	}
+	now := time.Now()
+	refund.Status = to
+	refund.Reviewer = reviewer
+	refund.ReviewNote = note
+	refund.ReviewedAt = &now
+	refund.UpdatedAt = now
+	return nil
+}
+
+// FinishRefund 仅当退款单当前状态为processing时才记录网关退款结果
+func (rRepo *memoryRefundRepository) FinishRefund(refundId int, to model.RefundStatus, refundNo string) error {
+	rRepo.mu.Lock()
+	defer rRepo.mu.Unlock()
+	refund, ok := rRepo.refunds[refundId]
+	if !ok || refund.Status != model.RefundStatusProcessing {
+		return ErrRefundStatusConflict
+	}
+	refund.Status = to
+	refund.RefundNo = refundNo
+	refund.UpdatedAt = time.Now()
+	return nil
+}
+
+// FindRefundById 根据ID查找退款单（包含退款行），不存在时返回gorm.ErrRecordNotFound
+func (rRepo *memoryRefundRepository) FindRefundById(refundId int) (*model.Refund, error) {
+	rRepo.mu.RLock()
+	defer rRepo.mu.RUnlock()
+	refund, ok := rRepo.refunds[refundId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return copyRefund(refund), nil
+}
+
+// FindRefundsByOrderId 查找订单的所有退款单，按创建时间倒序
+func (rRepo *memoryRefundRepository) FindRefundsByOrderId(orderId int) ([]*model.Refund, error) {
+	refunds := rRepo.listRefunds(func(refund *model.Refund) bool { return refund.OrderId == orderId })
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].Id > refunds[j].Id })
+	return refunds, nil
+}
+
+// FindRefundsByStatus 按状态分页查找退款单（status为空时不过滤），按创建时间升序（先申请的先审批）
+// 返回当前页的退款单以及满足条件的退款单总数
+func (rRepo *memoryRefundRepository) FindRefundsByStatus(status model.RefundStatus, offset int, limit int) ([]*model.Refund, int64, error) {
+	refunds := rRepo.listRefunds(func(refund *model.Refund) bool { return status == "" || refund.Status == status })
+	sort.Slice(refunds, func(i, j int) bool { return refunds[i].Id < refunds[j].Id })
+	return memstore.Paginate(refunds, offset, limit), int64(len(refunds)), nil
+}
+
+// listRefunds 返回满足条件的退款单副本
+func (rRepo *memoryRefundRepository) listRefunds(match func(refund *model.Refund) bool) []*model.Refund {
+	rRepo.mu.RLock()
+	defer rRepo.mu.RUnlock()
+	refunds := make([]*model.Refund, 0)
+	for _, refund := range rRepo.refunds {
+		if match(refund) {
+			refunds = append(refunds, copyRefund(refund))
+		}
+	}
+	return refunds
+}
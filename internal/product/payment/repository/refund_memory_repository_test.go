@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"server/internal/product/payment/model"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRefundRepositoryCreateRefund(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing model.RefundStatus // 订单已有的退款单状态，为空表示没有
+		wantErr  error
+	}{
+		{"first refund", "", nil},
+		{"requested refund is open", model.RefundStatusRequested, ErrOpenRefundExists},
+		{"processing refund is open", model.RefundStatusProcessing, ErrOpenRefundExists},
+		{"rejected refund is closed", model.RefundStatusRejected, nil},
+		{"succeeded refund is closed", model.RefundStatusSucceeded, nil},
+		{"failed refund is closed", model.RefundStatusFailed, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryRefundRepository()
+			if tt.existing != "" {
+				require.NoError(t, repo.CreateRefund(&model.Refund{OrderId: 1, Status: tt.existing}))
+			}
+			// 其他订单的未结束退款单不影响
+			require.NoError(t, repo.CreateRefund(&model.Refund{OrderId: 2, Status: model.RefundStatusRequested}))
+
+			refund := &model.Refund{OrderId: 1, Status: model.RefundStatusRequested, Items: []model.RefundItem{{Quantity: 1}}}
+			err := repo.CreateRefund(refund)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := repo.FindRefundById(refund.Id)
+			require.NoError(t, err)
+			assert.Equal(t, refund.Id, got.Items[0].RefundId)
+		})
+	}
+}
+
+func TestMemoryRefundRepositoryReviewAndFinish(t *testing.T) {
+	repo := NewMemoryRefundRepository()
+	refund := &model.Refund{OrderId: 1, Status: model.RefundStatusRequested}
+	require.NoError(t, repo.CreateRefund(refund))
+
+	assert.ErrorIs(t, repo.FinishRefund(refund.Id, model.RefundStatusSucceeded, "R1"), ErrRefundStatusConflict,
+		"a refund must be approved before it is finished")
+	require.NoError(t, repo.ReviewRefund(refund.Id, model.RefundStatusProcessing, "admin", ""))
+	assert.ErrorIs(t, repo.ReviewRefund(refund.Id, model.RefundStatusRejected, "other", ""), ErrRefundStatusConflict,
+		"a refund is reviewed only once")
+
+	require.NoError(t, repo.FinishRefund(refund.Id, model.RefundStatusSucceeded, "R1"))
+	assert.ErrorIs(t, repo.FinishRefund(refund.Id, model.RefundStatusFailed, ""), ErrRefundStatusConflict)
+
+	got, err := repo.FindRefundById(refund.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.RefundStatusSucceeded, got.Status)
+	assert.Equal(t, "R1", got.RefundNo)
+	assert.Equal(t, "admin", got.Reviewer)
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"server/internal/product/payment/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// openRefundStatuses 未结束的退款单状态，同一订单同时只能有一个处于这些状态的退款单
+var openRefundStatuses = []model.RefundStatus{model.RefundStatusRequested, model.RefundStatusProcessing}
+
+// refundWriter 定义退款单写操作接口
+type refundWriter interface {
+	CreateRefund(refund *model.Refund) error
+	ReviewRefund(refundId int, to model.RefundStatus, reviewer string, note string) error
+	FinishRefund(refundId int, to model.RefundStatus, refundNo string) error
+}
+
+// refundReader 定义退款单读操作接口
+type refundReader interface {
+	FindRefundById(refundId int) (*model.Refund, error)
+	FindRefundsByOrderId(orderId int) ([]*model.Refund, error)
+	FindRefundsByStatus(status model.RefundStatus, offset int, limit int) ([]*model.Refund, int64, error)
+}
+
+// RefundRepository 退款单操作的数据访问接口，组合了读写操作
+type RefundRepository interface {
+	refundWriter
+	refundReader
+}
+
+type gormRefundRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewRefundRepository 创建一个新的退款单仓储实例
+func NewRefundRepository(gDB *gorm.DB) RefundRepository {
+	return &gormRefundRepository{gormDB: gDB}
+}
+
+// CreateRefund 在订单没有未结束的退款单时创建新退款单记录，退款行随退款单在同一事务中一并写入
+// 事务中先锁定退款关联的原支付单，同一订单的并发退款申请因此串行执行，后到的请求能看到先创建的退款单
+// 订单已有待审批或退款中的退款单时返回ErrOpenRefundExists
+func (rRepo *gormRefundRepository) CreateRefund(refund *model.Refund) error {
+	return rRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		var payment model.Payment
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&payment, refund.PaymentId).Error
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		err = tx.Model(&model.Refund{}).
+			Where("order_id = ? AND status IN ?", refund.OrderId, openRefundStatuses).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOpenRefundExists
+		}
+		return tx.Create(refund).Error
+	})
+}
+
+// ReviewRefund 以条件更新的方式审批退款单（仅当当前状态为requested时才更新），并记录审批人和审批时间
+// 退款单已被其他管理员审批时返回ErrRefundStatusConflict
+func (rRepo *gormRefundRepository) ReviewRefund(refundId int, to model.RefundStatus, reviewer string, note string) error {
+	now := time.Now()
+	result := rRepo.gormDB.Model(&model.Refund{}).
+		Where("id = ? AND status = ?", refundId, model.RefundStatusRequested).
+		Updates(map[string]interface{}{
+			"status":      to,
+			"reviewer":    reviewer,
+			"review_note": note,
+			"reviewed_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundStatusConflict
+	}
+	return nil
+}
+
+// FinishRefund 以条件更新的方式记录网关退款结果（仅当当前状态为processing时才更新）
+func (rRepo *gormRefundRepository) FinishRefund(refundId int, to model.RefundStatus, refundNo string) error {
+	result := rRepo.gormDB.Model(&model.Refund{}).
+		Where("id = ? AND status = ?", refundId, model.RefundStatusProcessing).
+		Updates(map[string]interface{}{"status": to, "refund_no": refundNo, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundStatusConflict
+	}
+	return nil
+}
+
+// FindRefundById 根据ID查找退款单（包含退款行）
+func (rRepo *gormRefundRepository) FindRefundById(refundId int) (*model.Refund, error) {
+	var refund model.Refund
+	err := rRepo.gormDB.Preload("Items").First(&refund, refundId).Error
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// FindRefundsByOrderId 查找订单的所有退款单，按创建时间倒序
+func (rRepo *gormRefundRepository) FindRefundsByOrderId(orderId int) ([]*model.Refund, error) {
+	var refunds []*model.Refund
+	err := rRepo.gormDB.Preload("Items").Where("order_id = ?", orderId).Order("id DESC").Find(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// FindRefundsByStatus 按状态分页查找退款单（status为空时不过滤），按创建时间升序（先申请的先审批）
+// 返回当前页的退款单以及满足条件的退款单总数
+func (rRepo *gormRefundRepository) FindRefundsByStatus(status model.RefundStatus, offset int, limit int) ([]*model.Refund, int64, error) {
+	db := rRepo.gormDB.Model(&model.Refund{})
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var refunds []*model.Refund
+	err := db.Preload("Items").Order("id ASC").Offset(offset).Limit(limit).Find(&refunds).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return refunds, total, nil
+}
@@ -0,0 +1,38 @@
+package service
+
+import "errors"
+
+var (
+	// ErrOrderNotPayable 订单当前状态不允许支付（只有pending订单可以支付）
+	ErrOrderNotPayable = errors.New("order is not payable")
+
+	// ErrPaymentCreateFailed 支付网关创建支付意图失败
+	ErrPaymentCreateFailed = errors.New("failed to create payment")
+
+	// ErrPaymentNotFound 回调中的交易号没有对应的支付单
+	ErrPaymentNotFound = errors.New("payment not found")
+
+	// ErrPaymentAmountMismatch 回调中的实付金额与支付单金额不一致
+	ErrPaymentAmountMismatch = errors.New("payment amount mismatch")
+
+	// ErrOrderClosed 支付成功时订单已关闭（如已超时取消），需要人工退款
+	ErrOrderClosed = errors.New("order is closed")
+
+	// ErrOrderAlreadyPaid 支付成功时订单已由其他支付单支付，需要人工退款
+	ErrOrderAlreadyPaid = errors.New("order is already paid")
+
+	// ErrOrderNotRefundable 订单当前状态不允许退款（未支付、已取消或已全额退款）
+	ErrOrderNotRefundable = errors.New("order is not refundable")
+
+	// ErrRefundInProgress 订单已有待审批或退款中的退款单
+	ErrRefundInProgress = errors.New("refund in progress")
+
+	// ErrRefundNotFound 退款单不存在
+	ErrRefundNotFound = errors.New("refund not found")
+
+	// ErrRefundNotPending 退款单已被审批，不能重复审批
+	ErrRefundNotPending = errors.New("refund is not pending review")
+
+	// ErrRefundFailed 支付网关退款失败
+	ErrRefundFailed = errors.New("refund failed")
+)
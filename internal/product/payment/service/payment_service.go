@@ -0,0 +1,214 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	orderModel "server/internal/product/order/model"
+	orderService "server/internal/product/order/service"
+	"server/internal/product/payment/gateway"
+	"server/internal/product/payment/model"
+	"server/internal/product/payment/repository"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// PaymentService 提供订单支付相关的业务逻辑服务
+type PaymentService struct {
+	pRepo    repository.PaymentRepository
+	gateway  gateway.PaymentGateway
+	orderSvc *orderService.OrderService
+}
+
+// NewPaymentService 创建一个新的支付服务实例
+func NewPaymentService(pRepo repository.PaymentRepository, gateway gateway.PaymentGateway, orderSvc *orderService.OrderService) *PaymentService {
+	return &PaymentService{
+		pRepo:    pRepo,
+		gateway:  gateway,
+		orderSvc: orderSvc,
+	}
+}
+
+// CreatePayment 为用户自己的待支付订单创建支付单，并在支付网关创建支付意图
+// 业务流程：
+// 1. 查询属于该用户的订单，只有pending（待支付）订单可以支付
+// 2. 订单已有支付成功的支付单时拒绝；已有等待支付且拿到网关交易号的支付单时直接返回该支付单和原支付地址
+// 3. 创建支付单，金额为订单应付金额（总金额减去优惠金额）
+// 4. 调用支付网关创建支付意图，记录网关交易号和支付地址
+// 5. 返回支付单和支付地址，买家完成支付后由网关回调通知支付结果
+//
+// 注意：
+// - 复用等待中的支付单避免买家重复点击支付时在网关产生多笔可支付的交易
+// - 并发请求仍可能各自创建支付单，多笔支付成功时由回调处理将多出的支付置为refund_required
+func (ps *PaymentService) CreatePayment(userId int, orderNo string) (*model.Payment, *gateway.Intent, error) {
+	order, err := ps.orderSvc.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order.Status != orderModel.StatusPending {
+		return nil, nil, fmt.Errorf("%w: order %s is %s", ErrOrderNotPayable, orderNo, order.Status)
+	}
+
+	payments, err := ps.pRepo.FindPaymentsByOrderId(order.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, existing := range payments {
+		switch existing.Status {
+		case model.PaymentStatusSucceeded:
+			return nil, nil, fmt.Errorf("%w: order %s is already paid by payment %d", ErrOrderNotPayable, orderNo, existing.Id)
+		case model.PaymentStatusCreated:
+			// 没有网关交易号的支付单未能完成创建，买家无法通过它支付，忽略
+			if existing.TradeNo != "" && existing.Amount == order.PayAmount {
+				return existing, &gateway.Intent{TradeNo: existing.TradeNo, PayURL: existing.PayURL}, nil
+			}
+		}
+	}
+
+	now := time.Now()
+	payment := &model.Payment{
+		OrderId:   order.Id,
+		OrderNo:   order.OrderNo,
+		UserId:    userId,
+		Gateway:   ps.gateway.Name(),
+		Amount:    order.PayAmount,
+		Currency:  order.Currency,
+		Status:    model.PaymentStatusCreated,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err = ps.pRepo.CreatePayment(payment); err != nil {
+		return nil, nil, err
+	}
+
+	intent, err := ps.gateway.CreatePayment(payment)
+	if err != nil {
+		if updateErr := ps.pRepo.UpdatePaymentStatus(payment.Id, model.PaymentStatusCreated, model.PaymentStatusFailed, nil); updateErr != nil {
+			log.Errorf("Failed to mark payment %d as failed: %v", payment.Id, updateErr)
+		}
+		return nil, nil, fmt.Errorf("%w: %v", ErrPaymentCreateFailed, err)
+	}
+	if err = ps.pRepo.UpdateIntent(payment.Id, intent.TradeNo, intent.PayURL); err != nil {
+		return nil, nil, err
+	}
+	payment.TradeNo = intent.TradeNo
+	payment.PayURL = intent.PayURL
+
+	return payment, intent, nil
+}
+
+// HandleCallback 处理支付网关的回调通知
+// 业务流程：
+// 1. 校验回调签名并解析通知内容（签名无效直接拒绝）
+// 2. 根据网关交易号查找支付单
+// 3. 支付失败：将支付单置为failed，订单保持pending，买家可以重新发起支付
+// 4. 支付成功：校验实付金额，将支付单置为succeeded，再将订单置为paid
+//   - 订单置为paid时会撤销延迟取消任务，超时后不再取消订单
+//
+// 幂等性：
+// - 网关可能重复回调，支付单的状态以条件更新写入，只会被处理一次
+// - 支付单已是succeeded时仍会确认订单状态，用于补偿上次回调中订单状态更新失败的情况
+// - 支付单已是refund_required时直接返回成功，网关不再重试
+func (ps *PaymentService) HandleCallback(body []byte, signature string) error {
+	notification, err := ps.gateway.VerifyCallback(body, signature)
+	if err != nil {
+		return err
+	}
+
+	payment, err := ps.pRepo.FindPaymentByTradeNo(ps.gateway.Name(), notification.TradeNo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: %s", ErrPaymentNotFound, notification.TradeNo)
+		}
+		return err
+	}
+
+	switch notification.Status {
+	case gateway.NotifyStatusFailed:
+		err = ps.pRepo.UpdatePaymentStatus(payment.Id, model.PaymentStatusCreated, model.PaymentStatusFailed, nil)
+		if err != nil && !errors.Is(err, repository.ErrPaymentStatusConflict) {
+			return err
+		}
+		log.Info("payment failed, paymentID:", payment.Id, " orderID:", payment.OrderId)
+		return nil
+	case gateway.NotifyStatusSuccess:
+		return ps.confirmPayment(payment, notification)
+	default:
+		return fmt.Errorf("%w: unknown status %s", gateway.ErrInvalidNotification, notification.Status)
+	}
+}
+
+// confirmPayment 确认支付成功，将支付单置为succeeded并将订单置为paid
+// 同一订单有多笔支付成功时（如买家在两个支付页面都完成了支付），只保留ID最小的一笔，
+// 其余支付单置为refund_required并返回ErrOrderAlreadyPaid；订单已关闭时同样置为refund_required并返回ErrOrderClosed
+func (ps *PaymentService) confirmPayment(payment *model.Payment, notification *gateway.Notification) error {
+	paidAt := payment.PaidAt
+	switch payment.Status {
+	case model.PaymentStatusCreated:
+		if notification.Amount != payment.Amount {
+			return fmt.Errorf("%w: expected %s, actual %s", ErrPaymentAmountMismatch, payment.Amount, notification.Amount)
+		}
+		now := time.Now()
+		err := ps.pRepo.UpdatePaymentStatus(payment.Id, model.PaymentStatusCreated, model.PaymentStatusSucceeded, &now)
+		if errors.Is(err, repository.ErrPaymentStatusConflict) {
+			// 并发的重复回调已经处理了该支付单
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		paidAt = &now
+	case model.PaymentStatusSucceeded:
+		// 重复回调，继续确认订单状态
+	default:
+		log.Warnf("Ignore success callback of payment %d in status %s", payment.Id, payment.Status)
+		return nil
+	}
+
+	earlier, err := ps.findEarlierSucceededPayment(payment)
+	if err != nil {
+		return err
+	}
+	if earlier != nil {
+		log.Errorf("Payment %d succeeded but order %d is already paid by payment %d, refund required", payment.Id, payment.OrderId, earlier.Id)
+		ps.markRefundRequired(payment, paidAt)
+		return fmt.Errorf("%w: order %d by payment %d", ErrOrderAlreadyPaid, payment.OrderId, earlier.Id)
+	}
+
+	detail := fmt.Sprintf("payment %d, %s trade %s", payment.Id, payment.Gateway, payment.TradeNo)
+	if err = ps.orderSvc.MarkOrderPaid(payment.OrderId, detail); err != nil {
+		if errors.Is(err, orderService.ErrIllegalTransition) {
+			log.Errorf("Payment %d succeeded but order %d is closed, refund required: %v", payment.Id, payment.OrderId, err)
+			ps.markRefundRequired(payment, paidAt)
+			return fmt.Errorf("%w: order %d", ErrOrderClosed, payment.OrderId)
+		}
+		return err
+	}
+	log.Info("payment succeeded, paymentID:", payment.Id, " orderID:", payment.OrderId)
+	return nil
+}
+
+// findEarlierSucceededPayment 查找同一订单中ID更小的支付成功的支付单，没有时返回nil
+// 并发回调的两笔支付都会看到对方，按ID比较保证双方得出相同的结论
+func (ps *PaymentService) findEarlierSucceededPayment(payment *model.Payment) (*model.Payment, error) {
+	payments, err := ps.pRepo.FindPaymentsByOrderId(payment.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	var earlier *model.Payment
+	for _, p := range payments {
+		if p.Id < payment.Id && p.Status == model.PaymentStatusSucceeded && (earlier == nil || p.Id < earlier.Id) {
+			earlier = p
+		}
+	}
+	return earlier, nil
+}
+
+// markRefundRequired 将支付成功的支付单置为refund_required，等待人工退回；失败只记录日志，网关重试回调时会再次处理
+func (ps *PaymentService) markRefundRequired(payment *model.Payment, paidAt *time.Time) {
+	err := ps.pRepo.UpdatePaymentStatus(payment.Id, model.PaymentStatusSucceeded, model.PaymentStatusRefundRequired, paidAt)
+	if err != nil && !errors.Is(err, repository.ErrPaymentStatusConflict) {
+		log.Errorf("Failed to mark payment %d as refund required: %v", payment.Id, err)
+	}
+}
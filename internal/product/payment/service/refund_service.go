@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	orderModel "server/internal/product/order/model"
+	orderService "server/internal/product/order/service"
+	"server/internal/product/payment/gateway"
+	"server/internal/product/payment/model"
+	"server/internal/product/payment/repository"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RefundService 提供退款申请和审批相关的业务逻辑服务
+type RefundService struct {
+	rRepo    repository.RefundRepository
+	pRepo    repository.PaymentRepository
+	gateway  gateway.PaymentGateway
+	orderSvc *orderService.OrderService
+}
+
+// NewRefundService 创建一个新的退款服务实例
+func NewRefundService(rRepo repository.RefundRepository, pRepo repository.PaymentRepository, gateway gateway.PaymentGateway, orderSvc *orderService.OrderService) *RefundService {
+	return &RefundService{
+		rRepo:    rRepo,
+		pRepo:    pRepo,
+		gateway:  gateway,
+		orderSvc: orderSvc,
+	}
+}
+
+// RequestRefund 买家为自己已支付的订单申请退款
+// 业务流程：
+// 1. 查询属于该用户的订单，只有已支付且未全额退款的订单可以申请
+// 2. 校验退款数量（quantities为商品ID到退货数量的映射，为空时全额退款）
+// 3. 按订单行的实付金额（扣除分摊的优惠）计算退款金额，关联订单的成功支付单，创建待审批的退款单
+// 4. 同一订单同时只能有一个待审批或退款中的退款单，由仓储在创建时原子地检查，并发申请只有一个能成功
+func (rs *RefundService) RequestRefund(userId int, orderNo string, quantities map[int]int, reason string) (*model.Refund, error) {
+	order, err := rs.orderSvc.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	if !order.Status.IsRefundable() {
+		return nil, fmt.Errorf("%w: order %s is %s", ErrOrderNotRefundable, orderNo, order.Status)
+	}
+
+	payment, err := rs.findSucceededPayment(order.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	itemQuantities, err := orderService.RefundQuantities(order, quantities)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	refund := &model.Refund{
+		OrderId:   order.Id,
+		OrderNo:   order.OrderNo,
+		PaymentId: payment.Id,
+		UserId:    userId,
+		Currency:  payment.Currency,
+		Reason:    reason,
+		Status:    model.RefundStatusRequested,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	for _, item := range order.Items {
+		quantity, ok := itemQuantities[item.Id]
+		if !ok {
+			continue
+		}
+		amount := item.RefundAmount(item.RefundedQuantity, quantity)
+		refund.Amount += amount
+		refund.Items = append(refund.Items, model.RefundItem{
+			OrderItemId: item.Id,
+			CommodityId: item.CommodityId,
+			Quantity:    quantity,
+			Amount:      amount,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+
+	err = rs.rRepo.CreateRefund(refund)
+	if errors.Is(err, repository.ErrOpenRefundExists) {
+		return nil, fmt.Errorf("%w: order %s", ErrRefundInProgress, orderNo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Info("refund requested, refundID:", refund.Id, " orderNo:", orderNo, " amount:", refund.Amount)
+	return refund, nil
+}
+
+// findSucceededPayment 查找订单支付成功的支付单，没有时订单视为不可退款
+func (rs *RefundService) findSucceededPayment(orderId int) (*model.Payment, error) {
+	payments, err := rs.pRepo.FindPaymentsByOrderId(orderId)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range payments {
+		if payment.Status == model.PaymentStatusSucceeded {
+			return payment, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: order %d has no succeeded payment", ErrOrderNotRefundable, orderId)
+}
+
+// ApproveRefund 管理员批准退款
+// 业务流程：
+// 1. 以条件更新将退款单从requested置为processing（并发审批时只有一方能成功）
+// 2. 预留订单侧的退款：累加订单行的已退款数量，订单置为refunded或partially_refunded（不满足时退款单置为failed，不调用网关）
+// 3. 调用支付网关将退款金额原路退回到原支付单，失败时撤销订单侧的预留，退款单置为failed
+// 4. 记录订单的退款事件，归还退货数量的库存，退款单置为succeeded并记录网关退款单号
+//
+// 注意：网关退款失败且撤销预留也失败时，订单保持已退款状态并记录错误日志，需要人工处理
+func (rs *RefundService) ApproveRefund(refundId int, reviewer string, note string) (*model.Refund, error) {
+	refund, err := rs.getRefund(refundId)
+	if err != nil {
+		return nil, err
+	}
+	if err = rs.rRepo.ReviewRefund(refundId, model.RefundStatusProcessing, reviewer, note); err != nil {
+		if errors.Is(err, repository.ErrRefundStatusConflict) {
+			return nil, fmt.Errorf("%w: refund %d", ErrRefundNotPending, refundId)
+		}
+		return nil, err
+	}
+
+	payment, err := rs.pRepo.FindPaymentById(refund.PaymentId)
+	if err != nil {
+		rs.failRefund(refundId)
+		return nil, err
+	}
+	quantities := make(map[int]int, len(refund.Items))
+	for _, item := range refund.Items {
+		quantities[item.OrderItemId] = item.Quantity
+	}
+	reservation, err := rs.orderSvc.ReserveRefund(refund.OrderId, quantities)
+	if err != nil {
+		rs.failRefund(refundId)
+		return nil, err
+	}
+
+	refundNo, err := rs.gateway.Refund(payment, refund)
+	if err != nil {
+		if releaseErr := rs.orderSvc.ReleaseRefund(reservation); releaseErr != nil {
+			log.Errorf("Refund %d failed on gateway but failed to release order %d: %v", refundId, refund.OrderId, releaseErr)
+		}
+		rs.failRefund(refundId)
+		return nil, fmt.Errorf("%w: %v", ErrRefundFailed, err)
+	}
+
+	detail := fmt.Sprintf("refund %d, amount %s %s", refundId, refund.Amount, refund.Currency)
+	rs.orderSvc.ConfirmRefund(reservation, orderModel.OperatorAdmin(reviewer), detail)
+
+	if err = rs.rRepo.FinishRefund(refundId, model.RefundStatusSucceeded, refundNo); err != nil {
+		log.Errorf("Failed to mark refund %d as succeeded: %v", refundId, err)
+		return nil, err
+	}
+	log.Infof("Refund %d approved by %s, order %d is %s", refundId, reviewer, refund.OrderId, reservation.To)
+	return rs.rRepo.FindRefundById(refundId)
+}
+
+// failRefund 网关退款失败时将退款单置为failed，买家可以重新申请
+func (rs *RefundService) failRefund(refundId int) {
+	if err := rs.rRepo.FinishRefund(refundId, model.RefundStatusFailed, ""); err != nil {
+		log.Errorf("Failed to mark refund %d as failed: %v", refundId, err)
+	}
+}
+
+// RejectRefund 管理员拒绝退款，订单状态和库存不变
+func (rs *RefundService) RejectRefund(refundId int, reviewer string, note string) (*model.Refund, error) {
+	if _, err := rs.getRefund(refundId); err != nil {
+		return nil, err
+	}
+	if err := rs.rRepo.ReviewRefund(refundId, model.RefundStatusRejected, reviewer, note); err != nil {
+		if errors.Is(err, repository.ErrRefundStatusConflict) {
+			return nil, fmt.Errorf("%w: refund %d", ErrRefundNotPending, refundId)
+		}
+		return nil, err
+	}
+	log.Infof("Refund %d rejected by %s", refundId, reviewer)
+	return rs.rRepo.FindRefundById(refundId)
+}
+
+// getRefund 根据ID获取退款单，退款单不存在时返回ErrRefundNotFound
+func (rs *RefundService) getRefund(refundId int) (*model.Refund, error) {
+	refund, err := rs.rRepo.FindRefundById(refundId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRefundNotFound
+	}
+	return refund, err
+}
+
+// GetOrderRefunds 获取用户自己订单的所有退款单
+func (rs *RefundService) GetOrderRefunds(userId int, orderNo string) ([]*model.Refund, error) {
+	order, err := rs.orderSvc.GetUserOrder(userId, orderNo)
+	if err != nil {
+		return nil, err
+	}
+	return rs.rRepo.FindRefundsByOrderId(order.Id)
+}
+
+// ListRefunds 按状态分页获取退款单，供管理员审批使用
+func (rs *RefundService) ListRefunds(status model.RefundStatus, offset int, limit int) ([]*model.Refund, int64, error) {
+	return rs.rRepo.FindRefundsByStatus(status, offset, limit)
+}
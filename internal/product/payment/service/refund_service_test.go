@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"server/config"
+	commodityModel "server/internal/product/commodity/model"
+	commodityRepository "server/internal/product/commodity/repository"
+	commodityService "server/internal/product/commodity/service"
+	orderModel "server/internal/product/order/model"
+	orderRepository "server/internal/product/order/repository"
+	orderService "server/internal/product/order/service"
+	"server/internal/product/payment/gateway"
+	"server/internal/product/payment/model"
+	"server/internal/product/payment/repository"
+	promotionRepository "server/internal/product/promotion/repository"
+	promotionService "server/internal/product/promotion/service"
+	"server/pkg/idgen"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubGateway 退款结果可控的支付网关，refundErr不为nil时网关退款失败
+type stubGateway struct {
+	gateway.PaymentGateway
+	refundErr error
+}
+
+func (g *stubGateway) Refund(payment *model.Payment, refund *model.Refund) (string, error) {
+	if g.refundErr != nil {
+		return "", g.refundErr
+	}
+	return "RF-" + refund.OrderNo, nil
+}
+
+// refundFixture memory存储模式下的退款服务，以及一个已支付的订单（商品单价10.00、购买2件，下单后库存8）
+type refundFixture struct {
+	svc         *RefundService
+	orderSvc    *orderService.OrderService
+	commodity   commodityRepository.CommodityRepository
+	order       *orderModel.Order
+	commodityId int
+}
+
+func newRefundFixture(t *testing.T, refundErr error) *refundFixture {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Order.PaymentTimeoutMinutes = 15
+	cfg.Warehouse.AllocationStrategy = commodityService.AllocationPriority
+	cfg.DelayQueue.ProcessingTimeoutSeconds = 300
+
+	commodityStore := commodityRepository.NewMemoryStore()
+	commodityRepo := commodityRepository.NewMemoryCommodityRepository(commodityStore)
+	warehouseRepo := commodityRepository.NewMemoryWarehouseRepository(commodityStore)
+	stockRepo := commodityRepository.NewMemoryStockRepository(commodityStore)
+	stockCacheSvc := commodityService.NewStockCacheService(stockRepo, commodityRepo, warehouseRepo)
+	couponSvc := promotionService.NewCouponService(promotionRepository.NewMemoryCouponRepository(),
+		promotionRepository.NewMemoryCouponUsageRepository(), promotionRepository.NewMemoryCouponCounterRepository())
+
+	orderStore := orderRepository.NewMemoryStore()
+	oRepo := orderRepository.NewMemoryOrderRepository(orderStore)
+	eRepo := orderRepository.NewMemoryOrderEventRepository(orderStore)
+	cancelSvc := orderService.NewOrderCancelService(orderRepository.NewMemoryOrderDQRepository(cfg), oRepo, eRepo, stockRepo, couponSvc, cfg)
+	idGen, err := idgen.NewGenerator(0)
+	require.NoError(t, err)
+	orderSvc := orderService.NewOrderService(oRepo, eRepo, orderRepository.NewMemoryOrderArchiveRepository(orderStore), stockRepo,
+		commodityRepo, stockCacheSvc, commodityService.NewWarehouseService(warehouseRepo, stockCacheSvc, cfg), couponSvc,
+		promotionService.NewFlashSaleService(promotionRepository.NewMemoryFlashSaleRepository()), cancelSvc, idGen, cfg)
+
+	commodity := &commodityModel.Commodity{Name: "commodity", Price: 10, Status: true}
+	require.NoError(t, commodityRepo.CreateCommodity(commodity))
+	code, _, err := stockRepo.AdjustStock(context.Background(), commodity.ID, commodityModel.DefaultWarehouseId, 10, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, code)
+
+	order, err := orderSvc.CreateOrder(1, []orderModel.OrderItem{{CommodityId: commodity.ID, Quantity: 2}}, "", "", "address")
+	require.NoError(t, err)
+	require.NoError(t, orderSvc.MarkOrderPaid(order.Id, "payment"))
+	pRepo := repository.NewMemoryPaymentRepository()
+	require.NoError(t, pRepo.CreatePayment(&model.Payment{
+		OrderId:  order.Id,
+		OrderNo:  order.OrderNo,
+		UserId:   1,
+		Amount:   order.PayAmount,
+		Currency: order.Currency,
+		Status:   model.PaymentStatusSucceeded,
+	}))
+
+	return &refundFixture{
+		svc:         NewRefundService(repository.NewMemoryRefundRepository(), pRepo, &stubGateway{refundErr: refundErr}, orderSvc),
+		orderSvc:    orderSvc,
+		commodity:   commodityRepo,
+		order:       order,
+		commodityId: commodity.ID,
+	}
+}
+
+func TestApproveRefund(t *testing.T) {
+	tests := []struct {
+		name         string
+		quantity     int   // 申请退款的数量，0表示全额退款
+		refundErr    error // 网关退款的错误
+		wantErr      error
+		wantStatus   model.RefundStatus
+		wantOrder    orderModel.OrderStatus
+		wantRefunded int // 订单行的已退款数量
+		wantStock    int
+	}{
+		{
+			name:         "full refund",
+			wantStatus:   model.RefundStatusSucceeded,
+			wantOrder:    orderModel.StatusRefunded,
+			wantRefunded: 2,
+			wantStock:    10,
+		},
+		{
+			name:         "partial refund",
+			quantity:     1,
+			wantStatus:   model.RefundStatusSucceeded,
+			wantOrder:    orderModel.StatusPartiallyRefunded,
+			wantRefunded: 1,
+			wantStock:    9,
+		},
+		{
+			name:         "gateway failure releases the reservation",
+			refundErr:    errors.New("gateway unavailable"),
+			wantErr:      ErrRefundFailed,
+			wantStatus:   model.RefundStatusFailed,
+			wantOrder:    orderModel.StatusPaid,
+			wantRefunded: 0,
+			wantStock:    8,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newRefundFixture(t, tt.refundErr)
+			var quantities map[int]int
+			if tt.quantity > 0 {
+				quantities = map[int]int{f.commodityId: tt.quantity}
+			}
+			refund, err := f.svc.RequestRefund(1, f.order.OrderNo, quantities, "reason")
+			require.NoError(t, err)
+
+			_, err = f.svc.ApproveRefund(refund.Id, "admin", "")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			refunds, err := f.svc.GetOrderRefunds(1, f.order.OrderNo)
+			require.NoError(t, err)
+			require.Len(t, refunds, 1)
+			assert.Equal(t, tt.wantStatus, refunds[0].Status)
+
+			order, err := f.orderSvc.GetOrderById(f.order.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantOrder, order.Status)
+			assert.Equal(t, tt.wantRefunded, order.Items[0].RefundedQuantity)
+
+			commodity, err := f.commodity.FindCommodityById(f.commodityId)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStock, commodity.Stock)
+		})
+	}
+}
+
+func TestApproveRefundOnlyOnce(t *testing.T) {
+	f := newRefundFixture(t, nil)
+	refund, err := f.svc.RequestRefund(1, f.order.OrderNo, nil, "reason")
+	require.NoError(t, err)
+	_, err = f.svc.RequestRefund(1, f.order.OrderNo, nil, "reason")
+	assert.ErrorIs(t, err, ErrRefundInProgress)
+
+	approved, err := f.svc.ApproveRefund(refund.Id, "admin", "")
+	require.NoError(t, err)
+	assert.Equal(t, "RF-"+f.order.OrderNo, approved.RefundNo)
+
+	_, err = f.svc.ApproveRefund(refund.Id, "admin", "")
+	assert.ErrorIs(t, err, ErrRefundNotPending)
+	_, err = f.svc.ApproveRefund(refund.Id+1, "admin", "")
+	assert.ErrorIs(t, err, ErrRefundNotFound)
+
+	commodity, err := f.commodity.FindCommodityById(f.commodityId)
+	require.NoError(t, err)
+	assert.Equal(t, 10, commodity.Stock, "stock is restored only once")
+}
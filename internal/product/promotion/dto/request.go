@@ -0,0 +1,51 @@
+package dto
+
+import "time"
+
+// CreateCouponRequest 创建优惠券请求，金额均为十进制字符串（如"12.34"）
+type CreateCouponRequest struct {
+	Code         string    `json:"code" binding:"required,max=64"`
+	Name         string    `json:"name" binding:"required,max=255"`
+	Type         string    `json:"type" binding:"required,oneof=percentage fixed"`
+	PercentOff   int       `json:"percent_off"`    // 折扣百分比（1-100），percentage类型必填
+	AmountOff    string    `json:"amount_off"`     // 减免金额，fixed类型必填
+	MaxDiscount  string    `json:"max_discount"`   // 可选，percentage类型的最大减免金额
+	MinSpend     string    `json:"min_spend"`      // 可选，最低消费，按适用商品的小计计算
+	TotalLimit   int       `json:"total_limit"`    // 可选，全局可使用次数，0表示不限
+	PerUserLimit int       `json:"per_user_limit"` // 可选，每个用户可使用次数，0表示不限
+	CommodityIds []int     `json:"commodity_ids"`  // 可选，适用的商品ID，为空表示全部商品
+	StartsAt     time.Time `json:"starts_at" binding:"required"`
+	EndsAt       time.Time `json:"ends_at" binding:"required"`
+}
+
+// UpdateCouponRequest 启用或停用优惠券请求
+type UpdateCouponRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// ListCouponRequest 查询优惠券列表请求（Query参数）
+type ListCouponRequest struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize int `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
+
+// CreateFlashSaleRequest 创建秒杀活动请求，秒杀价为十进制字符串（如"9.90"）
+type CreateFlashSaleRequest struct {
+	CommodityId  int       `json:"commodity_id" binding:"required,min=1"`
+	SalePrice    string    `json:"sale_price" binding:"required"`
+	PerUserLimit int       `json:"per_user_limit"` // 可选，每个用户可购买的件数，0表示不限
+	Quota        int       `json:"quota"`          // 可选，活动总配额（件），0表示只受库存限制
+	StartsAt     time.Time `json:"starts_at" binding:"required"`
+	EndsAt       time.Time `json:"ends_at" binding:"required"`
+}
+
+// UpdateFlashSaleRequest 启用或停用秒杀活动请求
+type UpdateFlashSaleRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// ListFlashSaleRequest 查询秒杀活动列表请求（Query参数）
+type ListFlashSaleRequest struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`              // 页码，默认1
+	PageSize int `form:"page_size" binding:"omitempty,min=1,max=100"` // 每页条数，默认20，最大100
+}
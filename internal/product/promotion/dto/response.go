@@ -0,0 +1,23 @@
+package dto
+
+import "server/internal/product/promotion/model"
+
+// CouponResponse 优惠券响应
+type CouponResponse struct {
+	Coupon *model.Coupon `json:"coupon"`
+}
+
+// CouponListResponse 优惠券列表响应
+type CouponListResponse struct {
+	Coupons []*model.Coupon `json:"coupons"`
+}
+
+// FlashSaleResponse 秒杀活动响应
+type FlashSaleResponse struct {
+	FlashSale *model.FlashSale `json:"flash_sale"`
+}
+
+// FlashSaleListResponse 秒杀活动列表响应
+type FlashSaleListResponse struct {
+	FlashSales []*model.FlashSale `json:"flash_sales"`
+}
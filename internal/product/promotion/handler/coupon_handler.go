@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"server/internal/product/promotion/dto"
+	"server/internal/product/promotion/model"
+	"server/internal/product/promotion/service"
+	"server/pkg/money"
+	"server/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CouponHandler 处理优惠券管理相关的HTTP请求（仅管理员）
+type CouponHandler struct {
+	couponSvc *service.CouponService
+}
+
+// NewCouponHandler 创建一个新的优惠券处理器实例
+func NewCouponHandler(couponSvc *service.CouponService) *CouponHandler {
+	return &CouponHandler{couponSvc: couponSvc}
+}
+
+// CreateCoupon 处理管理员创建优惠券请求
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req dto.CreateCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	amounts := map[string]money.Amount{}
+	for name, s := range map[string]string{"amount_off": req.AmountOff, "max_discount": req.MaxDiscount, "min_spend": req.MinSpend} {
+		if s == "" {
+			continue
+		}
+		amount, err := money.Parse(s)
+		if err != nil {
+			response.BadRequest(c, response.CodeInvalidParams, "invalid "+name+": "+err.Error())
+			return
+		}
+		amounts[name] = amount
+	}
+
+	coupon := &model.Coupon{
+		Code:         req.Code,
+		Name:         req.Name,
+		Type:         model.CouponType(req.Type),
+		PercentOff:   req.PercentOff,
+		AmountOff:    amounts["amount_off"],
+		MaxDiscount:  amounts["max_discount"],
+		MinSpend:     amounts["min_spend"],
+		TotalLimit:   req.TotalLimit,
+		PerUserLimit: req.PerUserLimit,
+		CommodityIds: req.CommodityIds,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+	}
+	if err := h.couponSvc.CreateCoupon(coupon); err != nil {
+		if errors.Is(err, service.ErrInvalidCoupon) {
+			response.BadRequest(c, response.CodeInvalidCoupon, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.CouponResponse{Coupon: coupon})
+}
+
+// ListCoupons 处理管理员查询优惠券列表请求
+func (h *CouponHandler) ListCoupons(c *gin.Context) {
+	var req dto.ListCouponRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	coupons, total, err := h.couponSvc.ListCoupons((req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.CouponListResponse{Coupons: coupons}, response.NewPagination(req.Page, req.PageSize, total))
+}
+
+// UpdateCoupon 处理管理员启用或停用优惠券请求
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.UpdateCouponRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	if err = h.couponSvc.SetCouponEnabled(id, *req.Enabled); err != nil {
+		if errors.Is(err, service.ErrCouponNotFound) {
+			response.NotFound(c, response.CodeCouponNotFound, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, nil)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"server/internal/product/promotion/dto"
+	"server/internal/product/promotion/model"
+	"server/internal/product/promotion/service"
+	"server/pkg/money"
+	"server/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlashSaleHandler 处理秒杀活动管理相关的HTTP请求（仅管理员）
+type FlashSaleHandler struct {
+	flashSaleSvc *service.FlashSaleService
+}
+
+// NewFlashSaleHandler 创建一个新的秒杀活动处理器实例
+func NewFlashSaleHandler(flashSaleSvc *service.FlashSaleService) *FlashSaleHandler {
+	return &FlashSaleHandler{flashSaleSvc: flashSaleSvc}
+}
+
+// CreateFlashSale 处理管理员创建秒杀活动请求
+func (h *FlashSaleHandler) CreateFlashSale(c *gin.Context) {
+	var req dto.CreateFlashSaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	salePrice, err := money.Parse(req.SalePrice)
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid sale_price: "+err.Error())
+		return
+	}
+
+	sale := &model.FlashSale{
+		CommodityId:  req.CommodityId,
+		SalePrice:    salePrice,
+		PerUserLimit: req.PerUserLimit,
+		Quota:        req.Quota,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+	}
+	if err = h.flashSaleSvc.CreateFlashSale(sale); err != nil {
+		if errors.Is(err, service.ErrInvalidFlashSale) {
+			response.BadRequest(c, response.CodeInvalidFlashSale, err.Error())
+			return
+		}
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.Success(c, dto.FlashSaleResponse{FlashSale: sale})
+}
+
+// ListFlashSales 处理管理员查询秒杀活动列表请求
+func (h *FlashSaleHandler) ListFlashSales(c *gin.Context) {
+	var req dto.ListFlashSaleRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	sales, total, err := h.flashSaleSvc.ListFlashSales((req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		response.InternalServerError(c, response.CodeInternalError, "server busy")
+		return
+	}
+
+	response.SuccessWithPagination(c, dto.FlashSaleListResponse{FlashSales: sales}, response.NewPagination(req.Page, req.PageSize, total))
+}
+
+// UpdateFlashSale 处理管理员启用或停用秒杀活动请求
+func (h *FlashSaleHandler) UpdateFlashSale(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.BadRequest(c, response.CodeInvalidParams, "invalid id parameter")
+		return
+	}
+
+	var req dto.UpdateFlashSaleRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, response.CodeInvalidJSON, err.Error())
+		return
+	}
+
+	if err = h.flashSaleSvc.SetFlashSaleEnabled(id, *req.Enabled); err != nil {
+		switch {
+		case errors.Is(err, service.ErrFlashSaleNotFound):
+			response.NotFound(c, response.CodeFlashSaleNotFound, err.Error())
+		case errors.Is(err, service.ErrInvalidFlashSale):
+			response.BadRequest(c, response.CodeInvalidFlashSale, err.Error())
+		default:
+			response.InternalServerError(c, response.CodeInternalError, "server busy")
+		}
+		return
+	}
+
+	response.Success(c, nil)
+}
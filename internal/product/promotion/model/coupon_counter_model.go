@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// CouponCounter 优惠券使用次数计数器，仅在库存存储为database时使用（redis存储时计数保存在Redis中）
+// UserId为0的记录是优惠券的全局使用次数，其余为各用户的使用次数；过期的计数器视为不存在，下次使用时从数据库统计重建
+type CouponCounter struct {
+	CouponId int   `gorm:"primary_key;autoIncrement:false"`
+	UserId   int   `gorm:"primary_key;autoIncrement:false"`
+	Used     int64 // 已使用次数
+	ExpireAt time.Time
+}
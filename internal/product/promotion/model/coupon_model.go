@@ -0,0 +1,125 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"server/pkg/money"
+	"time"
+)
+
+// CouponType 优惠券类型
+type CouponType string
+
+const (
+	CouponTypePercentage CouponType = "percentage" // 百分比折扣，如减免15%
+	CouponTypeFixed      CouponType = "fixed"      // 固定金额减免，如减免10元
+)
+
+// Coupon 优惠券模型
+// 优惠券只对适用商品的小计生效：门槛、折扣都按适用商品的小计计算
+type Coupon struct {
+	Id           int          `gorm:"primary_key"`
+	Code         string       // 券码，买家下单时填写，全局唯一
+	Name         string       // 优惠券名称
+	Type         CouponType   // 优惠券类型
+	PercentOff   int          // 折扣百分比（1-100），仅percentage类型，如15表示减免15%
+	AmountOff    money.Amount // 减免金额（分），仅fixed类型
+	MaxDiscount  money.Amount // percentage类型的最大减免金额（分），0表示不限
+	MinSpend     money.Amount // 最低消费（分），按适用商品的小计计算，0表示不限
+	TotalLimit   int          // 全局可使用次数，0表示不限
+	PerUserLimit int          // 每个用户可使用次数，0表示不限
+	CommodityIds IdList       // 适用的商品ID，为空表示全部商品
+	StartsAt     time.Time    // 生效时间
+	EndsAt       time.Time    // 失效时间
+	Enabled      bool         // 是否启用，停用后不能再使用
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsActive 判断优惠券在指定时间是否可用（已启用且在有效期内）
+func (c *Coupon) IsActive(now time.Time) bool {
+	return c.Enabled && !now.Before(c.StartsAt) && now.Before(c.EndsAt)
+}
+
+// AppliesTo 判断优惠券是否适用于指定商品
+func (c *Coupon) AppliesTo(commodityId int) bool {
+	if len(c.CommodityIds) == 0 {
+		return true
+	}
+	for _, id := range c.CommodityIds {
+		if id == commodityId {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountFor 根据适用商品的小计计算优惠金额，优惠金额不超过小计
+func (c *Coupon) DiscountFor(eligible money.Amount) money.Amount {
+	var discount money.Amount
+	switch c.Type {
+	case CouponTypePercentage:
+		discount = eligible.MulDiv(int64(c.PercentOff), 100)
+		if c.MaxDiscount > 0 && discount > c.MaxDiscount {
+			discount = c.MaxDiscount
+		}
+	case CouponTypeFixed:
+		discount = c.AmountOff
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount
+}
+
+// IdList ID列表，以JSON数组的格式存储在一列中
+type IdList []int
+
+// Value 将ID列表序列化为JSON写入数据库
+func (l IdList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 从数据库读取JSON并反序列化为ID列表
+func (l *IdList) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("unsupported id list type %T", value)
+	}
+}
+
+// CouponUsageStatus 优惠券使用记录状态
+type CouponUsageStatus string
+
+const (
+	CouponUsageUsed     CouponUsageStatus = "used"     // 已使用（订单已创建）
+	CouponUsageReleased CouponUsageStatus = "released" // 已释放（订单取消后归还使用次数）
+)
+
+// CouponUsage 优惠券使用记录，一个订单最多使用一张优惠券
+// Redis使用次数计数器丢失时，以该表中used状态的记录数重建计数器
+type CouponUsage struct {
+	Id             int `gorm:"primary_key"`
+	CouponId       int
+	UserId         int
+	OrderId        int
+	DiscountAmount money.Amount // 优惠金额（分）
+	Status         CouponUsageStatus
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
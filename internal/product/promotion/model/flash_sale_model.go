@@ -0,0 +1,31 @@
+package model
+
+import (
+	"server/pkg/money"
+	"time"
+)
+
+// FlashSale 秒杀活动模型，一个商品同一时间最多有一个启用的秒杀活动
+// 活动期间商品按秒杀价售卖，限购和总配额在扣减库存的Lua脚本中与库存一起原子性地检查
+type FlashSale struct {
+	Id           int          `gorm:"primary_key"`
+	CommodityId  int          // 秒杀商品ID
+	SalePrice    money.Amount // 秒杀价（分）
+	PerUserLimit int          // 每个用户可购买的件数，0表示不限
+	Quota        int          // 活动总配额（件），0表示只受库存限制
+	StartsAt     time.Time    // 开始时间，开始前下单直接拒绝
+	EndsAt       time.Time    // 结束时间，结束后恢复原价售卖
+	Enabled      bool         // 是否启用，停用后立即恢复原价售卖
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// IsActive 判断秒杀活动在指定时间是否进行中（已启用且在活动时间内）
+func (f *FlashSale) IsActive(now time.Time) bool {
+	return f.Enabled && !now.Before(f.StartsAt) && now.Before(f.EndsAt)
+}
+
+// IsUpcoming 判断秒杀活动在指定时间是否已启用但尚未开始
+func (f *FlashSale) IsUpcoming(now time.Time) bool {
+	return f.Enabled && now.Before(f.StartsAt)
+}
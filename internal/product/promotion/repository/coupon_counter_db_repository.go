@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"server/internal/product/promotion/model"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type dbCouponCounterRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewDBCouponCounterRepository 创建一个基于数据库的优惠券计数器仓储实例，用于database库存存储，不依赖Redis
+// 计数器保存在coupon_counters中（user_id为0的记录为全局使用次数），语义和返回码与Redis实现一致：
+// 占用时在事务中锁定全局和用户计数器后检查上限并累加，防止并发下单超发
+func NewDBCouponCounterRepository(gDB *gorm.DB) CouponCounterRepository {
+	return &dbCouponCounterRepository{gormDB: gDB}
+}
+
+// InitCounter 初始化优惠券的全局和用户使用次数计数器
+// 已过期的计数器先删除，未过期的计数器已存在时不覆盖，避免并发初始化时丢失已占用的次数
+func (cRepo *dbCouponCounterRepository) InitCounter(ctx context.Context, couponId int, userId int, used int64, userUsed int64, ttl time.Duration) error {
+	now := time.Now()
+	err := cRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("coupon_id = ? AND user_id IN ? AND expire_at <= ?", couponId, []int{0, userId}, now).Delete(&model.CouponCounter{}).Error
+		if err != nil {
+			return err
+		}
+		counters := []*model.CouponCounter{
+			{CouponId: couponId, UserId: 0, Used: used, ExpireAt: now.Add(ttl)},
+			{CouponId: couponId, UserId: userId, Used: userUsed, ExpireAt: now.Add(ttl)},
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counters).Error
+	})
+	if err != nil {
+		log.Error("Failed to initialize coupon counter:", err)
+		return err
+	}
+	return nil
+}
+
+// ReserveUsage 在一个事务中锁定全局和用户计数器，检查使用上限后各加1
+// 返回码与Redis实现一致：0占用成功、1全局使用次数已达上限、2用户使用次数已达上限、3计数器未初始化或已过期
+// 计数器按user_id顺序加锁（全局计数器在前），并发事务以相同顺序锁定行，避免死锁
+func (cRepo *dbCouponCounterRepository) ReserveUsage(ctx context.Context, couponId int, userId int, totalLimit int, perUserLimit int) (int, error) {
+	code := 0
+	err := cRepo.gormDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var counters []model.CouponCounter
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("coupon_id = ? AND user_id IN ? AND expire_at > ?", couponId, []int{0, userId}, time.Now()).
+			Order("user_id").Find(&counters).Error
+		if err != nil {
+			return err
+		}
+		if len(counters) != 2 {
+			code = 3
+			return nil
+		}
+		used, userUsed := counters[0].Used, counters[1].Used
+		if totalLimit > 0 && used >= int64(totalLimit) {
+			code = 1
+			return nil
+		}
+		if perUserLimit > 0 && userUsed >= int64(perUserLimit) {
+			code = 2
+			return nil
+		}
+		return tx.Model(&model.CouponCounter{}).
+			Where("coupon_id = ? AND user_id IN ?", couponId, []int{0, userId}).
+			Update("used", gorm.Expr("used + 1")).Error
+	})
+	if err != nil {
+		log.Error("Failed to reserve coupon usage:", err)
+		return -1, err
+	}
+	return code, nil
+}
+
+// ReleaseUsage 归还一次使用次数，计数器不会减到0以下；计数器已过期或不存在时不做任何修改（下次使用时会从数据库重建）
+func (cRepo *dbCouponCounterRepository) ReleaseUsage(ctx context.Context, couponId int, userId int) error {
+	err := cRepo.gormDB.WithContext(ctx).Model(&model.CouponCounter{}).
+		Where("coupon_id = ? AND user_id IN ? AND used > 0 AND expire_at > ?", couponId, []int{0, userId}, time.Now()).
+		Update("used", gorm.Expr("used - 1")).Error
+	if err != nil {
+		log.Error("Failed to release coupon usage:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// couponCounter 内存中的使用次数计数器
+type couponCounter struct {
+	value    int64
+	expireAt time.Time
+}
+
+type memoryCouponCounterRepository struct {
+	mu       sync.Mutex
+	counters map[string]*couponCounter
+}
+
+// NewMemoryCouponCounterRepository 创建一个基于进程内存储的优惠券计数器仓储实例，用于memory存储模式
+// 计数器的key和语义与Redis实现一致，检查与占用在同一把锁内完成，防止并发下单超发
+func NewMemoryCouponCounterRepository() CouponCounterRepository {
+	return &memoryCouponCounterRepository{counters: make(map[string]*couponCounter)}
+}
+
+// InitCounter 初始化优惠券的全局和用户使用次数计数器，计数器已存在时不覆盖
+func (cRepo *memoryCouponCounterRepository) InitCounter(ctx context.Context, couponId int, userId int, used int64, userUsed int64, ttl time.Duration) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	expireAt := time.Now().Add(ttl)
+	for key, value := range map[string]int64{getCouponUsedKey(couponId): used, getCouponUserUsedKey(couponId, userId): userUsed} {
+		if cRepo.counter(key) == nil {
+			cRepo.counters[key] = &couponCounter{value: value, expireAt: expireAt}
+		}
+	}
+	return nil
+}
+
+// ReserveUsage 检查使用上限并占用一次使用次数，返回码与Redis实现一致：
+// 0占用成功、1全局使用次数已达上限、2用户使用次数已达上限、3计数器未初始化
+func (cRepo *memoryCouponCounterRepository) ReserveUsage(ctx context.Context, couponId int, userId int, totalLimit int, perUserLimit int) (int, error) {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	used := cRepo.counter(getCouponUsedKey(couponId))
+	userUsed := cRepo.counter(getCouponUserUsedKey(couponId, userId))
+	if used == nil || userUsed == nil {
+		return 3, nil
+	}
+	if totalLimit > 0 && used.value >= int64(totalLimit) {
+		return 1, nil
+	}
+	if perUserLimit > 0 && userUsed.value >= int64(perUserLimit) {
+		return 2, nil
+	}
+	used.value++
+	userUsed.value++
+	return 0, nil
+}
+
+// ReleaseUsage 归还一次使用次数，计数器不会减到0以下，不存在时不做任何修改
+func (cRepo *memoryCouponCounterRepository) ReleaseUsage(ctx context.Context, couponId int, userId int) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	for _, key := range []string{getCouponUsedKey(couponId), getCouponUserUsedKey(couponId, userId)} {
+		if counter := cRepo.counter(key); counter != nil && counter.value > 0 {
+			counter.value--
+		}
+	}
+	return nil
+}
+
+// counter 获取未过期的计数器，已过期的计数器会被删除，调用方需持有锁
+func (cRepo *memoryCouponCounterRepository) counter(key string) *couponCounter {
+	counter, ok := cRepo.counters[key]
+	if !ok {
+		return nil
+	}
+	if !counter.expireAt.After(time.Now()) {
+		delete(cRepo.counters, key)
+		return nil
+	}
+	return counter
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCouponCounterRepositoryReserveUsage(t *testing.T) {
+	tests := []struct {
+		name         string
+		init         bool
+		used         int64
+		userUsed     int64
+		totalLimit   int
+		perUserLimit int
+		want         int
+	}{
+		{"reserved", true, 0, 0, 10, 1, 0},
+		{"unlimited", true, 100, 100, 0, 0, 0},
+		{"total limit reached", true, 10, 0, 10, 1, 1},
+		{"per-user limit reached", true, 3, 1, 10, 1, 2},
+		{"counter not initialized", false, 0, 0, 10, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := NewMemoryCouponCounterRepository()
+			if tt.init {
+				require.NoError(t, repo.InitCounter(ctx, 1, 7, tt.used, tt.userUsed, time.Hour))
+			}
+			code, err := repo.ReserveUsage(ctx, 1, 7, tt.totalLimit, tt.perUserLimit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, code)
+		})
+	}
+}
+
+func TestMemoryCouponCounterRepositoryLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryCouponCounterRepository()
+	require.NoError(t, repo.InitCounter(ctx, 1, 7, 0, 0, time.Hour))
+
+	code, err := repo.ReserveUsage(ctx, 1, 7, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+
+	require.NoError(t, repo.InitCounter(ctx, 1, 7, 0, 0, time.Hour))
+	code, err = repo.ReserveUsage(ctx, 1, 7, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, code, "initializing again must not reset existing counters")
+
+	require.NoError(t, repo.ReleaseUsage(ctx, 1, 7))
+	require.NoError(t, repo.ReleaseUsage(ctx, 1, 7))
+	code, err = repo.ReserveUsage(ctx, 1, 7, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code, "releasing never drops the counters below zero")
+
+	require.NoError(t, repo.InitCounter(ctx, 2, 7, 0, 0, -time.Second))
+	code, err = repo.ReserveUsage(ctx, 2, 7, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, code, "expired counters must be initialized again")
+}
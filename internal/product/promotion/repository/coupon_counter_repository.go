@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// getCouponUsedKey 生成优惠券全局使用次数的Redis key
+// 格式：coupon_used_{优惠券ID}
+func getCouponUsedKey(couponId int) string {
+	return "coupon_used_" + strconv.Itoa(couponId)
+}
+
+// getCouponUserUsedKey 生成用户使用优惠券次数的Redis key
+// 格式：coupon_user_used_{优惠券ID}_{用户ID}
+func getCouponUserUsedKey(couponId int, userId int) string {
+	return "coupon_user_used_" + strconv.Itoa(couponId) + "_" + strconv.Itoa(userId)
+}
+
+// CouponCounterRepository 优惠券使用次数计数器的数据访问接口
+// 与库存缓存一样，使用Lua脚本在Redis中原子性地检查并占用使用次数，防止并发下单超发
+type CouponCounterRepository interface {
+	InitCounter(ctx context.Context, couponId int, userId int, used int64, userUsed int64, ttl time.Duration) error // 初始化使用次数计数器（已存在时不覆盖）
+	ReserveUsage(ctx context.Context, couponId int, userId int, totalLimit int, perUserLimit int) (int, error)      // 占用一次使用次数（原子操作）
+	ReleaseUsage(ctx context.Context, couponId int, userId int) error                                               // 归还一次使用次数
+}
+
+type redisCouponCounterRepository struct {
+	rDB *redis.Client
+}
+
+// NewCouponCounterRepository 创建一个新的Redis优惠券计数器仓储实例
+func NewCouponCounterRepository(rDB *redis.Client) CouponCounterRepository {
+	return &redisCouponCounterRepository{rDB: rDB}
+}
+
+// InitCounter 初始化优惠券的全局和用户使用次数计数器
+// 使用SET NX，计数器已存在时不覆盖，避免并发初始化时丢失已占用的次数
+// ttl通常为优惠券剩余有效期再加一段缓冲时间，过期后计数器自动清理
+func (cRepo *redisCouponCounterRepository) InitCounter(ctx context.Context, couponId int, userId int, used int64, userUsed int64, ttl time.Duration) error {
+	pipe := cRepo.rDB.TxPipeline()
+	pipe.SetNX(ctx, getCouponUsedKey(couponId), used, ttl)
+	pipe.SetNX(ctx, getCouponUserUsedKey(couponId, userId), userUsed, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Error("Failed to initialize coupon counter:", err)
+		return err
+	}
+	return nil
+}
+
+// ReserveUsage 使用Lua脚本原子性地检查使用上限并占用一次使用次数
+// 返回码说明：
+// - 0: 占用成功，全局和用户计数器各加1
+// - 1: 全局使用次数已达上限
+// - 2: 用户使用次数已达上限
+// - 3: 计数器未初始化，调用方需从数据库统计已使用次数并初始化后重试
+func (cRepo *redisCouponCounterRepository) ReserveUsage(ctx context.Context, couponId int, userId int, totalLimit int, perUserLimit int) (int, error) {
+	luaScript := `
+	local used = redis.call("GET", KEYS[1])
+	local user_used = redis.call("GET", KEYS[2])
+	if not used or not user_used then
+		return 3
+	end
+	local total_limit = tonumber(ARGV[1])
+	local user_limit = tonumber(ARGV[2])
+	if total_limit > 0 and tonumber(used) >= total_limit then
+		return 1
+	end
+	if user_limit > 0 and tonumber(user_used) >= user_limit then
+		return 2
+	end
+	redis.call("INCR", KEYS[1])
+	redis.call("INCR", KEYS[2])
+	return 0
+`
+	keys := []string{getCouponUsedKey(couponId), getCouponUserUsedKey(couponId, userId)}
+	result, err := cRepo.rDB.Eval(ctx, luaScript, keys, totalLimit, perUserLimit).Result()
+	if err != nil {
+		log.Error("Failed to reserve coupon usage:", err)
+		return -1, err
+	}
+	return int(result.(int64)), nil
+}
+
+// ReleaseUsage 使用Lua脚本原子性地归还一次使用次数，计数器不会减到0以下
+// 计数器已过期或不存在时不做任何修改（下次使用时会从数据库重建）
+func (cRepo *redisCouponCounterRepository) ReleaseUsage(ctx context.Context, couponId int, userId int) error {
+	luaScript := `
+	for i = 1, 2 do
+		local value = tonumber(redis.call("GET", KEYS[i]))
+		if value and value > 0 then
+			redis.call("DECR", KEYS[i])
+		end
+	end
+	return 0
+`
+	keys := []string{getCouponUsedKey(couponId), getCouponUserUsedKey(couponId, userId)}
+	if err := cRepo.rDB.Eval(ctx, luaScript, keys).Err(); err != nil {
+		log.Error("Failed to release coupon usage:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"server/pkg/memstore"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryCouponRepository struct {
+	mu      sync.RWMutex
+	coupons map[int]model.Coupon
+	lastId  int
+}
+
+// NewMemoryCouponRepository 创建一个基于进程内存储的优惠券仓储实例，用于memory存储模式
+func NewMemoryCouponRepository() CouponRepository {
+	return &memoryCouponRepository{coupons: make(map[int]model.Coupon)}
+}
+
+// CreateCoupon 创建新优惠券记录，并回写分配的优惠券ID
+func (cRepo *memoryCouponRepository) CreateCoupon(coupon *model.Coupon) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	now := time.Now()
+	if coupon.CreatedAt.IsZero() {
+		coupon.CreatedAt = now
+	}
+	if coupon.UpdatedAt.IsZero() {
+		coupon.UpdatedAt = now
+	}
+	cRepo.lastId++
+	coupon.Id = cRepo.lastId
+	cRepo.coupons[coupon.Id] = *coupon
+	return nil
+}
+
+// UpdateCouponEnabled 启用或停用优惠券，优惠券不存在时返回gorm.ErrRecordNotFound
+func (cRepo *memoryCouponRepository) UpdateCouponEnabled(couponId int, enabled bool) error {
+	cRepo.mu.Lock()
+	defer cRepo.mu.Unlock()
+	coupon, ok := cRepo.coupons[couponId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	coupon.Enabled = enabled
+	coupon.UpdatedAt = time.Now()
+	cRepo.coupons[couponId] = coupon
+	return nil
+}
+
+// FindCouponById 根据ID查找优惠券，不存在时返回gorm.ErrRecordNotFound
+func (cRepo *memoryCouponRepository) FindCouponById(couponId int) (*model.Coupon, error) {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	coupon, ok := cRepo.coupons[couponId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &coupon, nil
+}
+
+// FindCouponByCode 根据券码查找优惠券，不存在时返回gorm.ErrRecordNotFound
+func (cRepo *memoryCouponRepository) FindCouponByCode(code string) (*model.Coupon, error) {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	for _, coupon := range cRepo.coupons {
+		if coupon.Code == code {
+			return &coupon, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// FindCoupons 分页查找优惠券，按创建时间倒序，同时返回优惠券总数
+func (cRepo *memoryCouponRepository) FindCoupons(offset int, limit int) ([]*model.Coupon, int64, error) {
+	cRepo.mu.RLock()
+	defer cRepo.mu.RUnlock()
+	coupons := make([]*model.Coupon, 0, len(cRepo.coupons))
+	for _, coupon := range cRepo.coupons {
+		coupon := coupon
+		coupons = append(coupons, &coupon)
+	}
+	sort.Slice(coupons, func(i, j int) bool { return coupons[i].Id > coupons[j].Id })
+	return memstore.Paginate(coupons, offset, limit), int64(len(coupons)), nil
+}
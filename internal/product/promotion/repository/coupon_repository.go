@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CouponRepository 优惠券的数据访问接口
+type CouponRepository interface {
+	CreateCoupon(coupon *model.Coupon) error
+	UpdateCouponEnabled(couponId int, enabled bool) error
+	FindCouponById(couponId int) (*model.Coupon, error)
+	FindCouponByCode(code string) (*model.Coupon, error)
+	FindCoupons(offset int, limit int) ([]*model.Coupon, int64, error)
+}
+
+type gormCouponRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewCouponRepository 创建一个新的优惠券仓储实例
+func NewCouponRepository(gDB *gorm.DB) CouponRepository {
+	return &gormCouponRepository{gormDB: gDB}
+}
+
+// CreateCoupon 在数据库中创建新优惠券记录
+func (cRepo *gormCouponRepository) CreateCoupon(coupon *model.Coupon) error {
+	return cRepo.gormDB.Create(coupon).Error
+}
+
+// UpdateCouponEnabled 启用或停用优惠券，优惠券不存在时返回gorm.ErrRecordNotFound
+func (cRepo *gormCouponRepository) UpdateCouponEnabled(couponId int, enabled bool) error {
+	result := cRepo.gormDB.Model(&model.Coupon{}).
+		Where("id = ?", couponId).
+		Updates(map[string]interface{}{"enabled": enabled, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindCouponById 根据ID查找优惠券
+func (cRepo *gormCouponRepository) FindCouponById(couponId int) (*model.Coupon, error) {
+	var coupon model.Coupon
+	err := cRepo.gormDB.First(&coupon, couponId).Error
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// FindCouponByCode 根据券码查找优惠券
+func (cRepo *gormCouponRepository) FindCouponByCode(code string) (*model.Coupon, error) {
+	var coupon model.Coupon
+	err := cRepo.gormDB.Where("code = ?", code).First(&coupon).Error
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// FindCoupons 分页查找优惠券，按创建时间倒序，同时返回优惠券总数
+func (cRepo *gormCouponRepository) FindCoupons(offset int, limit int) ([]*model.Coupon, int64, error) {
+	var total int64
+	if err := cRepo.gormDB.Model(&model.Coupon{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var coupons []*model.Coupon
+	err := cRepo.gormDB.Order("id DESC").Offset(offset).Limit(limit).Find(&coupons).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return coupons, total, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryCouponUsageRepository struct {
+	mu     sync.RWMutex
+	usages map[int]model.CouponUsage
+	lastId int
+}
+
+// NewMemoryCouponUsageRepository 创建一个基于进程内存储的优惠券使用记录仓储实例，用于memory存储模式
+func NewMemoryCouponUsageRepository() CouponUsageRepository {
+	return &memoryCouponUsageRepository{usages: make(map[int]model.CouponUsage)}
+}
+
+// CreateUsage 创建优惠券使用记录，并回写分配的记录ID
+func (uRepo *memoryCouponUsageRepository) CreateUsage(usage *model.CouponUsage) error {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	now := time.Now()
+	if usage.CreatedAt.IsZero() {
+		usage.CreatedAt = now
+	}
+	if usage.UpdatedAt.IsZero() {
+		usage.UpdatedAt = now
+	}
+	uRepo.lastId++
+	usage.Id = uRepo.lastId
+	uRepo.usages[usage.Id] = *usage
+	return nil
+}
+
+// ReleaseUsage 将订单的优惠券使用记录从used置为released，返回被释放的使用记录
+// 订单没有使用优惠券或已经释放过时返回gorm.ErrRecordNotFound
+func (uRepo *memoryCouponUsageRepository) ReleaseUsage(orderId int) (*model.CouponUsage, error) {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	for id, usage := range uRepo.usages {
+		if usage.OrderId == orderId && usage.Status == model.CouponUsageUsed {
+			usage.Status = model.CouponUsageReleased
+			usage.UpdatedAt = time.Now()
+			uRepo.usages[id] = usage
+			return &usage, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// CountUsages 统计优惠券已使用（未释放）的次数，userId为0时统计所有用户
+func (uRepo *memoryCouponUsageRepository) CountUsages(couponId int, userId int) (int64, error) {
+	uRepo.mu.RLock()
+	defer uRepo.mu.RUnlock()
+	var count int64
+	for _, usage := range uRepo.usages {
+		if usage.CouponId == couponId && usage.Status == model.CouponUsageUsed && (userId == 0 || usage.UserId == userId) {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CouponUsageRepository 优惠券使用记录的数据访问接口
+type CouponUsageRepository interface {
+	CreateUsage(usage *model.CouponUsage) error
+	ReleaseUsage(orderId int) (*model.CouponUsage, error)
+	CountUsages(couponId int, userId int) (int64, error)
+}
+
+type gormCouponUsageRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewCouponUsageRepository 创建一个新的优惠券使用记录仓储实例
+func NewCouponUsageRepository(gDB *gorm.DB) CouponUsageRepository {
+	return &gormCouponUsageRepository{gormDB: gDB}
+}
+
+// CreateUsage 创建优惠券使用记录
+func (uRepo *gormCouponUsageRepository) CreateUsage(usage *model.CouponUsage) error {
+	return uRepo.gormDB.Create(usage).Error
+}
+
+// ReleaseUsage 以条件更新的方式将订单的优惠券使用记录从used置为released
+// 返回被释放的使用记录；订单没有使用优惠券或已经释放过时返回gorm.ErrRecordNotFound，
+// 保证同一订单的优惠券使用次数只会被归还一次
+func (uRepo *gormCouponUsageRepository) ReleaseUsage(orderId int) (*model.CouponUsage, error) {
+	var usage model.CouponUsage
+	err := uRepo.gormDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("order_id = ? AND status = ?", orderId, model.CouponUsageUsed).First(&usage).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&model.CouponUsage{}).
+			Where("id = ? AND status = ?", usage.Id, model.CouponUsageUsed).
+			Updates(map[string]interface{}{"status": model.CouponUsageReleased, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	usage.Status = model.CouponUsageReleased
+	return &usage, nil
+}
+
+// CountUsages 统计优惠券已使用（未释放）的次数，userId为0时统计所有用户
+func (uRepo *gormCouponUsageRepository) CountUsages(couponId int, userId int) (int64, error) {
+	db := uRepo.gormDB.Model(&model.CouponUsage{}).Where("coupon_id = ? AND status = ?", couponId, model.CouponUsageUsed)
+	if userId != 0 {
+		db = db.Where("user_id = ?", userId)
+	}
+	var count int64
+	err := db.Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"server/pkg/memstore"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type memoryFlashSaleRepository struct {
+	mu     sync.RWMutex
+	sales  map[int]model.FlashSale
+	lastId int
+}
+
+// NewMemoryFlashSaleRepository 创建一个基于进程内存储的秒杀活动仓储实例，用于memory存储模式
+func NewMemoryFlashSaleRepository() FlashSaleRepository {
+	return &memoryFlashSaleRepository{sales: make(map[int]model.FlashSale)}
+}
+
+// CreateFlashSale 创建新秒杀活动记录，并回写分配的活动ID
+func (fRepo *memoryFlashSaleRepository) CreateFlashSale(sale *model.FlashSale) error {
+	fRepo.mu.Lock()
+	defer fRepo.mu.Unlock()
+	now := time.Now()
+	if sale.CreatedAt.IsZero() {
+		sale.CreatedAt = now
+	}
+	if sale.UpdatedAt.IsZero() {
+		sale.UpdatedAt = now
+	}
+	fRepo.lastId++
+	sale.Id = fRepo.lastId
+	fRepo.sales[sale.Id] = *sale
+	return nil
+}
+
+// UpdateFlashSaleEnabled 启用或停用秒杀活动，活动不存在时返回gorm.ErrRecordNotFound
+func (fRepo *memoryFlashSaleRepository) UpdateFlashSaleEnabled(saleId int, enabled bool) error {
+	fRepo.mu.Lock()
+	defer fRepo.mu.Unlock()
+	sale, ok := fRepo.sales[saleId]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	sale.Enabled = enabled
+	sale.UpdatedAt = time.Now()
+	fRepo.sales[saleId] = sale
+	return nil
+}
+
+// FindFlashSaleById 根据ID查找秒杀活动，不存在时返回gorm.ErrRecordNotFound
+func (fRepo *memoryFlashSaleRepository) FindFlashSaleById(saleId int) (*model.FlashSale, error) {
+	fRepo.mu.RLock()
+	defer fRepo.mu.RUnlock()
+	sale, ok := fRepo.sales[saleId]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &sale, nil
+}
+
+// FindCurrentFlashSale 查找商品在指定时间尚未结束的启用秒杀活动（进行中或未开始），按开始时间取最早的一个
+// 没有时返回gorm.ErrRecordNotFound
+func (fRepo *memoryFlashSaleRepository) FindCurrentFlashSale(commodityId int, now time.Time) (*model.FlashSale, error) {
+	fRepo.mu.RLock()
+	defer fRepo.mu.RUnlock()
+	var current *model.FlashSale
+	for _, sale := range fRepo.sales {
+		if sale.CommodityId != commodityId || !sale.Enabled || !sale.EndsAt.After(now) {
+			continue
+		}
+		if current == nil || sale.StartsAt.Before(current.StartsAt) {
+			sale := sale
+			current = &sale
+		}
+	}
+	if current == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return current, nil
+}
+
+// CountOverlappingFlashSales 统计商品在指定时间段内与之重叠的启用秒杀活动数，excludeId为需要排除的活动（0表示不排除）
+func (fRepo *memoryFlashSaleRepository) CountOverlappingFlashSales(commodityId int, startsAt time.Time, endsAt time.Time, excludeId int) (int64, error) {
+	fRepo.mu.RLock()
+	defer fRepo.mu.RUnlock()
+	var count int64
+	for _, sale := range fRepo.sales {
+		if sale.CommodityId == commodityId && sale.Enabled && sale.StartsAt.Before(endsAt) && sale.EndsAt.After(startsAt) && sale.Id != excludeId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// FindFlashSales 分页查找秒杀活动，按创建时间倒序，同时返回活动总数
+func (fRepo *memoryFlashSaleRepository) FindFlashSales(offset int, limit int) ([]*model.FlashSale, int64, error) {
+	fRepo.mu.RLock()
+	defer fRepo.mu.RUnlock()
+	sales := make([]*model.FlashSale, 0, len(fRepo.sales))
+	for _, sale := range fRepo.sales {
+		sale := sale
+		sales = append(sales, &sale)
+	}
+	sort.Slice(sales, func(i, j int) bool { return sales[i].Id > sales[j].Id })
+	return memstore.Paginate(sales, offset, limit), int64(len(sales)), nil
+}
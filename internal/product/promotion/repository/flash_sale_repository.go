@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"server/internal/product/promotion/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FlashSaleRepository 秒杀活动的数据访问接口
+type FlashSaleRepository interface {
+	CreateFlashSale(sale *model.FlashSale) error
+	UpdateFlashSaleEnabled(saleId int, enabled bool) error
+	FindFlashSaleById(saleId int) (*model.FlashSale, error)
+	FindCurrentFlashSale(commodityId int, now time.Time) (*model.FlashSale, error)
+	CountOverlappingFlashSales(commodityId int, startsAt time.Time, endsAt time.Time, excludeId int) (int64, error)
+	FindFlashSales(offset int, limit int) ([]*model.FlashSale, int64, error)
+}
+
+type gormFlashSaleRepository struct {
+	gormDB *gorm.DB
+}
+
+// NewFlashSaleRepository 创建一个新的秒杀活动仓储实例
+func NewFlashSaleRepository(gDB *gorm.DB) FlashSaleRepository {
+	return &gormFlashSaleRepository{gormDB: gDB}
+}
+
+// CreateFlashSale 在数据库中创建新秒杀活动记录
+func (fRepo *gormFlashSaleRepository) CreateFlashSale(sale *model.FlashSale) error {
+	return fRepo.gormDB.Create(sale).Error
+}
+
+// UpdateFlashSaleEnabled 启用或停用秒杀活动，活动不存在时返回gorm.ErrRecordNotFound
+func (fRepo *gormFlashSaleRepository) UpdateFlashSaleEnabled(saleId int, enabled bool) error {
+	result := fRepo.gormDB.Model(&model.FlashSale{}).
+		Where("id = ?", saleId).
+		Updates(map[string]interface{}{"enabled": enabled, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindFlashSaleById 根据ID查找秒杀活动
+func (fRepo *gormFlashSaleRepository) FindFlashSaleById(saleId int) (*model.FlashSale, error) {
+	var sale model.FlashSale
+	if err := fRepo.gormDB.First(&sale, saleId).Error; err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// FindCurrentFlashSale 查找商品在指定时间尚未结束的启用秒杀活动（进行中或未开始），按开始时间取最早的一个
+// 没有时返回gorm.ErrRecordNotFound
+func (fRepo *gormFlashSaleRepository) FindCurrentFlashSale(commodityId int, now time.Time) (*model.FlashSale, error) {
+	var sale model.FlashSale
+	err := fRepo.gormDB.
+		Where("commodity_id = ? AND enabled = ? AND ends_at > ?", commodityId, true, now).
+		Order("starts_at ASC").
+		First(&sale).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// CountOverlappingFlashSales 统计商品在指定时间段内与之重叠的启用秒杀活动数，excludeId为需要排除的活动（0表示不排除）
+func (fRepo *gormFlashSaleRepository) CountOverlappingFlashSales(commodityId int, startsAt time.Time, endsAt time.Time, excludeId int) (int64, error) {
+	var count int64
+	err := fRepo.gormDB.Model(&model.FlashSale{}).
+		Where("commodity_id = ? AND enabled = ? AND starts_at < ? AND ends_at > ? AND id <> ?", commodityId, true, endsAt, startsAt, excludeId).
+		Count(&count).Error
+	return count, err
+}
+
+// FindFlashSales 分页查找秒杀活动，按创建时间倒序，同时返回活动总数
+func (fRepo *gormFlashSaleRepository) FindFlashSales(offset int, limit int) ([]*model.FlashSale, int64, error) {
+	var total int64
+	if err := fRepo.gormDB.Model(&model.FlashSale{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var sales []*model.FlashSale
+	err := fRepo.gormDB.Order("id DESC").Offset(offset).Limit(limit).Find(&sales).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return sales, total, nil
+}
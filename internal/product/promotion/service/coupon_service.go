@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"server/internal/product/promotion/model"
+	"server/internal/product/promotion/repository"
+	"server/pkg/money"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// counterGracePeriod 计数器在优惠券失效后的额外保留时间，覆盖失效前创建、失效后才取消的订单
+const counterGracePeriod = time.Hour * 24 * 7
+
+// ItemAmount 参与优惠计算的订单行（商品及其小计）
+type ItemAmount struct {
+	CommodityId int
+	Amount      money.Amount
+}
+
+// Discount 优惠券在订单上产生的优惠
+type Discount struct {
+	CouponId int
+	Code     string
+	Amount   money.Amount         // 优惠金额（分）
+	Items    map[int]money.Amount // 优惠金额在适用商品间的分摊（商品ID -> 分摊金额），用于按订单行退款
+}
+
+// CouponService 提供优惠券管理和下单用券相关的业务逻辑服务
+type CouponService struct {
+	couponRepo  repository.CouponRepository
+	usageRepo   repository.CouponUsageRepository
+	counterRepo repository.CouponCounterRepository
+}
+
+// NewCouponService 创建一个新的优惠券服务实例
+func NewCouponService(couponRepo repository.CouponRepository, usageRepo repository.CouponUsageRepository, counterRepo repository.CouponCounterRepository) *CouponService {
+	return &CouponService{
+		couponRepo:  couponRepo,
+		usageRepo:   usageRepo,
+		counterRepo: counterRepo,
+	}
+}
+
+// ReserveCoupon 下单时校验优惠券、计算优惠金额并占用一次使用次数
+// 业务流程：
+// 1. 根据券码查找优惠券，校验已启用且在有效期内
+// 2. 按优惠券的适用商品计算适用小计，校验最低消费
+// 3. 计算优惠金额（百分比折扣受最大减免限制，优惠金额不超过适用小计）
+// 4. 在Redis中原子性地检查全局和用户使用上限并占用次数（计数器未初始化时从数据库重建后重试）
+//
+// 占用成功后，调用方必须在订单创建成功后调用ConfirmCoupon记录使用，或在失败时调用ReleaseCoupon归还次数
+func (cs *CouponService) ReserveCoupon(userId int, code string, items []ItemAmount) (*Discount, error) {
+	coupon, err := cs.couponRepo.FindCouponByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrCouponNotFound, code)
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !coupon.IsActive(now) {
+		return nil, fmt.Errorf("%w: coupon %s is disabled or expired", ErrCouponNotApplicable, code)
+	}
+
+	var eligible money.Amount
+	for _, item := range items {
+		if coupon.AppliesTo(item.CommodityId) {
+			eligible += item.Amount
+		}
+	}
+	if eligible == 0 {
+		return nil, fmt.Errorf("%w: no eligible commodities for coupon %s", ErrCouponNotApplicable, code)
+	}
+	if eligible < coupon.MinSpend {
+		return nil, fmt.Errorf("%w: minimum spend is %s, eligible subtotal is %s", ErrCouponNotApplicable, coupon.MinSpend, eligible)
+	}
+
+	if err = cs.reserveUsage(coupon, userId, now); err != nil {
+		return nil, err
+	}
+	amount := coupon.DiscountFor(eligible)
+	return &Discount{CouponId: coupon.Id, Code: coupon.Code, Amount: amount, Items: allocateDiscount(coupon, items, eligible, amount)}, nil
+}
+
+// allocateDiscount 按适用商品的小计比例分摊优惠金额，舍入产生的余额计入最后一个适用商品，保证分摊之和等于优惠金额
+func allocateDiscount(coupon *model.Coupon, items []ItemAmount, eligible money.Amount, amount money.Amount) map[int]money.Amount {
+	allocation := make(map[int]money.Amount)
+	last := -1
+	var allocated money.Amount
+	for _, item := range items {
+		if !coupon.AppliesTo(item.CommodityId) {
+			continue
+		}
+		share := amount.MulDiv(int64(item.Amount), int64(eligible))
+		allocation[item.CommodityId] = share
+		allocated += share
+		last = item.CommodityId
+	}
+	if last >= 0 {
+		allocation[last] += amount - allocated
+	}
+	return allocation
+}
+
+// reserveUsage 占用一次优惠券使用次数，计数器未初始化时从数据库统计已使用次数后初始化并重试
+func (cs *CouponService) reserveUsage(coupon *model.Coupon, userId int, now time.Time) error {
+	ctx := context.TODO()
+	for attempt := 0; attempt < 2; attempt++ {
+		code, err := cs.counterRepo.ReserveUsage(ctx, coupon.Id, userId, coupon.TotalLimit, coupon.PerUserLimit)
+		switch code {
+		case 0: // 占用成功
+			return nil
+		case 1: // 全局使用次数已达上限
+			return fmt.Errorf("%w: %s", ErrCouponExhausted, coupon.Code)
+		case 2: // 用户使用次数已达上限
+			return fmt.Errorf("%w: %s", ErrCouponUserLimit, coupon.Code)
+		case 3: // 计数器未初始化，以数据库中的使用记录重建计数器
+			used, err := cs.usageRepo.CountUsages(coupon.Id, 0)
+			if err != nil {
+				return err
+			}
+			userUsed, err := cs.usageRepo.CountUsages(coupon.Id, userId)
+			if err != nil {
+				return err
+			}
+			ttl := coupon.EndsAt.Sub(now) + counterGracePeriod
+			if err = cs.counterRepo.InitCounter(ctx, coupon.Id, userId, used, userUsed, ttl); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+	return fmt.Errorf("failed to initialize coupon counter")
+}
+
+// ConfirmCoupon 订单创建成功后记录优惠券使用
+func (cs *CouponService) ConfirmCoupon(discount *Discount, userId int, orderId int) error {
+	now := time.Now()
+	return cs.usageRepo.CreateUsage(&model.CouponUsage{
+		CouponId:       discount.CouponId,
+		UserId:         userId,
+		OrderId:        orderId,
+		DiscountAmount: discount.Amount,
+		Status:         model.CouponUsageUsed,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+}
+
+// ReleaseCoupon 归还ReserveCoupon占用的使用次数，用于记录使用之前下单失败的回滚
+func (cs *CouponService) ReleaseCoupon(discount *Discount, userId int) {
+	if err := cs.counterRepo.ReleaseUsage(context.TODO(), discount.CouponId, userId); err != nil {
+		log.Errorf("Failed to release usage of coupon %d: %v", discount.CouponId, err)
+	}
+}
+
+// ReleaseOrderCoupon 订单取消或回滚时归还订单使用的优惠券次数
+// 先以条件更新将使用记录置为released，再归还Redis计数器，同一订单只会归还一次
+// 返回本次是否实际归还了次数（订单未使用优惠券或已归还过时返回false）
+func (cs *CouponService) ReleaseOrderCoupon(orderId int) (bool, error) {
+	usage, err := cs.usageRepo.ReleaseUsage(orderId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err = cs.counterRepo.ReleaseUsage(context.TODO(), usage.CouponId, usage.UserId); err != nil {
+		// 使用记录已释放，计数器偏大只会让优惠券提前用完；计数器过期重建后会恢复准确
+		log.Errorf("Failed to release counter of coupon %d for order %d: %v", usage.CouponId, orderId, err)
+	}
+	return true, nil
+}
+
+// CreateCoupon 创建优惠券，校验优惠券类型与参数是否匹配
+func (cs *CouponService) CreateCoupon(coupon *model.Coupon) error {
+	switch coupon.Type {
+	case model.CouponTypePercentage:
+		if coupon.PercentOff < 1 || coupon.PercentOff > 100 {
+			return fmt.Errorf("%w: percent off must be between 1 and 100", ErrInvalidCoupon)
+		}
+	case model.CouponTypeFixed:
+		if coupon.AmountOff <= 0 {
+			return fmt.Errorf("%w: amount off must be positive", ErrInvalidCoupon)
+		}
+	default:
+		return fmt.Errorf("%w: unknown coupon type %s", ErrInvalidCoupon, coupon.Type)
+	}
+	if !coupon.EndsAt.After(coupon.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidCoupon)
+	}
+	if coupon.TotalLimit < 0 || coupon.PerUserLimit < 0 || coupon.MinSpend < 0 || coupon.MaxDiscount < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidCoupon)
+	}
+	if _, err := cs.couponRepo.FindCouponByCode(coupon.Code); err == nil {
+		return fmt.Errorf("%w: code %s already exists", ErrInvalidCoupon, coupon.Code)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now()
+	coupon.Enabled = true
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+	return cs.couponRepo.CreateCoupon(coupon)
+}
+
+// SetCouponEnabled 启用或停用优惠券，停用后已下单的订单不受影响
+func (cs *CouponService) SetCouponEnabled(couponId int, enabled bool) error {
+	err := cs.couponRepo.UpdateCouponEnabled(couponId, enabled)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCouponNotFound
+	}
+	return err
+}
+
+// ListCoupons 分页获取优惠券，同时返回优惠券总数
+func (cs *CouponService) ListCoupons(offset int, limit int) ([]*model.Coupon, int64, error) {
+	return cs.couponRepo.FindCoupons(offset, limit)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"server/internal/product/promotion/model"
+	"server/pkg/money"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllocateDiscount(t *testing.T) {
+	tests := []struct {
+		name   string
+		coupon *model.Coupon
+		items  []ItemAmount
+		amount money.Amount
+		want   map[int]money.Amount
+	}{
+		{
+			name:   "single item takes the whole discount",
+			coupon: &model.Coupon{},
+			items:  []ItemAmount{{CommodityId: 1, Amount: 5000}},
+			amount: 500,
+			want:   map[int]money.Amount{1: 500},
+		},
+		{
+			name:   "proportional to subtotal",
+			coupon: &model.Coupon{},
+			items:  []ItemAmount{{CommodityId: 1, Amount: 3000}, {CommodityId: 2, Amount: 1000}},
+			amount: 400,
+			want:   map[int]money.Amount{1: 300, 2: 100},
+		},
+		{
+			name:   "rounding remainder goes to the last eligible item",
+			coupon: &model.Coupon{},
+			items:  []ItemAmount{{CommodityId: 1, Amount: 100}, {CommodityId: 2, Amount: 100}, {CommodityId: 3, Amount: 100}},
+			amount: 100,
+			want:   map[int]money.Amount{1: 33, 2: 33, 3: 34},
+		},
+		{
+			name:   "ineligible items get nothing",
+			coupon: &model.Coupon{CommodityIds: model.IdList{2, 3}},
+			items:  []ItemAmount{{CommodityId: 1, Amount: 9000}, {CommodityId: 2, Amount: 1000}, {CommodityId: 3, Amount: 2000}},
+			amount: 1000,
+			want:   map[int]money.Amount{2: 333, 3: 667},
+		},
+		{
+			name:   "zero discount",
+			coupon: &model.Coupon{},
+			items:  []ItemAmount{{CommodityId: 1, Amount: 1000}, {CommodityId: 2, Amount: 1000}},
+			amount: 0,
+			want:   map[int]money.Amount{1: 0, 2: 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var eligible money.Amount
+			for _, item := range tt.items {
+				if tt.coupon.AppliesTo(item.CommodityId) {
+					eligible += item.Amount
+				}
+			}
+			got := allocateDiscount(tt.coupon, tt.items, eligible, tt.amount)
+			assert.Equal(t, tt.want, got)
+
+			var total money.Amount
+			for _, share := range got {
+				total += share
+			}
+			assert.Equal(t, tt.amount, total, "allocation must add up to the discount")
+		})
+	}
+}
+
+func TestCouponDiscountFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		coupon   model.Coupon
+		eligible money.Amount
+		want     money.Amount
+	}{
+		{"percentage", model.Coupon{Type: model.CouponTypePercentage, PercentOff: 15}, 1999, 299},
+		{"percentage capped", model.Coupon{Type: model.CouponTypePercentage, PercentOff: 50, MaxDiscount: 1000}, 5000, 1000},
+		{"fixed", model.Coupon{Type: model.CouponTypeFixed, AmountOff: 500}, 2000, 500},
+		{"fixed larger than subtotal", model.Coupon{Type: model.CouponTypeFixed, AmountOff: 500}, 300, 300},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.coupon.DiscountFor(tt.eligible), tt.name)
+	}
+}
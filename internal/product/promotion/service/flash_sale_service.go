@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"server/internal/product/promotion/model"
+	"server/internal/product/promotion/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FlashSaleService 提供秒杀活动管理和下单时查询秒杀活动的业务逻辑服务
+// 限购和总配额的计数器与库存一起在扣减库存的Lua脚本中检查，见commodity/repository.PurchaseLimit
+type FlashSaleService struct {
+	saleRepo repository.FlashSaleRepository
+}
+
+// NewFlashSaleService 创建一个新的秒杀活动服务实例
+func NewFlashSaleService(saleRepo repository.FlashSaleRepository) *FlashSaleService {
+	return &FlashSaleService{saleRepo: saleRepo}
+}
+
+// CurrentFlashSale 下单时查询商品在指定时间进行中的秒杀活动，没有进行中的活动时返回nil
+// 商品有已启用但尚未开始的活动时返回ErrFlashSaleNotStarted，在扣减库存之前直接拒绝下单
+func (fs *FlashSaleService) CurrentFlashSale(commodityId int, now time.Time) (*model.FlashSale, error) {
+	sale, err := fs.saleRepo.FindCurrentFlashSale(commodityId, now)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if sale.IsUpcoming(now) {
+		return nil, fmt.Errorf("%w: commodity %d, starts at %s", ErrFlashSaleNotStarted, commodityId, sale.StartsAt.Format(time.RFC3339))
+	}
+	return sale, nil
+}
+
+// CounterExpireAt 秒杀活动限购计数器的过期时间，覆盖活动期间创建、活动结束后才取消的订单
+func (fs *FlashSaleService) CounterExpireAt(sale *model.FlashSale) time.Time {
+	return sale.EndsAt.Add(counterGracePeriod)
+}
+
+// CreateFlashSale 创建秒杀活动，同一商品的启用活动时间段不能重叠
+func (fs *FlashSaleService) CreateFlashSale(sale *model.FlashSale) error {
+	if sale.SalePrice <= 0 {
+		return fmt.Errorf("%w: sale price must be positive", ErrInvalidFlashSale)
+	}
+	if !sale.EndsAt.After(sale.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidFlashSale)
+	}
+	if !sale.EndsAt.After(time.Now()) {
+		return fmt.Errorf("%w: ends_at must be in the future", ErrInvalidFlashSale)
+	}
+	if sale.PerUserLimit < 0 || sale.Quota < 0 {
+		return fmt.Errorf("%w: limits must not be negative", ErrInvalidFlashSale)
+	}
+	if err := fs.checkOverlap(sale); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	sale.Enabled = true
+	sale.CreatedAt = now
+	sale.UpdatedAt = now
+	return fs.saleRepo.CreateFlashSale(sale)
+}
+
+// SetFlashSaleEnabled 启用或停用秒杀活动，重新启用时同样校验与其他启用活动的时间段不重叠
+// 停用后立即恢复原价售卖，已下单的订单不受影响
+func (fs *FlashSaleService) SetFlashSaleEnabled(saleId int, enabled bool) error {
+	if enabled {
+		sale, err := fs.saleRepo.FindFlashSaleById(saleId)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFlashSaleNotFound
+			}
+			return err
+		}
+		if err = fs.checkOverlap(sale); err != nil {
+			return err
+		}
+	}
+	err := fs.saleRepo.UpdateFlashSaleEnabled(saleId, enabled)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFlashSaleNotFound
+	}
+	return err
+}
+
+// ListFlashSales 分页获取秒杀活动，同时返回活动总数
+func (fs *FlashSaleService) ListFlashSales(offset int, limit int) ([]*model.FlashSale, int64, error) {
+	return fs.saleRepo.FindFlashSales(offset, limit)
+}
+
+// checkOverlap 校验秒杀活动与同一商品的其他启用活动时间段不重叠
+func (fs *FlashSaleService) checkOverlap(sale *model.FlashSale) error {
+	count, err := fs.saleRepo.CountOverlappingFlashSales(sale.CommodityId, sale.StartsAt, sale.EndsAt, sale.Id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: commodity %d already has a flash sale in this period", ErrInvalidFlashSale, sale.CommodityId)
+	}
+	return nil
+}
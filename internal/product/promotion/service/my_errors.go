@@ -0,0 +1,35 @@
+package service
+
+import "errors"
+
+var (
+	// ErrCouponNotFound 券码不存在
+	ErrCouponNotFound = errors.New("coupon not found")
+
+	// ErrCouponNotApplicable 优惠券不可用于本订单（已停用、不在有效期内、没有适用商品或未达到最低消费）
+	ErrCouponNotApplicable = errors.New("coupon not applicable")
+
+	// ErrCouponExhausted 优惠券全局使用次数已达上限
+	ErrCouponExhausted = errors.New("coupon usage limit reached")
+
+	// ErrCouponUserLimit 用户使用该优惠券的次数已达上限
+	ErrCouponUserLimit = errors.New("coupon per-user limit reached")
+
+	// ErrInvalidCoupon 创建优惠券的参数无效
+	ErrInvalidCoupon = errors.New("invalid coupon")
+
+	// ErrFlashSaleNotFound 秒杀活动不存在
+	ErrFlashSaleNotFound = errors.New("flash sale not found")
+
+	// ErrFlashSaleNotStarted 商品的秒杀活动尚未开始，开始前不接受下单
+	ErrFlashSaleNotStarted = errors.New("flash sale not started")
+
+	// ErrFlashSaleSoldOut 秒杀活动的总配额已售完
+	ErrFlashSaleSoldOut = errors.New("flash sale sold out")
+
+	// ErrFlashSaleUserLimit 用户在秒杀活动中的购买件数已达上限
+	ErrFlashSaleUserLimit = errors.New("flash sale per-user limit reached")
+
+	// ErrInvalidFlashSale 创建秒杀活动的参数无效
+	ErrInvalidFlashSale = errors.New("invalid flash sale")
+)
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"server/config"
+	"server/internal/product/order/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OrderArchiveScheduler 订单归档调度器，定时将超过保留期的已完成/已取消订单迁移到归档表
+// 工作原理：
+// 1. 每小时扫描一次orders表
+// 2. 最后更新时间早于（当前时间 - 保留天数）的已完成/已取消订单（包括买家已删除的）被迁移到archived_orders
+// 3. 订单行以JSON快照的形式随订单头一起归档，原记录被物理删除
+//
+// 保留天数通过配置order.archiveRetentionDays设置，默认90天
+type OrderArchiveScheduler struct {
+	archiveSvc *service.OrderArchiveService
+	retention  time.Duration
+	stopChan   chan struct{}
+}
+
+// NewOrderArchiveScheduler 创建一个新的订单归档调度器实例
+func NewOrderArchiveScheduler(archiveSvc *service.OrderArchiveService, cfg *config.Config) *OrderArchiveScheduler {
+	return &OrderArchiveScheduler{
+		archiveSvc: archiveSvc,
+		retention:  time.Duration(cfg.Order.ArchiveRetentionDays) * time.Hour * 24,
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// Start 启动调度器，每小时归档一次超过保留期的订单
+// 注意：
+// - 此方法会阻塞，应在goroutine中运行
+// - 归档失败只记录日志，不会停止调度器，下次触发时会重试未归档的订单
+func (s *OrderArchiveScheduler) Start() error {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	log.Infof("Order archive scheduler started, retention: %s", s.retention)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.archiveSvc.ArchiveOrdersBefore(time.Now().Add(-s.retention)); err != nil {
+				log.Error("Order archive failed:", err)
+			}
+		case <-s.stopChan:
+			log.Info("Order archive scheduler stopped")
+			return nil
+		}
+	}
+}
+
+// Stop 停止调度器
+func (s *OrderArchiveScheduler) Stop() {
+	close(s.stopChan)
+}
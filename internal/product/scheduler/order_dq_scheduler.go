@@ -11,13 +11,13 @@ import (
 // 工作原理：
 // 1. 每10秒扫描一次Redis延迟队列（ZSet）
 // 2. 获取所有到期的订单任务（score小于当前时间戳）
-// 3. 对每个到期订单：归还库存到Redis、从延迟队列移除任务
+// 3. 对每个到期订单：未支付则取消订单并归还库存到Redis，已支付则跳过，最后从延迟队列移除任务
 // 4. 记录处理日志
 //
 // 设计思想：
-// - 订单创建时加入延迟队列，15分钟后到期
+// - 订单创建时加入延迟队列，支付时限（默认15分钟）后到期
 // - 调度器定时扫描到期任务，自动取消未支付订单
-// - 归还库存只操作Redis，不影响已创建的订单记录
+// - 取消前会检查订单当前状态，已支付的订单不会被取消
 type OrderDQScheduler struct {
 	oCancelScheduler service.OrderCancelService // 订单取消服务
 	stopChan         chan struct{}               // 停止信号channel
@@ -2,10 +2,9 @@ package scheduler
 
 import (
 	"context"
-	myRedis "server/pkg/redis"
+	"server/internal/product/order/repository"
 	"time"
 
-	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -27,14 +26,14 @@ import (
 // - dq:processing: 处理中队列（本调度器负责恢复）
 // - dq:payload:{id}: 任务数据
 type RecoveryScheduler struct {
-	redisDB  *redis.Client
+	dqRepo   repository.OrderDQRepository
 	stopChan chan struct{}
 }
 
 // NewRecoveryScheduler 创建一个新的恢复调度器实例
-func NewRecoveryScheduler(redisDB *redis.Client) *RecoveryScheduler {
+func NewRecoveryScheduler(dqRepo repository.OrderDQRepository) *RecoveryScheduler {
 	return &RecoveryScheduler{
-		redisDB:  redisDB,
+		dqRepo:   dqRepo,
 		stopChan: make(chan struct{}),
 	}
 }
@@ -61,8 +60,8 @@ func (s *RecoveryScheduler) Start() error {
 		select {
 		case <-ticker.C:
 			// 定时器触发，恢复超时任务
-			// 参数60表示恢复的任务延迟60秒后重试
-			recovered, err := myRedis.RecoverTimedOutTasks(context.Background(), s.redisDB, 60)
+			// 恢复的任务延迟60秒后重试
+			recovered, err := s.dqRepo.RecoverTimedOutTasks(context.Background(), time.Second*60)
 			if err != nil {
 				log.Error("Recovery failed:", err)
 			} else if recovered > 0 {
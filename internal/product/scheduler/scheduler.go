@@ -35,7 +35,7 @@ func NewScheduler(cStockSvc *service.StockCacheService) *Scheduler {
 // 业务流程：
 // 1. 创建10秒定时器
 // 2. 每10秒触发一次库存同步
-// 3. 调用StockCacheService.SyncAllStock批量同步所有有变化的库存
+// 3. 调用StockCacheService.SyncAllStock批量同步所有有变化的库存，再调用FlushMovements将发件箱中的库存流水写入数据库
 // 4. 记录同步次数和错误日志
 // 5. 监听stopChan信号，收到信号后优雅退出
 //
@@ -57,6 +57,9 @@ func (s *Scheduler) Start() error {
 			if err := s.cStockSvc.SyncAllStock(context.Background()); err != nil {
 				log.Error("Stock sync failed:", err)
 			}
+			if err := s.cStockSvc.FlushMovements(context.Background()); err != nil {
+				log.Error("Stock movement flush failed:", err)
+			}
 			count++
 			log.Info("Stock sync scheduler finished", " count:", count)
 		case <-s.stopChan:
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"server/config"
+	"server/internal/product/commodity/service"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StockReconcileScheduler 库存对账调度器，定时对比Redis库存缓存与MySQL库存
+// 工作原理：
+// 1. 每隔配置的时间（stock.reconcileIntervalMinutes，默认10分钟）对账一次所有商品
+// 2. 发现的差异记录到日志中
+// 3. stock.reconcileRepair为true时在商品库存锁内自动修复Redis库存缓存
+//
+// 管理员也可以通过/admin/stock/reconcile接口按需触发对账
+type StockReconcileScheduler struct {
+	reconcileSvc *service.StockReconcileService
+	interval     time.Duration
+	repair       bool
+	stopChan     chan struct{}
+}
+
+// NewStockReconcileScheduler 创建一个新的库存对账调度器实例
+func NewStockReconcileScheduler(reconcileSvc *service.StockReconcileService, cfg *config.Config) *StockReconcileScheduler {
+	return &StockReconcileScheduler{
+		reconcileSvc: reconcileSvc,
+		interval:     time.Duration(cfg.Stock.ReconcileIntervalMinutes) * time.Minute,
+		repair:       cfg.Stock.ReconcileRepair,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start 启动调度器，按配置的间隔对账库存
+// 注意：
+// - 此方法会阻塞，应在goroutine中运行
+// - 对账失败只记录日志，不会停止调度器
+func (s *StockReconcileScheduler) Start() error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	log.Infof("Stock reconcile scheduler started, interval: %s, repair: %t", s.interval, s.repair)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.reconcileSvc.ReconcileStock(context.Background(), s.repair); err != nil {
+				log.Error("Stock reconcile failed:", err)
+			}
+		case <-s.stopChan:
+			log.Info("Stock reconcile scheduler stopped")
+			return nil
+		}
+	}
+}
+
+// Stop 停止调度器
+func (s *StockReconcileScheduler) Stop() {
+	close(s.stopChan)
+}
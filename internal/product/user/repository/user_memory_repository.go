@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"server/internal/product/user/model"
+	"sync"
+	"time"
+)
+
+type memoryUserRepository struct {
+	mu      sync.RWMutex
+	users   map[int]model.User
+	lastUid int
+}
+
+// NewMemoryUserRepository 创建一个基于进程内存储的用户仓储实例，用于memory存储模式
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{users: make(map[int]model.User)}
+}
+
+// CreateUser 创建新用户记录，并回写分配的用户ID
+func (uRepo *memoryUserRepository) CreateUser(user *model.User) error {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	uRepo.lastUid++
+	user.Uid = uRepo.lastUid
+	uRepo.users[user.Uid] = *user
+	return nil
+}
+
+// DeleteUser 根据用户ID删除用户记录
+func (uRepo *memoryUserRepository) DeleteUser(uid int) error {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	delete(uRepo.users, uid)
+	return nil
+}
+
+// UpdatePassword 更新用户密码
+func (uRepo *memoryUserRepository) UpdatePassword(uid int, password string) error {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	if user, ok := uRepo.users[uid]; ok {
+		user.Password = password
+		uRepo.users[uid] = user
+	}
+	return nil
+}
+
+// UpdateName 更新用户名称
+func (uRepo *memoryUserRepository) UpdateName(uid int, name string) error {
+	uRepo.mu.Lock()
+	defer uRepo.mu.Unlock()
+	if user, ok := uRepo.users[uid]; ok {
+		user.Name = name
+		uRepo.users[uid] = user
+	}
+	return nil
+}
+
+// FindUserByUid 根据用户ID查找用户，与GORM的Find一致，用户不存在时返回零值用户而不是错误
+func (uRepo *memoryUserRepository) FindUserByUid(uid int) (*model.User, error) {
+	uRepo.mu.RLock()
+	defer uRepo.mu.RUnlock()
+	user := uRepo.users[uid]
+	return &user, nil
+}
+
+// FindUserByAccount 根据账号查找用户，用户不存在时返回零值用户而不是错误
+func (uRepo *memoryUserRepository) FindUserByAccount(account string) (*model.User, error) {
+	uRepo.mu.RLock()
+	defer uRepo.mu.RUnlock()
+	for _, user := range uRepo.users {
+		if user.Account == account {
+			return &user, nil
+		}
+	}
+	return &model.User{}, nil
+}
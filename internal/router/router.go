@@ -1,11 +1,15 @@
 package router
 
 import (
+	"server/config"
 	"server/internal/middleware"
 	cartHandler "server/internal/product/cart/handler"
 	commodityHandler "server/internal/product/commodity/handler"
 	orderHandler "server/internal/product/order/handler"
+	paymentHandler "server/internal/product/payment/handler"
+	promotionHandler "server/internal/product/promotion/handler"
 	userHandler "server/internal/product/user/handler"
+	"server/pkg/idempotency"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,12 +18,16 @@ import (
 var secret = []byte("gee")
 
 // RegisterRoutes 注册所有API路由
-func RegisterRoutes(r *gin.Engine, uHandler *userHandler.UserHandler, cHandler *commodityHandler.CommodityHandler, caHandler *cartHandler.CartHandler, oHandler *orderHandler.OrderHandler) {
+func RegisterRoutes(r *gin.Engine, uHandler *userHandler.UserHandler, cHandler *commodityHandler.CommodityHandler, caHandler *cartHandler.CartHandler, oHandler *orderHandler.OrderHandler, oaHandler *orderHandler.OrderArchiveHandler, pHandler *paymentHandler.PaymentHandler, rHandler *paymentHandler.RefundHandler, cpHandler *promotionHandler.CouponHandler, fsHandler *promotionHandler.FlashSaleHandler, sHandler *commodityHandler.StockHandler, wHandler *commodityHandler.WarehouseHandler, idemStore idempotency.Store, cfg *config.Config) {
 	v1 := r.Group("/v1")
 	v1.POST("/login", uHandler.Login)
 	v1.POST("/register", uHandler.Register)
+	// 支付网关回调不携带JWT，通过X-Signature签名认证
+	v1.POST("/payment/callback", pHandler.PaymentCallback)
 	auth := v1.Group("/")
 	auth.Use(middleware.AuthMiddleWare(secret))
+	// 创建订单类接口支持Idempotency-Key，防止客户端重试导致重复下单
+	idempotent := middleware.IdempotencyMiddleWare(idemStore)
 
 	auth.POST("/commodity", cHandler.CreateCommodity)
 	auth.PUT("/commodity/:id", cHandler.UpdateCommodity)
@@ -31,9 +39,41 @@ func RegisterRoutes(r *gin.Engine, uHandler *userHandler.UserHandler, cHandler *
 	auth.DELETE("/cart/:id", caHandler.RemoveFromCart)
 	auth.PUT("/cart/:id", caHandler.UpdateCart)
 	auth.GET("/cart", caHandler.GetCart)
+	auth.POST("/cart/checkout", idempotent, caHandler.Checkout)
 
-	auth.POST("/order", oHandler.CreateOrder)
-	auth.PUT("/order/:id", oHandler.UpdateOrderStatus)
-	auth.DELETE("/order/:id", oHandler.DeleteOrder)
-	auth.GET("/order/:id", oHandler.GetOrder)
+	auth.POST("/order", idempotent, oHandler.CreateOrder)
+	auth.GET("/order", oHandler.ListOrders)
+	auth.PUT("/order/:order_no", oHandler.UpdateOrderStatus)
+	auth.DELETE("/order/:order_no", oHandler.DeleteOrder)
+	auth.GET("/order/:order_no", oHandler.GetOrder)
+	auth.POST("/order/:order_no/cancel", oHandler.CancelOrder)
+	auth.GET("/order/:order_no/events", oHandler.ListOrderEvents)
+	auth.GET("/order/:order_no/payment-deadline", oHandler.GetPaymentDeadline)
+	auth.POST("/order/:order_no/pay", idempotent, pHandler.PayOrder)
+	auth.POST("/order/:order_no/refund", idempotent, rHandler.RequestRefund)
+	auth.GET("/order/:order_no/refund", rHandler.ListOrderRefunds)
+
+	// 管理员接口，只允许配置中的管理员账号访问
+	admin := auth.Group("/admin")
+	admin.Use(middleware.AdminMiddleWare(cfg.Admin.Accounts))
+	admin.GET("/order/:order_no/events", oHandler.AdminListOrderEvents)
+	admin.PUT("/order/:order_no/status", oHandler.AdminUpdateOrderStatus)
+	admin.GET("/archived-order", oaHandler.ListArchivedOrders)
+	admin.GET("/archived-order/:order_no", oaHandler.GetArchivedOrder)
+	admin.GET("/refund", rHandler.ListRefunds)
+	admin.POST("/refund/:id/approve", rHandler.ApproveRefund)
+	admin.POST("/refund/:id/reject", rHandler.RejectRefund)
+	admin.POST("/coupon", cpHandler.CreateCoupon)
+	admin.GET("/coupon", cpHandler.ListCoupons)
+	admin.PUT("/coupon/:id", cpHandler.UpdateCoupon)
+	admin.POST("/flash-sale", fsHandler.CreateFlashSale)
+	admin.GET("/flash-sale", fsHandler.ListFlashSales)
+	admin.PUT("/flash-sale/:id", fsHandler.UpdateFlashSale)
+	admin.POST("/commodity/:id/stock/adjust", sHandler.AdjustStock)
+	admin.POST("/stock/reconcile", sHandler.ReconcileStock)
+	admin.GET("/commodity/:id/stock-movements", sHandler.ListStockMovements)
+	admin.POST("/warehouse", wHandler.CreateWarehouse)
+	admin.GET("/warehouse", wHandler.ListWarehouses)
+	admin.PUT("/warehouse/:id", wHandler.UpdateWarehouse)
+	admin.GET("/commodity/:id/warehouse-stock", wHandler.ListCommodityStocks)
 }
@@ -1,26 +1,28 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"os"
 	"os/signal"
 	"server/config"
 	cartHandler "server/internal/product/cart/handler"
 	commodityHandler "server/internal/product/commodity/handler"
+	commodityService "server/internal/product/commodity/service"
 	orderHandler "server/internal/product/order/handler"
+	paymentHandler "server/internal/product/payment/handler"
+	promotionHandler "server/internal/product/promotion/handler"
 	"server/internal/product/scheduler"
 	userHandler "server/internal/product/user/handler"
 	"syscall"
 
 	"server/internal/router"
 	"server/pkg/container"
+	"server/pkg/idempotency"
 	"server/pkg/logger"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/redis/go-redis/v9"
 	log "github.com/sirupsen/logrus"
-	"gorm.io/gorm"
 )
 
 // main 主函数，应用程序入口
@@ -41,39 +43,44 @@ func main() {
 	// 参数列表中的所有对象都由dig容器自动提供
 	err := c.Invoke(func(
 		cfg *config.Config,                        // 配置对象
-		rdb *redis.Client,                         // Redis客户端
-		gormDB *gorm.DB,                           // GORM数据库连接
+		storage container.Storage,                 // 存储连接（memory存储模式下为空）
 		r *gin.Engine,                             // Gin Web引擎
 		uHandler *userHandler.UserHandler,         // 用户Handler
 		cHandler *commodityHandler.CommodityHandler, // 商品Handler
 		caHandler *cartHandler.CartHandler,        // 购物车Handler
 		oHandler *orderHandler.OrderHandler,       // 订单Handler
+		oaHandler *orderHandler.OrderArchiveHandler, // 归档订单Handler
+		pHandler *paymentHandler.PaymentHandler,   // 支付Handler
+		rHandler *paymentHandler.RefundHandler,    // 退款Handler
+		cpHandler *promotionHandler.CouponHandler, // 优惠券Handler
+		fsHandler *promotionHandler.FlashSaleHandler, // 秒杀活动Handler
+		sHandler *commodityHandler.StockHandler,   // 库存管理Handler
+		wHandler *commodityHandler.WarehouseHandler, // 仓库管理Handler
 		stockScheduler *scheduler.Scheduler,       // 库存同步调度器
 		orderDQScheduler *scheduler.OrderDQScheduler, // 订单延迟队列调度器
 		recoveryScheduler *scheduler.RecoveryScheduler, // 超时任务恢复调度器
+		archiveScheduler *scheduler.OrderArchiveScheduler, // 订单归档调度器
+		reconcileScheduler *scheduler.StockReconcileScheduler, // 库存对账调度器
+		idemStore idempotency.Store,               // 幂等性记录存储
+		stockCacheSvc *commodityService.StockCacheService, // 库存缓存服务
 	) error {
 		// 1. 初始化日志系统（根据配置文件设置日志级别）
 		logger.InitLogger(cfg.Logger.Level)
 
-		// 2. 获取底层SQL DB连接，用于资源清理
-		sqlDB, err := gormDB.DB()
-		if err != nil {
-			return err
-		}
-		// 注册数据库连接的清理函数（程序退出时自动调用）
-		defer func(sqlDB *sql.DB) {
-			err = sqlDB.Close()
-			if err != nil {
-				log.Error("Failed to close database:", err)
-			}
-		}(sqlDB)
+		// 2. 注册存储连接的清理函数（程序退出时自动调用）
+		defer storage.Close()
 
 		// 3. 配置Gin中间件
 		r.Use(gin.LoggerWithWriter(log.StandardLogger().Out)) // 使用logrus作为日志输出
 		r.Use(gin.Recovery())                                 // panic恢复中间件
 
 		// 4. 注册所有HTTP路由（包括公开路由和需要认证的路由）
-		router.RegisterRoutes(r, uHandler, cHandler, caHandler, oHandler)
+		router.RegisterRoutes(r, uHandler, cHandler, caHandler, oHandler, oaHandler, pHandler, rHandler, cpHandler, fsHandler, sHandler, wHandler, idemStore, cfg)
+
+		// 4.1 预热库存缓存：将所有上架商品的库存加载到Redis，避免首批订单并发回源MySQL
+		if err := stockCacheSvc.WarmUpStockCache(context.Background()); err != nil {
+			log.Error("Failed to warm up stock cache:", err)
+		}
 
 		// 5. 启动库存同步调度器（在独立goroutine中运行）
 		// 作用：每10秒将Redis中的库存变化批量同步到MySQL
@@ -85,7 +92,7 @@ func main() {
 		}()
 
 		// 6. 启动订单延迟队列调度器（在独立goroutine中运行）
-		// 作用：每10秒扫描超时订单（15分钟未支付），自动取消并归还库存
+		// 作用：每10秒扫描超时订单（超过支付时限未支付），自动取消并归还库存
 		go func() {
 			log.Info("Starting Order DQ Scheduler...")
 			if err := orderDQScheduler.Start(); err != nil {
@@ -103,6 +110,24 @@ func main() {
 			}
 		}()
 
+		// 7.1 启动订单归档调度器（在独立goroutine中运行）
+		// 作用：每小时将超过保留期的已完成/已取消订单迁移到归档表
+		go func() {
+			log.Info("Starting Order Archive Scheduler...")
+			if err := archiveScheduler.Start(); err != nil {
+				log.Error("Order Archive Scheduler error:", err)
+			}
+		}()
+
+		// 7.2 启动库存对账调度器（在独立goroutine中运行）
+		// 作用：定时对比Redis库存缓存与MySQL库存，报告差异并按配置自动修复
+		go func() {
+			log.Info("Starting Stock Reconcile Scheduler...")
+			if err := reconcileScheduler.Start(); err != nil {
+				log.Error("Stock Reconcile Scheduler error:", err)
+			}
+		}()
+
 		// 8. 设置系统信号监听（用于优雅关闭）
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM) // 监听Ctrl+C和kill信号
@@ -122,6 +147,8 @@ func main() {
 		// 10. 优雅关闭：停止调度器
 		stockScheduler.Stop()
 		recoveryScheduler.Stop()
+		archiveScheduler.Stop()
+		reconcileScheduler.Stop()
 		// TODO: 也应该停止orderDQScheduler（需要添加Stop方法）
 
 		log.Info("Server stopped")
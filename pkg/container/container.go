@@ -11,11 +11,20 @@ import (
 	orderHandler "server/internal/product/order/handler"
 	orderRepo "server/internal/product/order/repository"
 	orderService "server/internal/product/order/service"
+	paymentGateway "server/internal/product/payment/gateway"
+	paymentHandler "server/internal/product/payment/handler"
+	paymentRepo "server/internal/product/payment/repository"
+	paymentService "server/internal/product/payment/service"
+	promotionHandler "server/internal/product/promotion/handler"
+	promotionRepo "server/internal/product/promotion/repository"
+	promotionService "server/internal/product/promotion/service"
 	"server/internal/product/scheduler"
 	userHandler "server/internal/product/user/handler"
 	userRepo "server/internal/product/user/repository"
 	userService "server/internal/product/user/service"
 	"server/pkg/db"
+	"server/pkg/idempotency"
+	"server/pkg/idgen"
 	myRedis "server/pkg/redis"
 
 	"github.com/gin-gonic/gin"
@@ -25,6 +34,36 @@ import (
 	"gorm.io/gorm"
 )
 
+// 可配置的存储模式（config.Storage.Mode）
+const (
+	StorageModePersistent = "persistent" // MySQL + Redis（stock.backend为database时只使用MySQL）
+	StorageModeMemory     = "memory"     // 进程内存储，不依赖MySQL和Redis
+)
+
+// Storage 外部存储连接，memory存储模式下均为nil，stock.backend为database时RedisDB为nil
+type Storage struct {
+	dig.In
+
+	GormDB  *gorm.DB      `optional:"true"`
+	RedisDB *redis.Client `optional:"true"`
+}
+
+// Close 关闭外部存储连接
+func (s Storage) Close() {
+	if s.GormDB != nil {
+		if sqlDB, err := s.GormDB.DB(); err == nil {
+			if err = sqlDB.Close(); err != nil {
+				log.Error("Failed to close database:", err)
+			}
+		}
+	}
+	if s.RedisDB != nil {
+		if err := s.RedisDB.Close(); err != nil {
+			log.Error("Failed to close Redis:", err)
+		}
+	}
+}
+
 // BuildContainer 构建依赖注入容器
 func BuildContainer() *dig.Container {
 	container := dig.New()
@@ -34,41 +73,42 @@ func BuildContainer() *dig.Container {
 		log.Fatalf("Failed to provide config: %v", err)
 	}
 
-	// 提供数据库连接
-	if err := container.Provide(func(cfg *config.Config) (*gorm.DB, error) {
-		return db.InitDB(cfg)
-	}); err != nil {
-		log.Fatalf("Failed to provide database connection: %v", err)
+	// 读取配置以确定存储模式
+	var cfg *config.Config
+	if err := container.Invoke(func(c *config.Config) { cfg = c }); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// 提供 Redis 连接
-	if err := container.Provide(func(cfg *config.Config) (*redis.Client, error) {
-		return myRedis.InitRedis(cfg)
-	}); err != nil {
-		log.Fatalf("Failed to provide Redis connection: %v", err)
+	// 按存储模式提供存储连接、幂等性记录存储和 Repositories
+	switch cfg.Storage.Mode {
+	case "", StorageModePersistent:
+		providePersistentStorage(container, cfg)
+	case StorageModeMemory:
+		log.Warn("storage mode is memory, all data will be lost when the server stops")
+		provideMemoryStorage(container)
+	default:
+		log.Fatalf("不支持的存储模式: %s", cfg.Storage.Mode)
 	}
 
-	// 提供 Repositories
-	if err := container.Provide(orderRepo.NewOrderDQRepository); err != nil {
-		log.Fatalf("Failed to provide OrderDQRepository: %v", err)
-	}
-	if err := container.Provide(commodityRepo.NewRedisCommodityRepository); err != nil {
-		log.Fatalf("Failed to provide redisCommodityRepository: %v", err)
-	}
-	if err := container.Provide(userRepo.NewUserRepository); err != nil {
-		log.Fatalf("Failed to provide UserRepository: %v", err)
-	}
-	if err := container.Provide(commodityRepo.NewCommodityRepository); err != nil {
-		log.Fatalf("Failed to provide CommodityRepository: %v", err)
-	}
-	if err := container.Provide(cartRepo.NewCartRepository); err != nil {
-		log.Fatalf("Failed to provide CartRepository: %v", err)
+	// 提供订单号生成器
+	if err := container.Provide(func(cfg *config.Config) (*idgen.Generator, error) {
+		return idgen.NewGenerator(cfg.Server.NodeId)
+	}); err != nil {
+		log.Fatalf("Failed to provide IdGenerator: %v", err)
 	}
-	if err := container.Provide(orderRepo.NewOrderRepository); err != nil {
-		log.Fatalf("Failed to provide OrderRepository: %v", err)
+
+	// 提供支付网关
+	if err := container.Provide(paymentGateway.NewPaymentGateway); err != nil {
+		log.Fatalf("Failed to provide PaymentGateway: %v", err)
 	}
 
 	// 提供 Services
+	if err := container.Provide(promotionService.NewCouponService); err != nil {
+		log.Fatalf("Failed to provide CouponService: %v", err)
+	}
+	if err := container.Provide(promotionService.NewFlashSaleService); err != nil {
+		log.Fatalf("Failed to provide FlashSaleService: %v", err)
+	}
 	if err := container.Provide(orderService.NewOrderCancelService); err != nil {
 		log.Fatalf("Failed to provide OrderCancelService: %v", err)
 	}
@@ -84,9 +124,27 @@ func BuildContainer() *dig.Container {
 	if err := container.Provide(orderService.NewOrderService); err != nil {
 		log.Fatalf("Failed to provide OrderService: %v", err)
 	}
+	if err := container.Provide(orderService.NewOrderArchiveService); err != nil {
+		log.Fatalf("Failed to provide OrderArchiveService: %v", err)
+	}
+	if err := container.Provide(paymentService.NewPaymentService); err != nil {
+		log.Fatalf("Failed to provide PaymentService: %v", err)
+	}
+	if err := container.Provide(paymentService.NewRefundService); err != nil {
+		log.Fatalf("Failed to provide RefundService: %v", err)
+	}
 	if err := container.Provide(commodityService.NewStockCacheService); err != nil {
 		log.Fatalf("Failed to provide StockCacheService: %v", err)
 	}
+	if err := container.Provide(commodityService.NewStockLedgerService); err != nil {
+		log.Fatalf("Failed to provide StockLedgerService: %v", err)
+	}
+	if err := container.Provide(commodityService.NewStockReconcileService); err != nil {
+		log.Fatalf("Failed to provide StockReconcileService: %v", err)
+	}
+	if err := container.Provide(commodityService.NewWarehouseService); err != nil {
+		log.Fatalf("Failed to provide WarehouseService: %v", err)
+	}
 
 	// 提供 Scheduler
 	if err := container.Provide(scheduler.NewOrderDQScheduler); err != nil {
@@ -98,6 +156,12 @@ func BuildContainer() *dig.Container {
 	if err := container.Provide(scheduler.NewRecoveryScheduler); err != nil {
 		log.Fatalf("Failed to provide RecoveryScheduler: %v", err)
 	}
+	if err := container.Provide(scheduler.NewOrderArchiveScheduler); err != nil {
+		log.Fatalf("Failed to provide OrderArchiveScheduler: %v", err)
+	}
+	if err := container.Provide(scheduler.NewStockReconcileScheduler); err != nil {
+		log.Fatalf("Failed to provide StockReconcileScheduler: %v", err)
+	}
 
 	// 提供 Handlers
 	if err := container.Provide(userHandler.NewUserHandler); err != nil {
@@ -106,12 +170,33 @@ func BuildContainer() *dig.Container {
 	if err := container.Provide(commodityHandler.NewCommodityHandler); err != nil {
 		log.Fatalf("Failed to provide CommodityHandler: %v", err)
 	}
+	if err := container.Provide(commodityHandler.NewStockHandler); err != nil {
+		log.Fatalf("Failed to provide StockHandler: %v", err)
+	}
+	if err := container.Provide(commodityHandler.NewWarehouseHandler); err != nil {
+		log.Fatalf("Failed to provide WarehouseHandler: %v", err)
+	}
 	if err := container.Provide(cartHandler.NewCartHandler); err != nil {
 		log.Fatalf("Failed to provide CartHandler: %v", err)
 	}
 	if err := container.Provide(orderHandler.NewOrderHandler); err != nil {
 		log.Fatalf("Failed to provide OrderHandler: %v", err)
 	}
+	if err := container.Provide(orderHandler.NewOrderArchiveHandler); err != nil {
+		log.Fatalf("Failed to provide OrderArchiveHandler: %v", err)
+	}
+	if err := container.Provide(paymentHandler.NewPaymentHandler); err != nil {
+		log.Fatalf("Failed to provide PaymentHandler: %v", err)
+	}
+	if err := container.Provide(paymentHandler.NewRefundHandler); err != nil {
+		log.Fatalf("Failed to provide RefundHandler: %v", err)
+	}
+	if err := container.Provide(promotionHandler.NewCouponHandler); err != nil {
+		log.Fatalf("Failed to provide CouponHandler: %v", err)
+	}
+	if err := container.Provide(promotionHandler.NewFlashSaleHandler); err != nil {
+		log.Fatalf("Failed to provide FlashSaleHandler: %v", err)
+	}
 
 	// 提供 Gin Engine
 	if err := container.Provide(gin.Default); err != nil {
@@ -120,3 +205,175 @@ func BuildContainer() *dig.Container {
 
 	return container
 }
+
+// providePersistentStorage 提供MySQL连接和基于它的 Repositories，并按stock.backend提供库存相关的存储
+func providePersistentStorage(container *dig.Container, cfg *config.Config) {
+	// 提供数据库连接
+	if err := container.Provide(func(cfg *config.Config) (*gorm.DB, error) {
+		return db.InitDB(cfg)
+	}); err != nil {
+		log.Fatalf("Failed to provide database connection: %v", err)
+	}
+
+	switch cfg.Stock.Backend {
+	case "", commodityRepo.StockBackendRedis:
+		provideRedisStockStorage(container)
+	case commodityRepo.StockBackendDatabase:
+		provideDatabaseStockStorage(container)
+	default:
+		log.Fatalf("不支持的库存存储: %s", cfg.Stock.Backend)
+	}
+
+	// 提供 Repositories
+	if err := container.Provide(commodityRepo.NewStockMovementRepository); err != nil {
+		log.Fatalf("Failed to provide StockMovementRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewWarehouseRepository); err != nil {
+		log.Fatalf("Failed to provide WarehouseRepository: %v", err)
+	}
+	if err := container.Provide(userRepo.NewUserRepository); err != nil {
+		log.Fatalf("Failed to provide UserRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewCommodityRepository); err != nil {
+		log.Fatalf("Failed to provide CommodityRepository: %v", err)
+	}
+	if err := container.Provide(cartRepo.NewCartRepository); err != nil {
+		log.Fatalf("Failed to provide CartRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewOrderRepository); err != nil {
+		log.Fatalf("Failed to provide OrderRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewOrderEventRepository); err != nil {
+		log.Fatalf("Failed to provide OrderEventRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewOrderArchiveRepository); err != nil {
+		log.Fatalf("Failed to provide OrderArchiveRepository: %v", err)
+	}
+	if err := container.Provide(paymentRepo.NewPaymentRepository); err != nil {
+		log.Fatalf("Failed to provide PaymentRepository: %v", err)
+	}
+	if err := container.Provide(paymentRepo.NewRefundRepository); err != nil {
+		log.Fatalf("Failed to provide RefundRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewCouponRepository); err != nil {
+		log.Fatalf("Failed to provide CouponRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewCouponUsageRepository); err != nil {
+		log.Fatalf("Failed to provide CouponUsageRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewFlashSaleRepository); err != nil {
+		log.Fatalf("Failed to provide FlashSaleRepository: %v", err)
+	}
+}
+
+// provideRedisStockStorage 提供Redis连接，以及基于Redis的库存缓存、延迟队列、幂等性记录存储和优惠券计数器
+func provideRedisStockStorage(container *dig.Container) {
+	// 提供 Redis 连接
+	if err := container.Provide(func(cfg *config.Config) (*redis.Client, error) {
+		return myRedis.InitRedis(cfg)
+	}); err != nil {
+		log.Fatalf("Failed to provide Redis connection: %v", err)
+	}
+
+	// 提供幂等性记录存储
+	if err := container.Provide(idempotency.NewRedisStore); err != nil {
+		log.Fatalf("Failed to provide idempotency Store: %v", err)
+	}
+
+	// 提供 Repositories
+	if err := container.Provide(orderRepo.NewOrderDQRepository); err != nil {
+		log.Fatalf("Failed to provide OrderDQRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewRedisCommodityRepository); err != nil {
+		log.Fatalf("Failed to provide StockCacheRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewCouponCounterRepository); err != nil {
+		log.Fatalf("Failed to provide CouponCounterRepository: %v", err)
+	}
+}
+
+// provideDatabaseStockStorage 提供基于数据库的库存、延迟队列、幂等性记录存储和优惠券计数器，不连接Redis
+func provideDatabaseStockStorage(container *dig.Container) {
+	// 提供幂等性记录存储
+	if err := container.Provide(idempotency.NewDBStore); err != nil {
+		log.Fatalf("Failed to provide idempotency Store: %v", err)
+	}
+
+	// 提供 Repositories
+	if err := container.Provide(orderRepo.NewDBOrderDQRepository); err != nil {
+		log.Fatalf("Failed to provide OrderDQRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewDBStockRepository); err != nil {
+		log.Fatalf("Failed to provide StockCacheRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewDBCouponCounterRepository); err != nil {
+		log.Fatalf("Failed to provide CouponCounterRepository: %v", err)
+	}
+}
+
+// provideMemoryStorage 提供基于进程内存储的幂等性记录存储和 Repositories，不连接MySQL和Redis
+// 同一模块的内存仓储共享该模块的MemoryStore；库存直接在内存中扣减，忽略stock.backend配置
+func provideMemoryStorage(container *dig.Container) {
+	// 提供幂等性记录存储
+	if err := container.Provide(idempotency.NewMemoryStore); err != nil {
+		log.Fatalf("Failed to provide idempotency Store: %v", err)
+	}
+
+	// 提供各模块共享的进程内存储
+	if err := container.Provide(commodityRepo.NewMemoryStore); err != nil {
+		log.Fatalf("Failed to provide commodity MemoryStore: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewMemoryStore); err != nil {
+		log.Fatalf("Failed to provide order MemoryStore: %v", err)
+	}
+
+	// 提供 Repositories
+	if err := container.Provide(orderRepo.NewMemoryOrderDQRepository); err != nil {
+		log.Fatalf("Failed to provide OrderDQRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewMemoryStockRepository); err != nil {
+		log.Fatalf("Failed to provide StockCacheRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewMemoryStockMovementRepository); err != nil {
+		log.Fatalf("Failed to provide StockMovementRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewMemoryWarehouseRepository); err != nil {
+		log.Fatalf("Failed to provide WarehouseRepository: %v", err)
+	}
+	if err := container.Provide(userRepo.NewMemoryUserRepository); err != nil {
+		log.Fatalf("Failed to provide UserRepository: %v", err)
+	}
+	if err := container.Provide(commodityRepo.NewMemoryCommodityRepository); err != nil {
+		log.Fatalf("Failed to provide CommodityRepository: %v", err)
+	}
+	if err := container.Provide(cartRepo.NewMemoryCartRepository); err != nil {
+		log.Fatalf("Failed to provide CartRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewMemoryOrderRepository); err != nil {
+		log.Fatalf("Failed to provide OrderRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewMemoryOrderEventRepository); err != nil {
+		log.Fatalf("Failed to provide OrderEventRepository: %v", err)
+	}
+	if err := container.Provide(orderRepo.NewMemoryOrderArchiveRepository); err != nil {
+		log.Fatalf("Failed to provide OrderArchiveRepository: %v", err)
+	}
+	if err := container.Provide(paymentRepo.NewMemoryPaymentRepository); err != nil {
+		log.Fatalf("Failed to provide PaymentRepository: %v", err)
+	}
+	if err := container.Provide(paymentRepo.NewMemoryRefundRepository); err != nil {
+		log.Fatalf("Failed to provide RefundRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewMemoryCouponRepository); err != nil {
+		log.Fatalf("Failed to provide CouponRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewMemoryCouponUsageRepository); err != nil {
+		log.Fatalf("Failed to provide CouponUsageRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewMemoryCouponCounterRepository); err != nil {
+		log.Fatalf("Failed to provide CouponCounterRepository: %v", err)
+	}
+	if err := container.Provide(promotionRepo.NewMemoryFlashSaleRepository); err != nil {
+		log.Fatalf("Failed to provide FlashSaleRepository: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dbRecord 数据库中的幂等性记录，Status为0表示首次请求仍在处理中
+type dbRecord struct {
+	IdempotencyKey string `gorm:"primary_key;size:255"`
+	Fingerprint    string `gorm:"size:64"`
+	Status         int
+	Body           []byte
+	ExpireAt       time.Time `gorm:"index"`
+}
+
+// TableName 幂等性记录的表名
+func (dbRecord) TableName() string {
+	return "idempotency_records"
+}
+
+// dbSweepInterval 清理过期记录的最小间隔
+const dbSweepInterval = time.Minute
+
+type dbStore struct {
+	gormDB    *gorm.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewDBStore 创建一个基于数据库的幂等性记录存储，用于database库存存储，不依赖Redis
+// 过期的记录视为不存在；写入时顺带删除过期的记录（每个进程最多每dbSweepInterval一次）
+func NewDBStore(gDB *gorm.DB) Store {
+	return &dbStore{gormDB: gDB, lastSweep: time.Now()}
+}
+
+// Begin 以主键冲突时不插入的方式占用幂等键，占用失败时读取已有记录
+// 读取前记录恰好被释放时按处理中返回，调用方拒绝本次请求，客户端重试即可
+func (s *dbStore) Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	db := s.gormDB.WithContext(ctx)
+	if err := db.Where("idempotency_key = ? AND expire_at <= ?", key, now).Delete(&dbRecord{}).Error; err != nil {
+		log.Error("Failed to begin idempotent request:", err)
+		return nil, false, err
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&dbRecord{IdempotencyKey: key, Fingerprint: fingerprint, ExpireAt: now.Add(ttl)})
+	if result.Error != nil {
+		log.Error("Failed to begin idempotent request:", result.Error)
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		s.sweepExpired(ctx, now)
+		return nil, true, nil
+	}
+
+	var row dbRecord
+	if err := db.Where("idempotency_key = ?", key).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, nil
+		}
+		log.Error("Failed to read idempotent record:", err)
+		return nil, false, err
+	}
+	return checkRecord(&Record{Fingerprint: row.Fingerprint, Status: row.Status, Body: row.Body}, fingerprint)
+}
+
+// Complete 保存请求的响应快照，record.Fingerprint应与Begin时一致
+func (s *dbStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	row := &dbRecord{
+		IdempotencyKey: key,
+		Fingerprint:    record.Fingerprint,
+		Status:         record.Status,
+		Body:           record.Body,
+		ExpireAt:       time.Now().Add(ttl),
+	}
+	return s.gormDB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fingerprint", "status", "body", "expire_at"}),
+	}).Create(row).Error
+}
+
+// Release 删除幂等键
+func (s *dbStore) Release(ctx context.Context, key string) error {
+	return s.gormDB.WithContext(ctx).Delete(&dbRecord{}, "idempotency_key = ?", key).Error
+}
+
+// sweepExpired 删除所有过期的记录，删除失败只记录日志
+func (s *dbStore) sweepExpired(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < dbSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+	if err := s.gormDB.WithContext(ctx).Where("expire_at <= ?", now).Delete(&dbRecord{}).Error; err != nil {
+		log.Warn("Failed to clean up expired idempotent records:", err)
+	}
+}
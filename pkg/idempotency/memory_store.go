@@ -0,0 +1,72 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryEntry 内存中的幂等性记录，record.Status为0表示请求处理中
+type memoryEntry struct {
+	record   *Record
+	expireAt time.Time
+}
+
+// memorySweepInterval 清理过期记录的最小间隔，避免每次写入都遍历所有记录
+const memorySweepInterval = time.Minute
+
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore 创建一个基于进程内存储的幂等性记录存储，用于memory存储模式
+// 写入时顺带清理过期的记录（最多每memorySweepInterval一次），不再访问的键也不会一直占用内存
+func NewMemoryStore() Store {
+	return &memoryStore{entries: make(map[string]*memoryEntry), lastSweep: time.Now()}
+}
+
+// sweepExpired 删除所有过期的记录，调用方需持有锁
+func (s *memoryStore) sweepExpired(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	for key, entry := range s.entries {
+		if !entry.expireAt.After(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Begin 原子性地占用幂等键或读取已有记录
+func (s *memoryStore) Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if entry, ok := s.entries[key]; ok && entry.expireAt.After(now) {
+		return checkRecord(entry.record, fingerprint)
+	}
+	s.sweepExpired(now)
+	s.entries[key] = &memoryEntry{record: &Record{Fingerprint: fingerprint}, expireAt: now.Add(ttl)}
+	return nil, true, nil
+}
+
+// Complete 保存请求的响应快照
+func (s *memoryStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweepExpired(now)
+	s.entries[key] = &memoryEntry{record: record, expireAt: now.Add(ttl)}
+	return nil
+}
+
+// Release 删除幂等键
+func (s *memoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package idempotency 提供基于 Idempotency-Key 的请求幂等性记录存储
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrFingerprintMismatch 相同幂等键的请求内容与首次请求不一致（客户端复用了幂等键）
+var ErrFingerprintMismatch = errors.New("idempotency key reused with a different request")
+
+// Record 幂等键对应的记录：Status为0表示首次请求仍在处理中，否则为已完成请求的响应快照，用于重放
+type Record struct {
+	Fingerprint string `json:"fingerprint"` // 首次请求内容的摘要，用于识别复用幂等键的不同请求
+	Status      int    `json:"status"`      // HTTP状态码
+	Body        []byte `json:"body"`        // 响应体
+}
+
+// Store 幂等性记录的存储接口
+type Store interface {
+	// Begin 尝试占用幂等键，fingerprint为请求内容的摘要，与键一起保存
+	// - 占用成功：返回(nil, true, nil)，调用方应继续处理请求
+	// - 请求已完成：返回(record, false, nil)，调用方应直接重放record
+	// - 请求处理中：返回(nil, false, nil)，调用方应拒绝并发的重复请求
+	// - 已有记录的fingerprint不同：返回ErrFingerprintMismatch，调用方应拒绝该请求
+	Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*Record, bool, error)
+	// Complete 保存请求的响应，之后相同键的请求会重放该响应
+	Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release 释放幂等键，允许客户端使用相同键重试
+	Release(ctx context.Context, key string) error
+}
+
+type redisStore struct {
+	rDB *redis.Client
+}
+
+// NewRedisStore 创建一个基于Redis的幂等性记录存储
+func NewRedisStore(rDB *redis.Client) Store {
+	return &redisStore{rDB: rDB}
+}
+
+// Begin 使用Lua脚本原子性地占用幂等键或读取已有记录
+// 占位值为只包含fingerprint的记录，处理完成后由Complete替换为带响应的记录
+func (s *redisStore) Begin(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	placeholder, err := json.Marshal(&Record{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+	luaScript := `
+	local ok = redis.call("SET", KEYS[1], ARGV[1], "NX", "EX", ARGV[2])
+	if ok then
+		return ""
+	end
+	return redis.call("GET", KEYS[1])
+`
+	result, err := s.rDB.Eval(ctx, luaScript, []string{key}, placeholder, int64(ttl.Seconds())).Result()
+	if err != nil {
+		log.Error("Failed to begin idempotent request:", err)
+		return nil, false, err
+	}
+
+	value, _ := result.(string)
+	if value == "" {
+		return nil, true, nil
+	}
+
+	var record Record
+	if err = json.Unmarshal([]byte(value), &record); err != nil {
+		log.Error("Failed to decode idempotent record:", err)
+		return nil, false, err
+	}
+	return checkRecord(&record, fingerprint)
+}
+
+// checkRecord 根据已有记录返回Begin的结果：fingerprint不同时返回ErrFingerprintMismatch，处理中时返回空记录
+func checkRecord(record *Record, fingerprint string) (*Record, bool, error) {
+	if record.Fingerprint != fingerprint {
+		return nil, false, ErrFingerprintMismatch
+	}
+	if record.Status == 0 {
+		return nil, false, nil
+	}
+	return record, false, nil
+}
+
+// Complete 保存请求的响应快照，record.Fingerprint应与Begin时一致
+func (s *redisStore) Complete(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rDB.Set(ctx, key, data, ttl).Err()
+}
+
+// Release 删除幂等键
+func (s *redisStore) Release(ctx context.Context, key string) error {
+	return s.rDB.Del(ctx, key).Err()
+}
@@ -0,0 +1,77 @@
+// Package idgen 提供分布式唯一ID生成，用于对外暴露的业务编号（如订单号）
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+
+	// randomDigits 业务编号末尾追加的随机位数，使编号不可被顺序猜测
+	// 雪花ID最多19位，加上随机位后编号不超过27位，可以存入VARCHAR(32)
+	randomDigits = 8
+	randomRange  = 100000000 // 10^randomDigits
+)
+
+// epoch 时间戳起点（2024-01-01 UTC），41位毫秒时间戳可以使用约69年
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+// Generator 雪花算法ID生成器
+// ID结构（64位）：1位符号位 + 41位毫秒时间戳 + 10位节点ID + 12位序列号
+// 同一节点每毫秒最多生成4096个ID，ID随时间递增；多实例部署时每个实例必须使用不同的节点ID
+type Generator struct {
+	mu       sync.Mutex
+	node     int64
+	lastMs   int64
+	sequence int64
+}
+
+// NewGenerator 创建一个新的ID生成器，nodeId取值范围为0-1023
+func NewGenerator(nodeId int) (*Generator, error) {
+	if nodeId < 0 || nodeId > maxNode {
+		return nil, fmt.Errorf("node id must be between 0 and %d, got %d", maxNode, nodeId)
+	}
+	return &Generator{node: int64(nodeId)}, nil
+}
+
+// NextId 生成下一个ID
+// 同一毫秒内序列号用尽时等待到下一毫秒；时钟回拨时沿用上次的时间戳继续递增序列号，保证ID不重复
+func (g *Generator) NextId() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now().UnixMilli() - epoch
+	if now <= g.lastMs {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			// 序列号用尽，借用下一毫秒
+			g.lastMs++
+			for time.Now().UnixMilli()-epoch < g.lastMs {
+				time.Sleep(time.Millisecond / 10)
+			}
+		}
+	} else {
+		g.lastMs = now
+		g.sequence = 0
+	}
+	return g.lastMs<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence
+}
+
+// NextNo 生成对外暴露的业务编号：雪花ID的十进制表示 + 8位随机数字
+// 编号按生成时间递增（前缀为雪花ID），随机后缀有1亿种取值，即使知道相邻编号的雪花ID也无法枚举出有效编号
+func (g *Generator) NextNo() string {
+	n, err := rand.Int(rand.Reader, big.NewInt(randomRange))
+	if err != nil {
+		// 系统随机源不可用时退化为纳秒时间，编号仍然唯一
+		n = big.NewInt(time.Now().UnixNano() % randomRange)
+	}
+	return fmt.Sprintf("%d%0*d", g.NextId(), randomDigits, n.Int64())
+}
@@ -0,0 +1,18 @@
+// Package memstore 提供memory存储模式下各模块内存仓储共用的辅助函数
+package memstore
+
+// Paginate 按偏移量和条数截取一页记录，limit不大于0时不限制条数
+// 与数据库查询的OFFSET/LIMIT语义一致：偏移量超出记录数时返回空列表
+func Paginate[T any](items []T, offset int, limit int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,81 @@
+// Package money 提供以最小货币单位（分）表示的金额类型，避免浮点数计算带来的精度误差
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency 默认币种（ISO 4217 代码）
+const DefaultCurrency = "CNY"
+
+// Amount 以最小货币单位（分）表示的金额
+// 例如：Amount(1234) 表示 12.34 元
+type Amount int64
+
+// FromFloat 将以元为单位的浮点数金额转换为Amount，四舍五入到分
+// 用于兼容商品表中以DECIMAL/float64存储的价格
+func FromFloat(v float64) Amount {
+	return Amount(math.Round(v * 100))
+}
+
+// Parse 解析以元为单位的金额字符串，例如"12.34"、"12.3"、"12"
+// 小数部分超过两位时返回错误，不做任何舍入
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" || len(fracPart) > 2 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	yuan, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || yuan < 0 {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	var cents int64
+	for i, ch := range fracPart {
+		if ch < '0' || ch > '9' {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+		digit := int64(ch - '0')
+		if i == 0 {
+			digit *= 10
+		}
+		cents += digit
+	}
+
+	amount := Amount(yuan*100 + cents)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// Mul 计算金额乘以数量，用于计算订单行小计
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// MulDiv 按比例换算金额（a * num / den），结果向下取整到分
+// 用于计算百分比折扣和按实付比例分摊退款金额
+func (a Amount) MulDiv(num, den int64) Amount {
+	if den == 0 {
+		return 0
+	}
+	return Amount(int64(a) * num / den)
+}
+
+// String 以元为单位输出金额，保留两位小数，例如"12.34"
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
@@ -0,0 +1,91 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Amount
+		wantErr bool
+	}{
+		{"12.34", 1234, false},
+		{"12.3", 1230, false},
+		{"12", 1200, false},
+		{"0.05", 5, false},
+		{" 7.50 ", 750, false},
+		{"-3.21", -321, false},
+		{"0", 0, false},
+		{"12.345", 0, true},
+		{"", 0, true},
+		{".5", 0, true},
+		{"abc", 0, true},
+		{"1.a", 0, true},
+		{"--1", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		input float64
+		want  Amount
+	}{
+		{12.34, 1234},
+		{0.1 + 0.2, 30},
+		{19.995, 2000},
+		{0, 0},
+		{-1.5, -150},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, FromFloat(tt.input), "FromFloat(%v)", tt.input)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Amount
+		want Amount
+	}{
+		{"Mul", Amount(1234).Mul(3), 3702},
+		{"Mul zero", Amount(1234).Mul(0), 0},
+		{"MulDiv rounds down", Amount(1000).MulDiv(1, 3), 333},
+		{"MulDiv percentage", Amount(1999).MulDiv(15, 100), 299},
+		{"MulDiv exact", Amount(600).MulDiv(2, 3), 400},
+		{"MulDiv zero denominator", Amount(600).MulDiv(2, 0), 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.got, tt.name)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		want   string
+	}{
+		{1234, "12.34"},
+		{5, "0.05"},
+		{100, "1.00"},
+		{0, "0.00"},
+		{-321, "-3.21"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.amount.String())
+	}
+}
@@ -158,3 +158,28 @@ func Ack(ctx context.Context, rdb *redis.Client, id string) error {
 	}
 	return nil
 }
+
+// RemoveDelayTask 从ready和processing队列中移除任务并删除任务数据
+// 与Ack不同，此方法用于任务到期前主动撤销任务（如订单已支付或已被取消）
+func RemoveDelayTask(ctx context.Context, rdb *redis.Client, id string) error {
+	pipe := rdb.TxPipeline()
+	pipe.ZRem(ctx, "dq:ready", id)
+	pipe.ZRem(ctx, "dq:processing", id)
+	pipe.Del(ctx, "dq:payload:"+id)
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		log.Errorf("Failed to remove delay task %s: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// GetDelayTaskTime 获取ready队列中任务的执行时间（Unix时间戳，秒）
+// 任务不在ready队列中（已被获取处理、已完成或已撤销）时返回redis.Nil
+func GetDelayTaskTime(ctx context.Context, rdb *redis.Client, id string) (int64, error) {
+	score, err := rdb.ZScore(ctx, "dq:ready", id).Result()
+	if err != nil {
+		return 0, err
+	}
+	return int64(score), nil
+}
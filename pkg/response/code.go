@@ -4,11 +4,14 @@ package response
 // 格式：模块(2位) + 类型(2位) + 序号(2位)
 const (
 	// 通用错误码 (10xxxx)
-	CodeSuccess       = 0      // 成功
-	CodeInternalError = 100000 // 服务器内部错误
-	CodeInvalidJSON   = 100001 // JSON 格式错误
-	CodeInvalidParams = 100002 // 参数错误
-	CodeUnauthorized  = 100003 // 未授权
+	CodeSuccess             = 0      // 成功
+	CodeInternalError       = 100000 // 服务器内部错误
+	CodeInvalidJSON         = 100001 // JSON 格式错误
+	CodeInvalidParams       = 100002 // 参数错误
+	CodeUnauthorized        = 100003 // 未授权
+	CodeIdempotencyConflict = 100004 // 相同幂等键的请求正在处理中
+	CodeForbidden           = 100005 // 无权限
+	CodeIdempotencyMismatch = 100006 // 幂等键已被用于内容不同的请求
 
 	// 用户模块错误码 (20xxxx)
 	CodeUserNotFound      = 201001 // 用户不存在
@@ -22,15 +25,61 @@ const (
 	CodeCommodityUpdateFailed = 301003 // 商品更新失败
 	CodeCommodityDeleteFailed = 301004 // 商品删除失败
 	CodeCommodityQueryFailed  = 301005 // 商品查询失败
+	CodeWarehouseNotFound     = 301006 // 仓库不存在
+	CodeInvalidWarehouse      = 301007 // 仓库参数无效
+
+	// 购物车模块错误码 (40xxxx)
+	CodeCartEmpty    = 401001 // 购物车为空
+	CodeCartNotFound = 401002 // 购物车条目不存在
+
+	// 订单模块错误码 (50xxxx)
+	CodeOrderNotFound          = 501001 // 订单不存在
+	CodeOrderCreateFailed      = 501002 // 订单创建失败
+	CodeInsufficientStock      = 501003 // 库存不足
+	CodeOrderPriceMismatch     = 501004 // 订单总价与服务端计算结果不一致
+	CodeOrderIllegalTransition = 501005 // 订单状态不允许此流转
+	CodeOrderNotDeletable      = 501006 // 订单未结束，不能删除
+	CodeOrderNotPending        = 501007 // 订单不是待支付状态
+	CodeAddressNotEditable     = 501008 // 订单已发货或已结束，不能修改地址
+
+	// 支付模块错误码 (60xxxx)
+	CodeOrderNotPayable         = 601001 // 订单当前状态不允许支付
+	CodePaymentCreateFailed     = 601002 // 支付单创建失败
+	CodePaymentNotFound         = 601003 // 支付单不存在
+	CodePaymentSignatureInvalid = 601004 // 回调签名无效
+	CodePaymentAmountMismatch   = 601005 // 实付金额与支付单金额不一致
+	CodePaymentOrderClosed      = 601006 // 支付成功但订单已关闭
+	CodePaymentDuplicated       = 601007 // 支付成功但订单已由其他支付单支付
+	CodeOrderNotRefundable      = 602001 // 订单当前状态不允许退款
+	CodeRefundInProgress        = 602002 // 订单已有处理中的退款
+	CodeInvalidRefundItems      = 602003 // 退款商品或数量无效
+	CodeRefundNotFound          = 602004 // 退款单不存在
+	CodeRefundNotPending        = 602005 // 退款单已审批
+	CodeRefundFailed            = 602006 // 网关退款失败
+
+	// 营销模块错误码 (70xxxx)
+	CodeCouponNotFound      = 701001 // 优惠券不存在
+	CodeCouponNotApplicable = 701002 // 优惠券不可用于本订单
+	CodeCouponExhausted     = 701003 // 优惠券已被领完
+	CodeCouponUserLimit     = 701004 // 用户使用次数已达上限
+	CodeInvalidCoupon       = 701005 // 优惠券参数无效
+	CodeFlashSaleNotFound   = 702001 // 秒杀活动不存在
+	CodeFlashSaleNotStarted = 702002 // 秒杀活动尚未开始
+	CodeFlashSaleSoldOut    = 702003 // 秒杀活动配额已售完
+	CodeFlashSaleUserLimit  = 702004 // 用户秒杀购买件数已达上限
+	CodeInvalidFlashSale    = 702005 // 秒杀活动参数无效
 )
 
 // 错误消息映射表
 var msgMap = map[int]string{
-	CodeSuccess:       "操作成功",
-	CodeInternalError: "服务器内部错误",
-	CodeInvalidJSON:   "JSON 格式错误",
-	CodeInvalidParams: "参数错误",
-	CodeUnauthorized:  "未授权",
+	CodeSuccess:             "操作成功",
+	CodeInternalError:       "服务器内部错误",
+	CodeInvalidJSON:         "JSON 格式错误",
+	CodeInvalidParams:       "参数错误",
+	CodeUnauthorized:        "未授权",
+	CodeIdempotencyConflict: "请求正在处理中，请勿重复提交",
+	CodeForbidden:           "无权限",
+	CodeIdempotencyMismatch: "幂等键已被用于其他请求",
 
 	CodeUserNotFound:      "用户不存在",
 	CodeUserAlreadyExists: "用户已存在",
@@ -42,6 +91,45 @@ var msgMap = map[int]string{
 	CodeCommodityUpdateFailed: "商品更新失败",
 	CodeCommodityDeleteFailed: "商品删除失败",
 	CodeCommodityQueryFailed:  "商品查询失败",
+	CodeWarehouseNotFound:     "仓库不存在",
+	CodeInvalidWarehouse:      "仓库参数无效",
+
+	CodeCartEmpty:    "购物车为空",
+	CodeCartNotFound: "购物车条目不存在",
+
+	CodeOrderNotFound:          "订单不存在",
+	CodeOrderCreateFailed:      "订单创建失败",
+	CodeInsufficientStock:      "库存不足",
+	CodeOrderPriceMismatch:     "订单价格已变动，请刷新后重试",
+	CodeOrderIllegalTransition: "订单当前状态不允许此操作",
+	CodeOrderNotDeletable:      "订单未结束，不能删除",
+	CodeOrderNotPending:        "订单不是待支付状态",
+	CodeAddressNotEditable:     "订单已发货或已结束，不能修改收货地址",
+
+	CodeOrderNotPayable:         "订单当前状态不允许支付",
+	CodePaymentCreateFailed:     "支付单创建失败",
+	CodePaymentNotFound:         "支付单不存在",
+	CodePaymentSignatureInvalid: "回调签名无效",
+	CodePaymentAmountMismatch:   "实付金额与订单金额不一致",
+	CodePaymentOrderClosed:      "订单已关闭，支付将退回",
+	CodePaymentDuplicated:       "订单已支付，重复支付将退回",
+	CodeOrderNotRefundable:      "订单当前状态不允许退款",
+	CodeRefundInProgress:        "订单已有处理中的退款申请",
+	CodeInvalidRefundItems:      "退款商品或数量无效",
+	CodeRefundNotFound:          "退款单不存在",
+	CodeRefundNotPending:        "退款单已审批，不能重复操作",
+	CodeRefundFailed:            "退款失败，请稍后重试",
+
+	CodeCouponNotFound:      "优惠券不存在",
+	CodeCouponNotApplicable: "优惠券不适用于本订单",
+	CodeCouponExhausted:     "优惠券已被领完",
+	CodeCouponUserLimit:     "已达到该优惠券的使用次数上限",
+	CodeInvalidCoupon:       "优惠券参数无效",
+	CodeFlashSaleNotFound:   "秒杀活动不存在",
+	CodeFlashSaleNotStarted: "秒杀活动尚未开始",
+	CodeFlashSaleSoldOut:    "秒杀商品已抢完",
+	CodeFlashSaleUserLimit:  "已达到秒杀限购件数",
+	CodeInvalidFlashSale:    "秒杀活动参数无效",
 }
 
 // GetMsg 根据错误码获取错误消息
@@ -8,9 +8,27 @@ import (
 
 // Response 统一响应结构
 type Response struct {
-	Code    int         `json:"code"`    // 业务错误码
-	Message string      `json:"message"` // 提示信息
-	Data    interface{} `json:"data"`    // 数据
+	Code       int         `json:"code"`                 // 业务错误码
+	Message    string      `json:"message"`              // 提示信息
+	Data       interface{} `json:"data"`                 // 数据
+	Pagination *Pagination `json:"pagination,omitempty"` // 分页信息（仅列表接口返回）
+}
+
+// Pagination 分页元数据
+type Pagination struct {
+	Page       int   `json:"page"`        // 当前页码（从1开始）
+	PageSize   int   `json:"page_size"`   // 每页条数
+	Total      int64 `json:"total"`       // 总条数
+	TotalPages int   `json:"total_pages"` // 总页数
+}
+
+// NewPagination 根据页码、每页条数和总条数构建分页元数据
+func NewPagination(page, pageSize int, total int64) *Pagination {
+	totalPages := 0
+	if pageSize > 0 {
+		totalPages = int((total + int64(pageSize) - 1) / int64(pageSize))
+	}
+	return &Pagination{Page: page, PageSize: pageSize, Total: total, TotalPages: totalPages}
 }
 
 // Success 成功响应
@@ -31,6 +49,16 @@ func SuccessWithMessage(c *gin.Context, message string, data interface{}) {
 	})
 }
 
+// SuccessWithPagination 成功响应（列表数据 + 分页信息）
+func SuccessWithPagination(c *gin.Context, data interface{}, pagination *Pagination) {
+	c.JSON(http.StatusOK, Response{
+		Code:       CodeSuccess,
+		Message:    "success",
+		Data:       data,
+		Pagination: pagination,
+	})
+}
+
 // Error 错误响应
 func Error(c *gin.Context, httpStatus int, code int, message string) {
 	c.JSON(httpStatus, Response{
@@ -55,6 +83,16 @@ func Forbidden(c *gin.Context, code int, message string) {
 	Error(c, http.StatusForbidden, code, message)
 }
 
+// Conflict 409 错误
+func Conflict(c *gin.Context, code int, message string) {
+	Error(c, http.StatusConflict, code, message)
+}
+
+// UnprocessableEntity 422 错误
+func UnprocessableEntity(c *gin.Context, code int, message string) {
+	Error(c, http.StatusUnprocessableEntity, code, message)
+}
+
 // NotFound 404 错误
 func NotFound(c *gin.Context, code int, message string) {
 	Error(c, http.StatusNotFound, code, message)